@@ -2,8 +2,10 @@ package client
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,6 +14,7 @@ import (
 	"github.com/bluesky-social/indigo/lex/util"
 	"github.com/bluesky-social/indigo/xrpc"
 	"github.com/jtarrio/k3"
+	"github.com/jtarrio/k3/posts"
 	"github.com/lestrrat-go/jwx/v3/jwt"
 )
 
@@ -26,6 +29,47 @@ type Client interface {
 	FindUserByHandle(ctx context.Context, handle string) (*UserData, error)
 }
 
+// RkeyPublisher is implemented by clients that can publish posts with a given record key.
+//
+// The clients returned by New implement this interface.
+type RkeyPublisher interface {
+	// PublishWithRkey saves the given post with the given record key, returning the post's CID and URI.
+	//
+	// If publishing fails but a post with the same record key, text, and creation time already exists,
+	// for example because an earlier attempt was saved but its response was lost, it returns the existing post's CID and URI.
+	PublishWithRkey(ctx context.Context, post *bsky.FeedPost, rkey string) (*PublishResult, error)
+}
+
+// RecordPublisher is implemented by clients that can publish post records with custom facet features.
+//
+// The clients returned by New implement this interface.
+type RecordPublisher interface {
+	// PublishRecord saves the given post record in the user's timeline, returning the post's CID and URI.
+	PublishRecord(ctx context.Context, record *posts.Record) (*PublishResult, error)
+}
+
+// GatePublisher is implemented by clients that can publish threadgates and postgates.
+//
+// The clients returned by New implement this interface.
+type GatePublisher interface {
+	// PublishThreadgate saves a threadgate, which limits who can reply to a thread, for the post given in its Post field.
+	//
+	// If publishing fails but a threadgate for the same post already exists, it returns the existing threadgate's CID and URI.
+	PublishThreadgate(ctx context.Context, threadgate *bsky.FeedThreadgate) (*PublishResult, error)
+	// PublishPostgate saves a postgate, which limits how a post can be embedded, for the post given in its Post field.
+	//
+	// If publishing fails but a postgate for the same post already exists, it returns the existing postgate's CID and URI.
+	PublishPostgate(ctx context.Context, postgate *bsky.FeedPostgate) (*PublishResult, error)
+}
+
+// Deleter is implemented by clients that can delete records.
+//
+// The clients returned by New implement this interface.
+type Deleter interface {
+	// Delete removes the record with the given URI, such as a post, a threadgate, or a postgate.
+	Delete(ctx context.Context, uri string) error
+}
+
 // PublishResult holds the result of the Publish method.
 type PublishResult struct {
 	// Uri contains the post's URI.
@@ -80,6 +124,13 @@ func WithClock(clock k3.Clock) ClientOption {
 // ClientOption is a modifier for NewClient.
 type ClientOption func(*clientImpl)
 
+var (
+	_ RkeyPublisher   = &clientImpl{}
+	_ RecordPublisher = &clientImpl{}
+	_ GatePublisher   = &clientImpl{}
+	_ Deleter         = &clientImpl{}
+)
+
 type clientImpl struct {
 	identifier string
 	password   string
@@ -136,22 +187,172 @@ func (c *clientImpl) Publish(ctx context.Context, post *bsky.FeedPost) (*Publish
 	if err := c.GetAccessToken(ctx); err != nil {
 		return nil, err
 	}
+	result, err := c.createRecord(ctx, "app.bsky.feed.post", nil, post)
+	if err != nil {
+		return nil, fmt.Errorf("could not publish a post: %w", err)
+	}
+	return result, nil
+}
+
+func (c *clientImpl) PublishRecord(ctx context.Context, record *posts.Record) (*PublishResult, error) {
+	if err := c.GetAccessToken(ctx); err != nil {
+		return nil, err
+	}
+	result, err := c.createRecord(ctx, "app.bsky.feed.post", nil, record)
+	if err != nil {
+		return nil, fmt.Errorf("could not publish a post: %w", err)
+	}
+	return result, nil
+}
+
+func (c *clientImpl) PublishWithRkey(ctx context.Context, post *bsky.FeedPost, rkey string) (*PublishResult, error) {
+	if err := c.GetAccessToken(ctx); err != nil {
+		return nil, err
+	}
+	result, err := c.createRecord(ctx, "app.bsky.feed.post", &rkey, post)
+	if err == nil {
+		return result, nil
+	}
+	if existing, record, getErr := c.getRecord(ctx, "app.bsky.feed.post", rkey); getErr == nil {
+		if existingPost, ok := record.(*bsky.FeedPost); ok && existingPost.Text == post.Text && existingPost.CreatedAt == post.CreatedAt {
+			return existing, nil
+		}
+	}
+	return nil, fmt.Errorf("could not publish a post: %w", err)
+}
+
+func (c *clientImpl) PublishThreadgate(ctx context.Context, threadgate *bsky.FeedThreadgate) (*PublishResult, error) {
+	rkey, err := rkeyOf(threadgate.Post)
+	if err != nil {
+		return nil, fmt.Errorf("could not publish a threadgate: %w", err)
+	}
+	if err := c.GetAccessToken(ctx); err != nil {
+		return nil, err
+	}
+	result, err := c.createRecord(ctx, "app.bsky.feed.threadgate", &rkey, &threadgateRecord{threadgate})
+	if err != nil {
+		if existing, record, getErr := c.getRecord(ctx, "app.bsky.feed.threadgate", rkey); getErr == nil {
+			if existingGate, ok := record.(*bsky.FeedThreadgate); ok && existingGate.Post == threadgate.Post {
+				return existing, nil
+			}
+		}
+		return nil, fmt.Errorf("could not publish a threadgate: %w", err)
+	}
+	return result, nil
+}
+
+func (c *clientImpl) PublishPostgate(ctx context.Context, postgate *bsky.FeedPostgate) (*PublishResult, error) {
+	rkey, err := rkeyOf(postgate.Post)
+	if err != nil {
+		return nil, fmt.Errorf("could not publish a postgate: %w", err)
+	}
+	if err := c.GetAccessToken(ctx); err != nil {
+		return nil, err
+	}
+	result, err := c.createRecord(ctx, "app.bsky.feed.postgate", &rkey, postgate)
+	if err != nil {
+		if existing, record, getErr := c.getRecord(ctx, "app.bsky.feed.postgate", rkey); getErr == nil {
+			if existingGate, ok := record.(*bsky.FeedPostgate); ok && existingGate.Post == postgate.Post {
+				return existing, nil
+			}
+		}
+		return nil, fmt.Errorf("could not publish a postgate: %w", err)
+	}
+	return result, nil
+}
+
+func (c *clientImpl) Delete(ctx context.Context, uri string) error {
+	repo, collection, rkey, err := splitUri(uri)
+	if err != nil {
+		return fmt.Errorf("could not delete a record: %w", err)
+	}
+	if err := c.GetAccessToken(ctx); err != nil {
+		return err
+	}
+	c.xrpcMutex.RLock()
+	defer c.xrpcMutex.RUnlock()
+	input := &atproto.RepoDeleteRecord_Input{
+		Collection: collection,
+		Repo:       repo,
+		Rkey:       rkey,
+	}
+	if _, err := atproto.RepoDeleteRecord(ctx, c.xrpc, input); err != nil {
+		return fmt.Errorf("could not delete record '%s': %w", uri, err)
+	}
+	return nil
+}
+
+func (c *clientImpl) createRecord(ctx context.Context, collection string, rkey *string, record util.CBOR) (*PublishResult, error) {
 	c.xrpcMutex.RLock()
 	defer c.xrpcMutex.RUnlock()
 	input := &atproto.RepoCreateRecord_Input{
-		Collection: "app.bsky.feed.post",
-		Record:     &util.LexiconTypeDecoder{Val: post},
+		Collection: collection,
+		Record:     &util.LexiconTypeDecoder{Val: record},
 		Repo:       c.xrpc.Auth.Did,
+		Rkey:       rkey,
 	}
 	output, err := atproto.RepoCreateRecord(ctx, c.xrpc, input)
 	if err != nil {
-		return nil, fmt.Errorf("could not publish a post: %w", err)
+		return nil, err
 	}
 	result := &PublishResult{
 		Uri: output.Uri,
 		Cid: output.Cid,
 	}
-	return result, err
+	return result, nil
+}
+
+// threadgateRecord is a threadgate that keeps its list of rules in JSON even when it's empty.
+//
+// bsky.FeedThreadgate omits an empty list, but a threadgate without a list lets everybody reply, instead of nobody.
+type threadgateRecord struct {
+	*bsky.FeedThreadgate
+}
+
+func (r *threadgateRecord) MarshalJSON() ([]byte, error) {
+	if r.Allow == nil {
+		return json.Marshal(r.FeedThreadgate)
+	}
+	type plainThreadgate bsky.FeedThreadgate
+	return json.Marshal(struct {
+		*plainThreadgate
+		Allow []*bsky.FeedThreadgate_Allow_Elem `json:"allow"`
+	}{(*plainThreadgate)(r.FeedThreadgate), r.Allow})
+}
+
+// getRecord retrieves the record with the given collection and record key from the user's repository.
+func (c *clientImpl) getRecord(ctx context.Context, collection string, rkey string) (*PublishResult, any, error) {
+	c.xrpcMutex.RLock()
+	defer c.xrpcMutex.RUnlock()
+	output, err := atproto.RepoGetRecord(ctx, c.xrpc, "", collection, c.xrpc.Auth.Did, rkey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if output.Cid == nil || output.Value == nil {
+		return nil, nil, fmt.Errorf("incomplete record for '%s'", output.Uri)
+	}
+	return &PublishResult{Uri: output.Uri, Cid: *output.Cid}, output.Value.Val, nil
+}
+
+// rkeyOf returns the record key of the given post URI, which is the last component of its path.
+//
+// Threadgates and postgates must have the same record key as the post they apply to.
+func rkeyOf(uri string) (string, error) {
+	i := strings.LastIndexByte(uri, '/')
+	if i < 0 || i == len(uri)-1 {
+		return "", fmt.Errorf("invalid post URI '%s'", uri)
+	}
+	return uri[i+1:], nil
+}
+
+// splitUri returns the repository, collection, and record key of the given record URI.
+func splitUri(uri string) (string, string, string, error) {
+	path, found := strings.CutPrefix(uri, "at://")
+	parts := strings.Split(path, "/")
+	if !found || len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", "", "", fmt.Errorf("invalid record URI '%s'", uri)
+	}
+	return parts[0], parts[1], parts[2], nil
 }
 
 func (c *clientImpl) FindUserByHandle(ctx context.Context, username string) (*UserData, error) {
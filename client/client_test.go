@@ -1,11 +1,17 @@
 package client_test
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/api/bsky"
 	"github.com/bluesky-social/indigo/atproto/identity"
 	"github.com/bluesky-social/indigo/lex/util"
 	"github.com/jtarrio/k3"
@@ -140,3 +146,154 @@ func TestSearchUsersByHandle(t *testing.T) {
 	_, err = c.FindUserByHandle(ctx, "xxxxx")
 	assert.Error(t, err)
 }
+
+func TestPublishGates(t *testing.T) {
+	username := "testuser"
+	password := "testpass"
+	clock := &atptesting.FakeClock{Time: time.Date(2025, time.January, 2, 12, 34, 56, 0, time.UTC)}
+	fakeServer := atptesting.NewFakeServer(atptesting.WithClock(clock))
+	fakeServer.AddUser(username, password)
+	defer fakeServer.Close()
+
+	ctx := context.Background()
+	c := client.New(username, password, client.WithHost(fakeServer.URL()), client.WithClock(clock))
+	post := k3.NewPost().SetCreationTime(clock.Now()).AddText(`y así como don Quijote los vio, dijo a su escudero`)
+	result, err := c.Publish(ctx, posts.NewConverter(posts.WithClock(clock)).ToFeedPost(post))
+	require.NoError(t, err)
+	assert.Equal(t, "at://did:web:testuser/app.bsky.feed.post/0", result.Uri)
+
+	threadgate := &bsky.FeedThreadgate{
+		LexiconTypeID: "app.bsky.feed.threadgate",
+		Allow:         []*bsky.FeedThreadgate_Allow_Elem{{FeedThreadgate_FollowerRule: &bsky.FeedThreadgate_FollowerRule{LexiconTypeID: "app.bsky.feed.threadgate#followerRule"}}},
+		CreatedAt:     "2025-01-02T12:34:56Z",
+		Post:          result.Uri,
+	}
+	_, err = c.(client.GatePublisher).PublishThreadgate(ctx, threadgate)
+	require.NoError(t, err)
+
+	postgate := &bsky.FeedPostgate{
+		LexiconTypeID:  "app.bsky.feed.postgate",
+		CreatedAt:      "2025-01-02T12:34:56Z",
+		EmbeddingRules: []*bsky.FeedPostgate_EmbeddingRules_Elem{{FeedPostgate_DisableRule: &bsky.FeedPostgate_DisableRule{LexiconTypeID: "app.bsky.feed.postgate#disableRule"}}},
+		Post:           result.Uri,
+	}
+	_, err = c.(client.GatePublisher).PublishPostgate(ctx, postgate)
+	require.NoError(t, err)
+
+	assert.Equal(t, []atptesting.Threadgate{{Repo: "did:web:" + username, Rkey: "0", Record: threadgate}}, fakeServer.Threadgates)
+	assert.Equal(t, []atptesting.Postgate{{Repo: "did:web:" + username, Rkey: "0", Record: postgate}}, fakeServer.Postgates)
+
+	// Retrying doesn't fail or create duplicates.
+	_, err = c.(client.GatePublisher).PublishThreadgate(ctx, threadgate)
+	require.NoError(t, err)
+	_, err = c.(client.GatePublisher).PublishPostgate(ctx, postgate)
+	require.NoError(t, err)
+	assert.Len(t, fakeServer.Threadgates, 1)
+	assert.Len(t, fakeServer.Postgates, 1)
+
+	_, err = c.(client.GatePublisher).PublishPostgate(ctx, &bsky.FeedPostgate{Post: "invalid/"})
+	assert.ErrorContains(t, err, "invalid post URI")
+}
+
+func TestDelete(t *testing.T) {
+	username := "testuser"
+	password := "testpass"
+	fakeServer := atptesting.NewFakeServer()
+	fakeServer.AddUser(username, password)
+	defer fakeServer.Close()
+
+	ctx := context.Background()
+	c := client.New(username, password, client.WithHost(fakeServer.URL()))
+	first, err := c.Publish(ctx, &bsky.FeedPost{Text: "first"})
+	require.NoError(t, err)
+	second, err := c.Publish(ctx, &bsky.FeedPost{Text: "second"})
+	require.NoError(t, err)
+
+	require.NoError(t, c.(client.Deleter).Delete(ctx, first.Uri))
+	require.Len(t, fakeServer.Posts, 1)
+	assert.Equal(t, "second", fakeServer.Posts[0].Record.Text)
+
+	third, err := c.Publish(ctx, &bsky.FeedPost{Text: "third"})
+	require.NoError(t, err)
+	assert.NotEqual(t, second.Uri, third.Uri)
+
+	assert.ErrorContains(t, c.(client.Deleter).Delete(ctx, "at://did:web:testuser/app.bsky.feed.post"), "invalid record URI")
+}
+
+// recordingTransport sends the requests to the server and keeps their bodies.
+type recordingTransport struct {
+	bodies []string
+}
+
+func (r *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		r.bodies = append(r.bodies, string(body))
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestPublishRecord(t *testing.T) {
+	username := "testuser"
+	password := "testpass"
+	fakeServer := atptesting.NewFakeServer()
+	fakeServer.AddUser(username, password)
+	defer fakeServer.Close()
+
+	ctx := context.Background()
+	transport := &recordingTransport{}
+	c := client.New(username, password, client.WithHost(fakeServer.URL()), client.WithHttpClient(&http.Client{Transport: transport}))
+	post := k3.NewPost().AddText("Up ").AddBlock(k3.NewBlock("12%", k3.WithCustomFeature("com.example.facet#highlight", map[string]string{"color": "green"})))
+	result, err := c.(client.RecordPublisher).PublishRecord(ctx, posts.NewConverter().ToRecord(post))
+	require.NoError(t, err)
+	assert.Equal(t, "at://did:web:testuser/app.bsky.feed.post/0", result.Uri)
+	require.Len(t, fakeServer.Posts, 1)
+	assert.Equal(t, "Up 12%", fakeServer.Posts[0].Record.Text)
+	assert.Contains(t, transport.bodies[len(transport.bodies)-1], `{"$type":"com.example.facet#highlight","color":"green"}`)
+}
+
+// lossyTransport sends the requests to the server, but loses the response to the first createRecord call.
+type lossyTransport struct {
+	lost bool
+}
+
+func (l *lossyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err == nil && !l.lost && strings.HasSuffix(req.URL.Path, "/com.atproto.repo.createRecord") {
+		l.lost = true
+		resp.Body.Close()
+		return nil, errors.New("connection reset")
+	}
+	return resp, err
+}
+
+func TestPublishWithRkey(t *testing.T) {
+	username := "testuser"
+	password := "testpass"
+	fakeServer := atptesting.NewFakeServer()
+	fakeServer.AddUser(username, password)
+	defer fakeServer.Close()
+
+	ctx := context.Background()
+	c := client.New(username, password, client.WithHost(fakeServer.URL()), client.WithHttpClient(&http.Client{Transport: &lossyTransport{}}))
+	post := &bsky.FeedPost{Text: "only once", CreatedAt: "2025-01-02T12:34:56.789Z"}
+
+	// The post is saved, but the response is lost, so the client checks whether it exists.
+	result, err := c.(client.RkeyPublisher).PublishWithRkey(ctx, post, "3ler3lwntkc2b")
+	require.NoError(t, err)
+	assert.Equal(t, "at://did:web:testuser/app.bsky.feed.post/3ler3lwntkc2b", result.Uri)
+
+	// Retrying doesn't create a duplicate.
+	again, err := c.(client.RkeyPublisher).PublishWithRkey(ctx, post, "3ler3lwntkc2b")
+	require.NoError(t, err)
+	assert.Equal(t, result, again)
+	assert.Len(t, fakeServer.Posts, 1)
+
+	// A different post with the same record key is an error.
+	_, err = c.(client.RkeyPublisher).PublishWithRkey(ctx, &bsky.FeedPost{Text: "another", CreatedAt: post.CreatedAt}, "3ler3lwntkc2b")
+	assert.ErrorContains(t, err, "could not publish a post")
+}
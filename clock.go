@@ -1,11 +1,42 @@
 package k3
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
-// Clock is an interface for objects that return the current time.
+// Clock is an interface for objects that return the current time and wait for time to pass.
 type Clock interface {
 	// Now returns the current time
 	Now() time.Time
+	// After returns a channel that receives the current time after the given duration has elapsed.
+	After(d time.Duration) <-chan time.Time
+	// NewTimer creates a timer that sends the current time on its channel after the given duration has elapsed.
+	NewTimer(d time.Duration) Timer
+	// NewTicker creates a ticker that sends the current time on its channel every time the given period elapses.
+	NewTicker(d time.Duration) Ticker
+	// Sleep waits until the given duration has elapsed or the context is done, in which case it returns the context's error.
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// Timer is an interface for single-use timers, like time.Timer.
+type Timer interface {
+	// C returns the channel where the time is sent when the timer fires.
+	C() <-chan time.Time
+	// Stop prevents the timer from firing. It returns false if the timer had already fired or been stopped.
+	Stop() bool
+	// Reset changes the timer to fire after the given duration. It returns true if the timer was active.
+	Reset(d time.Duration) bool
+}
+
+// Ticker is an interface for periodic timers, like time.Ticker.
+type Ticker interface {
+	// C returns the channel where the time is sent every time the ticker fires.
+	C() <-chan time.Time
+	// Stop turns off the ticker.
+	Stop()
+	// Reset stops the ticker and changes its period to the given duration.
+	Reset(d time.Duration)
 }
 
 // NewSystemClock returns a Clock that uses the computer's clock.
@@ -19,18 +50,72 @@ func (c systemClock) Now() time.Time {
 	return time.Now()
 }
 
+func (c systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (c systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+func (c systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+func (c systemClock) Sleep(ctx context.Context, d time.Duration) error {
+	return Sleep(ctx, c, d)
+}
+
+type systemTimer struct {
+	*time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// Sleep waits on the given clock until the duration has elapsed or the context is done.
+//
+// It can be used to implement the Sleep method of a Clock.
+func Sleep(ctx context.Context, clock Clock, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if d <= 0 {
+		return nil
+	}
+	timer := clock.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
+
 // NewIncreasingClock modifies the given clock so it always increases by at least 1 millisecond.
+//
+// Timers and tickers are delegated to the given clock.
 func NewIncreasingClock(clock Clock) Clock {
-	return &increasingClock{parent: clock}
+	return &increasingClock{Clock: clock}
 }
 
 type increasingClock struct {
-	parent Clock
-	next   time.Time
+	Clock
+	next time.Time
 }
 
 func (c *increasingClock) Now() time.Time {
-	now := c.parent.Now()
+	now := c.Clock.Now()
 	if now.Before(c.next) {
 		now = c.next
 	}
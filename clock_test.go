@@ -0,0 +1,38 @@
+package k3_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jtarrio/k3"
+	atptesting "github.com/jtarrio/k3/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSystemClockSleep(t *testing.T) {
+	clock := k3.SystemClock()
+	assert.NoError(t, clock.Sleep(context.Background(), time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, clock.Sleep(ctx, time.Hour), context.Canceled)
+
+	timer := clock.NewTimer(time.Millisecond)
+	<-timer.C()
+	ticker := clock.NewTicker(time.Millisecond)
+	<-ticker.C()
+	ticker.Stop()
+}
+
+func TestIncreasingClock(t *testing.T) {
+	start := time.Date(2025, time.January, 2, 12, 0, 0, 0, time.UTC)
+	fake := &atptesting.FakeClock{Time: start}
+	clock := k3.NewIncreasingClock(fake)
+	assert.Equal(t, start, clock.Now())
+	assert.Equal(t, start.Add(time.Millisecond), clock.Now())
+
+	timer := clock.After(time.Second)
+	fake.Advance(time.Second)
+	assert.Equal(t, start.Add(time.Second), <-timer)
+}
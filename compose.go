@@ -0,0 +1,150 @@
+package k3
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Append adds the blocks of another post at the end of this post.
+//
+// The other post's languages and labels are added to this post's, if it doesn't already have them.
+func (p *Post) Append(other *Post) *Post {
+	for _, block := range other.Blocks {
+		p.AddBlock(block)
+	}
+	p.mergeMetadata(other)
+	return p
+}
+
+// Prepend adds the blocks of another post at the beginning of this post.
+//
+// The other post's languages and labels are added to this post's, if it doesn't already have them.
+func (p *Post) Prepend(other *Post) *Post {
+	p.setBlocks(append(slices.Clone(other.Blocks), p.Blocks...))
+	p.mergeMetadata(other)
+	return p
+}
+
+// Truncate shortens the post so its length is at most maxGraphemes, adding the ellipsis at the end if the post is cut.
+//
+// The post is never cut in the middle of a block with a link, mention, tag, or custom feature, which are removed whole if they don't fit,
+// or in the middle of a grapheme cluster, such as an emoji sequence or a letter with combining accents.
+// Whitespace before the ellipsis is removed. If the ellipsis is longer than maxGraphemes, it is not added.
+func (p *Post) Truncate(maxGraphemes int, ellipsis string) *Post {
+	if p.GetGraphemeLength() <= maxGraphemes {
+		return p
+	}
+	budget := maxGraphemes - utf8.RuneCountInString(ellipsis)
+	if budget < 0 {
+		budget = max(maxGraphemes, 0)
+		ellipsis = ""
+	}
+
+	text := p.GetPlainText()
+	cut := 0
+	count := 0
+	for offset := range text {
+		if count > budget {
+			break
+		}
+		if isGraphemeBoundary(text, offset) && !p.insideFeature(offset) {
+			cut = offset
+		}
+		count++
+	}
+
+	blocks := p.sliceBlocks(0, cut)
+	for len(blocks) > 0 && !blocks[len(blocks)-1].HasFeatures() {
+		last := &blocks[len(blocks)-1]
+		last.Text = strings.TrimRightFunc(last.Text, unicode.IsSpace)
+		if len(last.Text) > 0 {
+			break
+		}
+		blocks = blocks[:len(blocks)-1]
+	}
+	p.setBlocks(blocks)
+	p.AddText(ellipsis)
+	return p
+}
+
+// FitWithSuffix truncates the post so that it fits in maxGraphemes together with the suffix, and then appends the suffix.
+//
+// The suffix is always kept whole, so it can be used to make sure that a trailing link survives the truncation.
+// If the suffix is longer than maxGraphemes, the result is longer than maxGraphemes too.
+func (p *Post) FitWithSuffix(maxGraphemes int, ellipsis string, suffix *Post) *Post {
+	return p.Truncate(maxGraphemes-suffix.GetGraphemeLength(), ellipsis).Append(suffix)
+}
+
+func (p *Post) mergeMetadata(other *Post) {
+	for _, lang := range other.Languages {
+		if !slices.Contains(p.Languages, lang) {
+			p.AddLanguage(lang)
+		}
+	}
+	for _, label := range other.Labels {
+		p.AddLabel(label)
+	}
+}
+
+// insideFeature returns whether the given byte offset is strictly inside a block that has any features.
+func (p *Post) insideFeature(offset int) bool {
+	pos := 0
+	for _, block := range p.Blocks {
+		if offset <= pos {
+			return false
+		}
+		pos += len(block.Text)
+		if offset < pos {
+			return block.HasFeatures()
+		}
+	}
+	return false
+}
+
+// isGraphemeBoundary returns whether a grapheme cluster can end at the given byte offset of the text.
+//
+// This is a simplified version of the Unicode rules that keeps together combining marks, emoji modifiers
+// and sequences, and flags.
+func isGraphemeBoundary(text string, offset int) bool {
+	if offset <= 0 || offset >= len(text) {
+		return true
+	}
+	prev, _ := utf8.DecodeLastRuneInString(text[:offset])
+	next, _ := utf8.DecodeRuneInString(text[offset:])
+	if prev == '\r' && next == '\n' {
+		return false
+	}
+	if prev == zeroWidthJoiner || isGraphemeExtender(next) {
+		return false
+	}
+	if isRegionalIndicator(prev) && isRegionalIndicator(next) {
+		// Flags are pairs of regional indicators, so only cut after an even number of them.
+		count := 0
+		for rest := text[:offset]; len(rest) > 0; {
+			r, size := utf8.DecodeLastRuneInString(rest)
+			if !isRegionalIndicator(r) {
+				break
+			}
+			count++
+			rest = rest[:len(rest)-size]
+		}
+		return count%2 == 0
+	}
+	return true
+}
+
+const zeroWidthJoiner = '\u200d'
+
+func isGraphemeExtender(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+		r == zeroWidthJoiner ||
+		(r >= '\ufe00' && r <= '\ufe0f') || // variation selectors
+		(r >= '\U0001f3fb' && r <= '\U0001f3ff') || // emoji skin tone modifiers
+		(r >= '\U000e0020' && r <= '\U000e007f') // tags
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= '\U0001f1e6' && r <= '\U0001f1ff'
+}
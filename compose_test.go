@@ -0,0 +1,71 @@
+package k3_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jtarrio/k3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendAndPrepend(t *testing.T) {
+	post := k3.NewPost().AddLanguage("en").AddText("Read ").AddLink("this", "https://example.com/")
+	post.Append(k3.NewPost().AddLanguage("es").AddLanguage("en").AddLabel(k3.LabelNudity).AddLink(" article", "https://example.com/").AddText("."))
+	post.Prepend(k3.NewPost().AddLabel(k3.LabelNudity).AddTag("#news", "news").AddText(" "))
+	expected := k3.NewPost().AddLanguage("en").AddLanguage("es").AddLabel(k3.LabelNudity).
+		AddTag("#news", "news").AddText(" Read ").AddLink("this article", "https://example.com/").AddText(".")
+	assert.Equal(t, expected, post)
+}
+
+func TestTruncate(t *testing.T) {
+	post := k3.NewPost().AddText("The quick brown fox jumps over the lazy dog")
+	post.Truncate(100, "…")
+	assert.Equal(t, "The quick brown fox jumps over the lazy dog", post.GetPlainText())
+
+	post.Truncate(20, "…")
+	assert.Equal(t, k3.NewPost().AddText("The quick brown fox…"), post)
+	assert.Equal(t, 20, post.GetGraphemeLength())
+
+	post = k3.NewPost().AddText("Abc").Truncate(2, "...")
+	assert.Equal(t, k3.NewPost().AddText("Ab"), post)
+}
+
+func TestTruncateKeepsFeaturesWhole(t *testing.T) {
+	post := k3.NewPost().AddText("Go to ").AddLink("this link", "https://example.com/").AddText(" now")
+	post.Truncate(12, "…")
+	assert.Equal(t, k3.NewPost().AddText("Go to…"), post)
+
+	post = k3.NewPost().AddText("Go to ").AddLink("this link", "https://example.com/").AddText(" now")
+	post.Truncate(18, "…")
+	assert.Equal(t, k3.NewPost().AddText("Go to ").AddLink("this link", "https://example.com/").AddText(" n…"), post)
+}
+
+func TestTruncateKeepsGraphemeClustersWhole(t *testing.T) {
+	// "e" followed by a combining acute accent.
+	post := k3.NewPost().AddText("Cafe\u0301 con leche")
+	post.Truncate(5, "…")
+	assert.Equal(t, "Caf…", post.GetPlainText())
+
+	// Family emoji made of three people joined with ZWJ.
+	post = k3.NewPost().AddText("Hi \U0001f469\u200d\U0001f469\u200d\U0001f467 there")
+	post.Truncate(7, "…")
+	assert.Equal(t, "Hi…", post.GetPlainText())
+
+	// Two flags, each made of two regional indicators.
+	post = k3.NewPost().AddText("\U0001f1ea\U0001f1f8\U0001f1eb\U0001f1f7 flags")
+	post.Truncate(4, "…")
+	assert.Equal(t, "\U0001f1ea\U0001f1f8…", post.GetPlainText())
+}
+
+func TestFitWithSuffix(t *testing.T) {
+	suffix := k3.NewPost().AddText(" ").AddLink("Read more", "https://example.com/article")
+	post := k3.NewPost().AddText(strings.Repeat("word ", 100))
+	post.FitWithSuffix(300, "…", suffix)
+	assert.LessOrEqual(t, post.GetGraphemeLength(), 300)
+	assert.True(t, strings.HasSuffix(post.GetPlainText(), "word… Read more"))
+	assert.Equal(t, k3.NewBlock("Read more", k3.WithLink("https://example.com/article")), post.Blocks[len(post.Blocks)-1])
+
+	post = k3.NewPost().AddText("Short text.")
+	post.FitWithSuffix(300, "…", suffix)
+	assert.Equal(t, k3.NewPost().AddText("Short text. ").AddLink("Read more", "https://example.com/article"), post)
+}
@@ -0,0 +1,424 @@
+// Package cron publishes posts on a recurring schedule defined by cron expressions.
+//
+// Each Job has a Schedule and a function that returns the posts to publish on each run, which are
+// published through a multiposter.Multiposter. The Cron runner decides what to do with runs that were
+// missed while it was not running and with runs that would overlap a previous run of the same job.
+package cron
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jtarrio/k3"
+	"github.com/jtarrio/k3/client"
+	"github.com/jtarrio/k3/internal/atomicfile"
+	"github.com/jtarrio/k3/multiposter"
+	"github.com/jtarrio/k3/posts"
+)
+
+// MissedPolicy specifies what to do with runs whose time passed while the runner was not running.
+type MissedPolicy int
+
+const (
+	// SkipMissed doesn't perform missed runs. This is the default policy.
+	SkipMissed MissedPolicy = iota
+	// RunLatestMissed performs only the latest of the missed runs.
+	RunLatestMissed
+	// RunAllMissed performs all the missed runs, in order.
+	RunAllMissed
+)
+
+// OverlapPolicy specifies what to do when a run is due while the previous run of the same job is still in progress.
+type OverlapPolicy int
+
+const (
+	// SkipOverlapping doesn't perform the new run. This is the default policy.
+	SkipOverlapping OverlapPolicy = iota
+	// QueueOverlapping performs the new run after the previous run finishes.
+	QueueOverlapping
+	// AllowOverlapping performs the new run at the same time as the previous one.
+	AllowOverlapping
+)
+
+// Job is a task that publishes posts on a recurring schedule.
+type Job struct {
+	// Name identifies the job. It must be unique, and it is used as the key in the state file.
+	Name string
+	// Schedule specifies when the job runs.
+	Schedule *Schedule
+	// Posts returns the posts to publish for the run that was due at the given time.
+	// If it returns no posts, nothing is published.
+	Posts func(ctx context.Context, due time.Time) ([]*k3.Post, error)
+	// Thread indicates that the posts must be published as a thread instead of as a sequence.
+	Thread bool
+	// Missed specifies what to do with the runs that were missed.
+	Missed MissedPolicy
+	// Overlap specifies what to do with runs that would overlap a previous run.
+	Overlap OverlapPolicy
+}
+
+// Run contains the outcome of a job's run.
+type Run struct {
+	// Job is the name of the job.
+	Job string
+	// Due is the time the run was scheduled for.
+	Due time.Time
+	// Skipped is true if the run was not performed because of the MissedPolicy or the OverlapPolicy,
+	// or because the context was cancelled before it started.
+	Skipped bool
+	// Err contains the error returned by the job's Posts function, if any.
+	// For runs skipped because the context was cancelled, it contains the context's error.
+	Err error
+	// Result contains the result of publishing the posts. It is nil if nothing was published.
+	Result *multiposter.PublishResult
+}
+
+// DefaultGracePeriod is the default time after its due time when a run is considered missed.
+const DefaultGracePeriod = time.Minute
+
+// New creates a new Cron runner that publishes posts with the given client.
+func New(client client.Client, options ...CronOption) *Cron {
+	c := &Cron{
+		client:      client,
+		clock:       k3.SystemClock(),
+		gracePeriod: DefaultGracePeriod,
+		onRun:       func(*Run) {},
+		wake:        make(chan struct{}, 1),
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// WithClock makes the runner use the given clock to decide when runs are due and to wait for them.
+func WithClock(clock k3.Clock) CronOption {
+	return func(c *Cron) {
+		c.clock = clock
+	}
+}
+
+// WithGracePeriod sets how long after its due time a run is still performed normally instead of being considered missed.
+//
+// Only the runs that were due this long before Run was called are considered missed.
+func WithGracePeriod(gracePeriod time.Duration) CronOption {
+	return func(c *Cron) {
+		c.gracePeriod = gracePeriod
+	}
+}
+
+// WithStateFile makes the runner remember the time of each job's latest run in a JSON file.
+//
+// Without a state file, the runner can't know which runs were missed while it was not running.
+func WithStateFile(path string) CronOption {
+	return func(c *Cron) {
+		c.statePath = path
+	}
+}
+
+// WithMultiposterOptions sets the options for the multiposter used to publish the posts of each run.
+//
+// AsThread and AsSequence are set according to each job's Thread field, so you don't need to pass them.
+func WithMultiposterOptions(options ...multiposter.MultiposterOption) CronOption {
+	return func(c *Cron) {
+		c.multiposterOptions = options
+	}
+}
+
+// WithRunHandler sets a function that is called after each run is performed or skipped.
+func WithRunHandler(handler func(run *Run)) CronOption {
+	return func(c *Cron) {
+		c.onRun = handler
+	}
+}
+
+type CronOption func(*Cron)
+
+// Cron performs the runs of a set of jobs when they become due.
+type Cron struct {
+	client             client.Client
+	clock              k3.Clock
+	gracePeriod        time.Duration
+	statePath          string
+	multiposterOptions []multiposter.MultiposterOption
+	onRun              func(*Run)
+	wake               chan struct{}
+	started            time.Time
+
+	mutex sync.Mutex
+	jobs  []*jobState
+	state map[string]time.Time
+}
+
+type jobState struct {
+	job *Job
+	// last is the due time of the latest run that was processed.
+	last time.Time
+	// running is the number of goroutines performing runs of this job.
+	running int
+	// queued contains runs waiting for the current run to finish.
+	queued []time.Time
+}
+
+// Add adds a job to the runner. It can be called while the runner is running.
+func (c *Cron) Add(job *Job) error {
+	if job.Name == "" {
+		return errors.New("the job has no name")
+	}
+	if job.Schedule == nil {
+		return fmt.Errorf("the job %s has no schedule", job.Name)
+	}
+	if job.Posts == nil {
+		return fmt.Errorf("the job %s has no Posts function", job.Name)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, js := range c.jobs {
+		if js.job.Name == job.Name {
+			return fmt.Errorf("there is already a job named %s", job.Name)
+		}
+	}
+	c.jobs = append(c.jobs, &jobState{job: job})
+	c.notify()
+	return nil
+}
+
+// Remove removes the job with the given name from the runner. Runs already in progress are not interrupted.
+func (c *Cron) Remove(name string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i, js := range c.jobs {
+		if js.job.Name == name {
+			js.queued = nil
+			c.jobs = append(c.jobs[:i], c.jobs[i+1:]...)
+			break
+		}
+	}
+	c.notify()
+}
+
+// Run performs the jobs' runs as they become due, until the context is cancelled.
+//
+// A job's runs are recorded as done in the state file when they start, so a run that is interrupted
+// is not performed again. When the context is cancelled, Run waits for the runs in progress to finish,
+// and the runs that were queued behind them are passed to the run handler as skipped.
+func (c *Cron) Run(ctx context.Context) error {
+	if err := c.loadState(); err != nil {
+		return err
+	}
+	c.started = c.clock.Now()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		next, err := c.processDue(ctx, &wg)
+		if err != nil {
+			return err
+		}
+		var timer k3.Timer
+		var timerC <-chan time.Time
+		if !next.IsZero() {
+			timer = c.clock.NewTimer(next.Sub(c.clock.Now()))
+			timerC = timer.C()
+		}
+		select {
+		case <-ctx.Done():
+		case <-timerC:
+		case <-c.wake:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// processDue starts the runs that are due, and returns the time of the next run, if any.
+func (c *Cron) processDue(ctx context.Context, wg *sync.WaitGroup) (time.Time, error) {
+	var skipped []*Run
+	defer func() {
+		for _, run := range skipped {
+			c.onRun(run)
+		}
+	}()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := c.clock.Now()
+	var next time.Time
+	changed := false
+	for _, js := range c.jobs {
+		if js.last.IsZero() {
+			js.last = c.state[js.job.Name]
+		}
+		if js.last.IsZero() {
+			js.last = now
+			changed = true
+		}
+		var missed, due []time.Time
+		for t := js.job.Schedule.Next(js.last); !t.IsZero() && !t.After(now); t = js.job.Schedule.Next(t) {
+			if c.started.Sub(t) > c.gracePeriod {
+				missed = append(missed, t)
+			} else {
+				due = append(due, t)
+			}
+			js.last = t
+			changed = true
+		}
+
+		switch js.job.Missed {
+		case SkipMissed:
+			for _, t := range missed {
+				skipped = append(skipped, &Run{Job: js.job.Name, Due: t, Skipped: true})
+			}
+		case RunLatestMissed:
+			if len(missed) > 0 {
+				for _, t := range missed[:len(missed)-1] {
+					skipped = append(skipped, &Run{Job: js.job.Name, Due: t, Skipped: true})
+				}
+				due = append(missed[len(missed)-1:], due...)
+			}
+		case RunAllMissed:
+			due = append(missed, due...)
+		}
+
+		if len(due) > 0 {
+			skipped = append(skipped, c.start(ctx, wg, js, due)...)
+		}
+		if t := js.job.Schedule.Next(js.last); !t.IsZero() && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+	if changed {
+		if err := c.saveState(); err != nil {
+			return time.Time{}, err
+		}
+	}
+	return next, nil
+}
+
+// start performs the given runs of a job in order, according to the job's OverlapPolicy.
+// It returns the runs that were skipped. It must be called with the mutex held.
+func (c *Cron) start(ctx context.Context, wg *sync.WaitGroup, js *jobState, due []time.Time) []*Run {
+	if js.running > 0 {
+		switch js.job.Overlap {
+		case SkipOverlapping:
+			var skipped []*Run
+			for _, t := range due {
+				skipped = append(skipped, &Run{Job: js.job.Name, Due: t, Skipped: true})
+			}
+			return skipped
+		case QueueOverlapping:
+			js.queued = append(js.queued, due...)
+			return nil
+		}
+	}
+	js.running++
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			for _, t := range due {
+				if err := ctx.Err(); err != nil {
+					c.onRun(&Run{Job: js.job.Name, Due: t, Skipped: true, Err: err})
+				} else {
+					c.onRun(c.perform(ctx, js.job, t))
+				}
+			}
+			c.mutex.Lock()
+			if len(js.queued) == 0 || js.job.Overlap != QueueOverlapping {
+				js.running--
+				c.mutex.Unlock()
+				return
+			}
+			due, js.queued = js.queued, nil
+			c.mutex.Unlock()
+		}
+	}()
+	return nil
+}
+
+// perform calls the job's Posts function and publishes the posts it returns.
+func (c *Cron) perform(ctx context.Context, job *Job, due time.Time) *Run {
+	run := &Run{Job: job.Name, Due: due}
+	newPosts, err := job.Posts(ctx, due)
+	if err != nil {
+		run.Err = err
+		return run
+	}
+	if len(newPosts) == 0 {
+		return run
+	}
+	options := append([]multiposter.MultiposterOption{}, c.multiposterOptions...)
+	if job.Thread {
+		options = append(options, multiposter.AsThread())
+	} else {
+		options = append(options, multiposter.AsSequence())
+	}
+	feedPosts := posts.NewConverter(posts.WithClock(c.clock)).ToFeedPosts(newPosts)
+	run.Result = multiposter.New(c.client, options...).Publish(ctx, feedPosts)
+	return run
+}
+
+// notify wakes up the Run loop so it sees the changes in the jobs.
+func (c *Cron) notify() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+const stateFileVersion = 1
+
+type stateFile struct {
+	Version int                  `json:"version"`
+	Jobs    map[string]time.Time `json:"jobs"`
+}
+
+func (c *Cron) loadState() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.state = map[string]time.Time{}
+	if c.statePath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(c.statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var file stateFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("could not read state file %s: %w", c.statePath, err)
+	}
+	if file.Version != stateFileVersion {
+		return fmt.Errorf("unsupported state file version %d", file.Version)
+	}
+	for name, last := range file.Jobs {
+		c.state[name] = last
+	}
+	return nil
+}
+
+// saveState writes the time of each job's latest run into the state file. It must be called with the mutex held.
+func (c *Cron) saveState() error {
+	for _, js := range c.jobs {
+		c.state[js.job.Name] = js.last
+	}
+	if c.statePath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(stateFile{Version: stateFileVersion, Jobs: c.state}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.Write(c.statePath, data)
+}
@@ -0,0 +1,218 @@
+package cron_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jtarrio/k3"
+	"github.com/jtarrio/k3/client"
+	"github.com/jtarrio/k3/cron"
+	atptesting "github.com/jtarrio/k3/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var startTime = time.Date(2025, time.January, 2, 12, 0, 0, 0, time.UTC)
+
+// runCron starts the runner and returns a channel that receives each run.
+func runCron(t *testing.T, c client.Client, jobs []*cron.Job, options ...cron.CronOption) <-chan *cron.Run {
+	runs := make(chan *cron.Run, 100)
+	options = append(options, cron.WithRunHandler(func(run *cron.Run) {
+		if run.Result != nil {
+			assert.NoError(t, run.Result.Error)
+		}
+		runs <- run
+	}))
+	r := cron.New(c, options...)
+	for _, job := range jobs {
+		require.NoError(t, r.Add(job))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		assert.ErrorIs(t, r.Run(ctx), context.Canceled)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return runs
+}
+
+func digest(name string, expr string) *cron.Job {
+	return &cron.Job{
+		Name:     name,
+		Schedule: cron.MustParse(expr, time.UTC),
+		Posts: func(ctx context.Context, due time.Time) ([]*k3.Post, error) {
+			return []*k3.Post{k3.NewPost().AddText(name + " " + due.Format(time.DateTime))}, nil
+		},
+	}
+}
+
+func TestRunsWhenDue(t *testing.T) {
+	clock := &atptesting.FakeClock{Time: startTime}
+	c := atptesting.NewFakeClient()
+	failing := &cron.Job{
+		Name:     "failing",
+		Schedule: cron.MustParse("30 12 * * *", time.UTC),
+		Posts: func(ctx context.Context, due time.Time) ([]*k3.Post, error) {
+			return nil, errors.New("no news today")
+		},
+	}
+	runs := runCron(t, c, []*cron.Job{digest("hourly", "0 * * * *"), failing}, cron.WithClock(clock))
+
+	clock.BlockUntil(1)
+	clock.Advance(30 * time.Minute)
+	run := <-runs
+	assert.Equal(t, "failing", run.Job)
+	assert.EqualError(t, run.Err, "no news today")
+	assert.Nil(t, run.Result)
+
+	clock.BlockUntil(1)
+	clock.Advance(30 * time.Minute)
+	run = <-runs
+	assert.Equal(t, "hourly", run.Job)
+	assert.True(t, startTime.Add(time.Hour).Equal(run.Due))
+	assert.False(t, run.Skipped)
+	assert.Equal(t, []string{"hourly 2025-01-02 13:00:00"}, c.Texts())
+}
+
+func TestMissedRuns(t *testing.T) {
+	tests := []struct {
+		policy    cron.MissedPolicy
+		skipped   int
+		published []string
+	}{
+		{cron.SkipMissed, 3, nil},
+		{cron.RunLatestMissed, 2, []string{"job 2025-01-02 11:00:00"}},
+		{cron.RunAllMissed, 0, []string{"job 2025-01-02 09:00:00", "job 2025-01-02 10:00:00", "job 2025-01-02 11:00:00"}},
+	}
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "state.json")
+		clock := &atptesting.FakeClock{Time: startTime.Add(-4 * time.Hour)}
+		job := digest("job", "0 * * * *")
+		job.Missed = test.policy
+
+		// The first time, the runner records the time of the latest run.
+		r := cron.New(atptesting.NewFakeClient(), cron.WithClock(clock), cron.WithStateFile(path))
+		require.NoError(t, r.Add(job))
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- r.Run(ctx) }()
+		clock.BlockUntil(1)
+		cancel()
+		assert.ErrorIs(t, <-done, context.Canceled)
+
+		// The second time, it finds the runs that were missed since then.
+		clock.Set(startTime.Add(-30 * time.Minute))
+		c := atptesting.NewFakeClient()
+		runs := runCron(t, c, []*cron.Job{job}, cron.WithClock(clock), cron.WithStateFile(path))
+		skipped := 0
+		for range 3 {
+			if run := <-runs; run.Skipped {
+				skipped++
+			}
+		}
+		assert.Equal(t, test.skipped, skipped)
+		assert.Equal(t, test.published, c.Texts())
+	}
+}
+
+func TestRunsDueWhileRunningAreNotMissed(t *testing.T) {
+	clock := &atptesting.FakeClock{Time: startTime}
+	c := atptesting.NewFakeClient()
+	runs := runCron(t, c, []*cron.Job{digest("hourly", "0 * * * *")}, cron.WithClock(clock))
+
+	// The runner wakes up long after the runs were due, but it was running when they became due.
+	clock.BlockUntil(1)
+	clock.Advance(3 * time.Hour)
+	for range 3 {
+		assert.False(t, (<-runs).Skipped)
+	}
+	assert.Equal(t, []string{"hourly 2025-01-02 13:00:00", "hourly 2025-01-02 14:00:00", "hourly 2025-01-02 15:00:00"}, c.Texts())
+}
+
+func TestOverlappingRuns(t *testing.T) {
+	tests := []struct {
+		policy    cron.OverlapPolicy
+		published []string
+	}{
+		{cron.SkipOverlapping, []string{"slow 2025-01-02 12:01:00"}},
+		{cron.QueueOverlapping, []string{"slow 2025-01-02 12:01:00", "slow 2025-01-02 12:02:00"}},
+	}
+	for _, test := range tests {
+		clock := &atptesting.FakeClock{Time: startTime}
+		c := atptesting.NewFakeClient()
+		started := make(chan time.Time, 10)
+		release := make(chan struct{})
+		job := digest("slow", "* * * * *")
+		job.Overlap = test.policy
+		posts := job.Posts
+		job.Posts = func(ctx context.Context, due time.Time) ([]*k3.Post, error) {
+			started <- due
+			<-release
+			return posts(ctx, due)
+		}
+		runs := runCron(t, c, []*cron.Job{job}, cron.WithClock(clock))
+
+		clock.BlockUntil(1)
+		clock.Advance(time.Minute)
+		<-started
+		clock.BlockUntil(1)
+		clock.Advance(time.Minute)
+		if test.policy == cron.SkipOverlapping {
+			run := <-runs
+			assert.True(t, run.Skipped)
+			assert.True(t, startTime.Add(2*time.Minute).Equal(run.Due))
+		}
+		close(release)
+		for range test.published {
+			assert.False(t, (<-runs).Skipped)
+		}
+		assert.Equal(t, test.published, c.Texts())
+	}
+}
+
+func TestQueuedRunsAreReportedWhenCancelled(t *testing.T) {
+	clock := &atptesting.FakeClock{Time: startTime}
+	c := atptesting.NewFakeClient()
+	started := make(chan time.Time, 10)
+	release := make(chan struct{})
+	job := digest("slow", "* * * * *")
+	job.Overlap = cron.QueueOverlapping
+	posts := job.Posts
+	job.Posts = func(ctx context.Context, due time.Time) ([]*k3.Post, error) {
+		started <- due
+		<-release
+		return posts(ctx, due)
+	}
+	runs := make(chan *cron.Run, 10)
+	r := cron.New(c, cron.WithClock(clock), cron.WithRunHandler(func(run *cron.Run) { runs <- run }))
+	require.NoError(t, r.Add(job))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.Run(ctx) }()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	<-started
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	clock.BlockUntil(1)
+	cancel()
+	close(release)
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	run := <-runs
+	assert.False(t, run.Skipped)
+	assert.True(t, startTime.Add(time.Minute).Equal(run.Due))
+	run = <-runs
+	assert.True(t, run.Skipped)
+	assert.ErrorIs(t, run.Err, context.Canceled)
+	assert.True(t, startTime.Add(2*time.Minute).Equal(run.Due))
+	assert.Equal(t, []string{"slow 2025-01-02 12:01:00"}, c.Texts())
+}
@@ -0,0 +1,341 @@
+package cron
+
+import (
+	"fmt"
+	"math/bits"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed five-field cron expression that is evaluated in a time zone.
+//
+// The fields are minute (0-59), hour (0-23), day of month (1-31), month (1-12 or JAN-DEC) and
+// day of week (0-7 or SUN-SAT, where both 0 and 7 are Sunday). Each field can contain '*', a value,
+// a range like 1-5, a step like */15 or 10-50/20, or a comma-separated list of those. Like in Vixie cron,
+// if both the day of month and the day of week are restricted, a day matches if either field matches.
+//
+// The macros @yearly, @annually, @monthly, @weekly, @daily, @midnight, and @hourly are also accepted.
+//
+// Daylight saving time transitions are handled like most cron implementations do: runs whose time
+// is skipped when the clocks go forward happen at the moment of the transition, and runs whose time
+// is repeated when the clocks go back only happen once, unless the schedule runs every hour.
+type Schedule struct {
+	expr      string
+	location  *time.Location
+	minute    bitset
+	hour      bitset
+	dom       bitset
+	month     bitset
+	dow       bitset
+	domStar   bool
+	dowStar   bool
+	everyHour bool
+}
+
+type bitset uint64
+
+func (b bitset) has(n int) bool {
+	return b&(1<<n) != 0
+}
+
+// Parse parses a cron expression that is evaluated in the given location.
+//
+// If the location is nil, the local time zone is used. The expression can be prefixed with
+// CRON_TZ=<zone> or TZ=<zone> to override the location.
+func Parse(expr string, location *time.Location) (*Schedule, error) {
+	if location == nil {
+		location = time.Local
+	}
+	spec := strings.TrimSpace(expr)
+	if rest, ok := cutTimeZone(spec); ok {
+		zone, remainder, _ := strings.Cut(rest, " ")
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time zone in cron expression '%s': %w", expr, err)
+		}
+		location = loc
+		spec = strings.TrimSpace(remainder)
+	}
+	if macro, ok := macros[spec]; ok {
+		spec = macro
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression '%s' must have 5 fields, has %d", expr, len(fields))
+	}
+	s := &Schedule{expr: expr, location: location}
+	var err error
+	if s.minute, err = parseField(fields[0], minuteField); err != nil {
+		return nil, fmt.Errorf("invalid cron expression '%s': %w", expr, err)
+	}
+	if s.hour, err = parseField(fields[1], hourField); err != nil {
+		return nil, fmt.Errorf("invalid cron expression '%s': %w", expr, err)
+	}
+	if s.dom, err = parseField(fields[2], domField); err != nil {
+		return nil, fmt.Errorf("invalid cron expression '%s': %w", expr, err)
+	}
+	if s.month, err = parseField(fields[3], monthField); err != nil {
+		return nil, fmt.Errorf("invalid cron expression '%s': %w", expr, err)
+	}
+	if s.dow, err = parseField(fields[4], dowField); err != nil {
+		return nil, fmt.Errorf("invalid cron expression '%s': %w", expr, err)
+	}
+	if s.dow.has(7) {
+		s.dow = s.dow&^(1<<7) | 1
+	}
+	s.domStar = strings.HasPrefix(fields[2], "*")
+	s.dowStar = strings.HasPrefix(fields[4], "*")
+	s.everyHour = bits.OnesCount64(uint64(s.hour)) == 24
+	if !s.canMatch() {
+		return nil, fmt.Errorf("cron expression '%s' never matches", expr)
+	}
+	return s, nil
+}
+
+// MustParse is like Parse, but it panics if the expression is not valid.
+func MustParse(expr string, location *time.Location) *Schedule {
+	s, err := Parse(expr, location)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// String returns the expression the schedule was parsed from.
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// Location returns the time zone the schedule is evaluated in.
+func (s *Schedule) Location() *time.Location {
+	return s.location
+}
+
+// Next returns the first time after the given one that matches the schedule.
+//
+// It returns the zero time if there is no such time in the next few years, which can only happen
+// for schedules that run on February 29.
+func (s *Schedule) Next(after time.Time) time.Time {
+	after = after.In(s.location)
+	// The first wall-clock time to look at is the earliest one that could correspond to an instant after
+	// the given time, which depends on whether the clocks go back soon.
+	_, offset := after.Zone()
+	if _, end := after.ZoneBounds(); !end.IsZero() {
+		if _, nextOffset := end.Zone(); nextOffset < offset {
+			offset = nextOffset
+		}
+	}
+	wall := after.UTC().Add(time.Duration(offset) * time.Second).Truncate(time.Minute).Add(time.Minute)
+	limit := wall.AddDate(searchYears, 0, 0)
+	var best time.Time
+	for {
+		wall = s.nextWall(wall, limit)
+		if wall.IsZero() {
+			return best
+		}
+		instants := s.instants(wall)
+		if !best.IsZero() && instants[0].After(best) {
+			return best
+		}
+		for i, instant := range instants {
+			if i > 0 && !s.everyHour {
+				break
+			}
+			if instant.After(after) && (best.IsZero() || instant.Before(best)) {
+				best = instant
+			}
+		}
+		wall = wall.Add(time.Minute)
+	}
+}
+
+// searchYears is how far in the future Next looks for a matching time.
+// It is long enough to find the next February 29 even across a non-leap century year.
+const searchYears = 9
+
+// nextWall returns the first wall-clock time, represented in UTC, that is not before the given one and matches
+// all the fields of the schedule.
+func (s *Schedule) nextWall(wall time.Time, limit time.Time) time.Time {
+	for wall.Before(limit) {
+		year, month, day := wall.Date()
+		if !s.month.has(int(month)) {
+			wall = time.Date(year, month+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !s.dayMatches(wall) {
+			wall = time.Date(year, month, day+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !s.hour.has(wall.Hour()) {
+			wall = wall.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if !s.minute.has(wall.Minute()) {
+			wall = wall.Add(time.Minute)
+			continue
+		}
+		return wall
+	}
+	return time.Time{}
+}
+
+func (s *Schedule) dayMatches(wall time.Time) bool {
+	dom := s.dom.has(wall.Day())
+	dow := s.dow.has(int(wall.Weekday()))
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// instants returns the instants, in order, when the clocks in the schedule's location show the given wall-clock time.
+//
+// There are two instants if the time is repeated because the clocks go back. If the time is skipped because the clocks
+// go forward, the only instant is the moment of the transition.
+func (s *Schedule) instants(wall time.Time) []time.Time {
+	t := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), 0, 0, s.location)
+	start, end := t.ZoneBounds()
+	_, offset := t.Zone()
+	offsets := []int{offset}
+	if !start.IsZero() {
+		_, before := start.Add(-time.Second).Zone()
+		offsets = append(offsets, before)
+	}
+	if !end.IsZero() {
+		_, after := end.Zone()
+		offsets = append(offsets, after)
+	}
+	var out []time.Time
+	for _, offset := range offsets {
+		instant := wall.Add(-time.Duration(offset) * time.Second).In(s.location)
+		if !wallClock(instant).Equal(wall) {
+			continue
+		}
+		out = append(out, instant)
+	}
+	if len(out) > 0 {
+		slices.SortFunc(out, time.Time.Compare)
+		return slices.CompactFunc(out, time.Time.Equal)
+	}
+	if wallClock(t).After(wall) {
+		return []time.Time{start}
+	}
+	return []time.Time{end}
+}
+
+// wallClock returns the wall-clock time of the given time, represented in UTC.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// canMatch returns whether there is any day that matches the schedule.
+func (s *Schedule) canMatch() bool {
+	if !s.dowStar && !s.domStar {
+		return true
+	}
+	if s.domStar && s.dowStar {
+		return s.dom != 0 && s.dow != 0
+	}
+	if s.dow == 0 {
+		return false
+	}
+	for month := 1; month <= 12; month++ {
+		if !s.month.has(month) {
+			continue
+		}
+		for day := 1; day <= maxDays[month]; day++ {
+			if s.dom.has(day) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var maxDays = [13]int{0, 31, 29, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
+
+func cutTimeZone(spec string) (string, bool) {
+	if rest, ok := strings.CutPrefix(spec, "CRON_TZ="); ok {
+		return rest, true
+	}
+	return strings.CutPrefix(spec, "TZ=")
+}
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type fieldSpec struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var (
+	minuteField = fieldSpec{name: "minute", min: 0, max: 59}
+	hourField   = fieldSpec{name: "hour", min: 0, max: 23}
+	domField    = fieldSpec{name: "day of month", min: 1, max: 31}
+	monthField  = fieldSpec{name: "month", min: 1, max: 12, names: []string{"", "JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}}
+	dowField    = fieldSpec{name: "day of week", min: 0, max: 7, names: []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}}
+)
+
+func parseField(field string, spec fieldSpec) (bitset, error) {
+	var out bitset
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepStr)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step '%s' in %s field", stepStr, spec.name)
+			}
+		}
+		var low, high int
+		if rng == "*" {
+			low, high = spec.min, spec.max
+		} else {
+			lowStr, highStr, isRange := strings.Cut(rng, "-")
+			var err error
+			if low, err = spec.parseValue(lowStr); err != nil {
+				return 0, err
+			}
+			high = low
+			if isRange {
+				if high, err = spec.parseValue(highStr); err != nil {
+					return 0, err
+				}
+				if high < low {
+					return 0, fmt.Errorf("invalid range '%s' in %s field", rng, spec.name)
+				}
+			} else if hasStep {
+				high = spec.max
+			}
+		}
+		for n := low; n <= high; n += step {
+			out |= 1 << n
+		}
+	}
+	return out, nil
+}
+
+func (f fieldSpec) parseValue(value string) (int, error) {
+	for i, name := range f.names {
+		if name != "" && strings.EqualFold(value, name) {
+			return i, nil
+		}
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("invalid value '%s' in %s field", value, f.name)
+	}
+	return n, nil
+}
@@ -0,0 +1,135 @@
+package cron_test
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/jtarrio/k3/cron"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	require.NoError(t, err)
+	return loc
+}
+
+// runs returns the next n times when the schedule runs after the given time.
+func runs(s *cron.Schedule, after time.Time, n int) []time.Time {
+	var out []time.Time
+	for range n {
+		after = s.Next(after)
+		out = append(out, after)
+	}
+	return out
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"* * * FOO *",
+		"0 0 30 2 *",
+		"TZ=Nowhere/Land 0 0 * * *",
+	} {
+		_, err := cron.Parse(expr, time.UTC)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestNext(t *testing.T) {
+	start := time.Date(2025, time.January, 1, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		expr     string
+		expected []time.Time
+	}{
+		{"* * * * *", []time.Time{
+			time.Date(2025, time.January, 1, 10, 8, 0, 0, time.UTC),
+			time.Date(2025, time.January, 1, 10, 9, 0, 0, time.UTC),
+		}},
+		{"*/20 9-11 * * *", []time.Time{
+			time.Date(2025, time.January, 1, 10, 20, 0, 0, time.UTC),
+			time.Date(2025, time.January, 1, 10, 40, 0, 0, time.UTC),
+			time.Date(2025, time.January, 1, 11, 0, 0, 0, time.UTC),
+		}},
+		{"0 9 * * MON-FRI", []time.Time{
+			time.Date(2025, time.January, 2, 9, 0, 0, 0, time.UTC),
+			time.Date(2025, time.January, 3, 9, 0, 0, 0, time.UTC),
+			time.Date(2025, time.January, 6, 9, 0, 0, 0, time.UTC),
+		}},
+		{"0 0 1,15 * 7", []time.Time{
+			time.Date(2025, time.January, 5, 0, 0, 0, 0, time.UTC),
+			time.Date(2025, time.January, 12, 0, 0, 0, 0, time.UTC),
+			time.Date(2025, time.January, 15, 0, 0, 0, 0, time.UTC),
+		}},
+		{"30 8 29 feb *", []time.Time{
+			time.Date(2028, time.February, 29, 8, 30, 0, 0, time.UTC),
+			time.Date(2032, time.February, 29, 8, 30, 0, 0, time.UTC),
+		}},
+		{"@weekly", []time.Time{
+			time.Date(2025, time.January, 5, 0, 0, 0, 0, time.UTC),
+			time.Date(2025, time.January, 12, 0, 0, 0, 0, time.UTC),
+		}},
+		{"TZ=Europe/Madrid 0 9 * * *", []time.Time{
+			time.Date(2025, time.January, 2, 8, 0, 0, 0, time.UTC),
+			time.Date(2025, time.January, 3, 8, 0, 0, 0, time.UTC),
+		}},
+	}
+	for _, test := range tests {
+		s, err := cron.Parse(test.expr, time.UTC)
+		require.NoError(t, err, test.expr)
+		actual := runs(s, start, len(test.expected))
+		for i := range actual {
+			assert.True(t, test.expected[i].Equal(actual[i]), "%s: expected %s, got %s", test.expr, test.expected[i], actual[i])
+		}
+	}
+}
+
+func TestDaylightSavingTime(t *testing.T) {
+	tests := []struct {
+		zone     string
+		expr     string
+		after    string
+		expected []string
+	}{
+		// Clocks go forward from 02:00 to 03:00: the 02:30 run happens at 03:00.
+		{"America/New_York", "30 2 * * *", "2025-03-08T12:00:00Z", []string{
+			"2025-03-09T03:00:00-04:00", "2025-03-10T02:30:00-04:00"}},
+		{"Europe/Madrid", "30 2 * * *", "2025-03-29T12:00:00Z", []string{
+			"2025-03-30T03:00:00+02:00", "2025-03-31T02:30:00+02:00"}},
+		{"America/New_York", "*/30 1-3 * * *", "2025-03-09T00:00:00-05:00", []string{
+			"2025-03-09T01:00:00-05:00", "2025-03-09T01:30:00-05:00", "2025-03-09T03:00:00-04:00", "2025-03-09T03:30:00-04:00"}},
+		// Clocks go back from 02:00 to 01:00: the 01:30 run only happens once.
+		{"America/New_York", "30 1 * * *", "2025-11-01T12:00:00Z", []string{
+			"2025-11-02T01:30:00-04:00", "2025-11-03T01:30:00-05:00"}},
+		{"Europe/Madrid", "30 2 * * *", "2025-10-25T12:00:00Z", []string{
+			"2025-10-26T02:30:00+02:00", "2025-10-27T02:30:00+01:00"}},
+		// ... unless the job runs every hour.
+		{"America/New_York", "30 * * * *", "2025-11-02T00:00:00-04:00", []string{
+			"2025-11-02T00:30:00-04:00", "2025-11-02T01:30:00-04:00", "2025-11-02T01:30:00-05:00", "2025-11-02T02:30:00-05:00"}},
+		{"Europe/Madrid", "30 * * * *", "2025-10-26T01:00:00+02:00", []string{
+			"2025-10-26T01:30:00+02:00", "2025-10-26T02:30:00+02:00", "2025-10-26T02:30:00+01:00", "2025-10-26T03:30:00+01:00"}},
+	}
+	for _, test := range tests {
+		s, err := cron.Parse(test.expr, mustLoad(t, test.zone))
+		require.NoError(t, err)
+		after, err := time.Parse(time.RFC3339, test.after)
+		require.NoError(t, err)
+		var actual []string
+		for _, run := range runs(s, after, len(test.expected)) {
+			actual = append(actual, run.Format(time.RFC3339))
+		}
+		assert.Equal(t, test.expected, actual, "%s %s", test.zone, test.expr)
+	}
+}
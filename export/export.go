@@ -0,0 +1,208 @@
+// Package export renders posts and threads as HTML, Markdown, or plain text.
+//
+// Links, mentions, and tags become links in HTML and Markdown. Mentions and tags link to the URLs
+// given by the Exporter's options, which point to bsky.app by default.
+package export
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+
+	"github.com/jtarrio/k3"
+)
+
+// NewExporter creates a new Exporter instance with the given options.
+func NewExporter(options ...ExporterOption) *Exporter {
+	e := &Exporter{profileUrl: DefaultProfileUrl, tagUrl: DefaultTagUrl}
+	for _, option := range options {
+		option(e)
+	}
+	return e
+}
+
+// WithProfileUrl makes the exporter use the given function to generate the URL for a mentioned user's DID.
+func WithProfileUrl(profileUrl func(did string) string) ExporterOption {
+	return func(e *Exporter) {
+		e.profileUrl = profileUrl
+	}
+}
+
+// WithTagUrl makes the exporter use the given function to generate the URL for a tag.
+func WithTagUrl(tagUrl func(tag string) string) ExporterOption {
+	return func(e *Exporter) {
+		e.tagUrl = tagUrl
+	}
+}
+
+type ExporterOption func(*Exporter)
+
+// DefaultProfileUrl returns the URL of the user's profile on bsky.app.
+func DefaultProfileUrl(did string) string {
+	return "https://bsky.app/profile/" + url.PathEscape(did)
+}
+
+// DefaultTagUrl returns the URL of the search results for the tag on bsky.app.
+//
+// Cashtags, which start with '$', are searched as they are; other tags get a '#' prefix.
+func DefaultTagUrl(tag string) string {
+	if strings.HasPrefix(tag, "$") {
+		return "https://bsky.app/search?q=" + url.QueryEscape(tag)
+	}
+	return "https://bsky.app/search?q=" + url.QueryEscape("#"+tag)
+}
+
+// Exporter renders posts in HTML, Markdown, and plain text formats.
+type Exporter struct {
+	profileUrl func(did string) string
+	tagUrl     func(tag string) string
+}
+
+// Html renders the post as an HTML paragraph.
+//
+// Links, mentions, and tags are rendered as <a> elements, and line breaks are rendered as <br> elements.
+// Links that don't use the http or https schemes are rendered as plain text.
+func (e *Exporter) Html(post *k3.Post) string {
+	sb := strings.Builder{}
+	sb.WriteString("<p>")
+	for _, block := range post.Blocks {
+		text := strings.ReplaceAll(html.EscapeString(block.Text), "\n", "<br>\n")
+		if uri := e.blockUrl(block); len(uri) > 0 {
+			fmt.Fprintf(&sb, `<a href="%s">%s</a>`, html.EscapeString(uri), text)
+		} else {
+			sb.WriteString(text)
+		}
+	}
+	sb.WriteString("</p>")
+	return sb.String()
+}
+
+// HtmlThread renders each post in the thread as an HTML paragraph.
+func (e *Exporter) HtmlThread(posts []*k3.Post) string {
+	var out []string
+	for _, post := range posts {
+		out = append(out, e.Html(post))
+	}
+	return strings.Join(out, "\n")
+}
+
+// Markdown renders the post in Markdown format.
+//
+// Links, mentions, and tags are rendered as inline links, and line breaks are rendered as hard line breaks.
+// Links that don't use the http or https schemes are rendered as plain text.
+func (e *Exporter) Markdown(post *k3.Post) string {
+	sb := strings.Builder{}
+	for _, block := range post.Blocks {
+		text := escapeMarkdown(block.Text)
+		if uri := e.blockUrl(block); len(uri) > 0 {
+			fmt.Fprintf(&sb, "[%s](%s)", text, markdownUrl(uri))
+		} else {
+			sb.WriteString(text)
+		}
+	}
+	lines := strings.Split(sb.String(), "\n")
+	for i := range len(lines) - 1 {
+		if len(lines[i]) > 0 && len(lines[i+1]) > 0 {
+			lines[i] += `\`
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// MarkdownThread renders the posts in the thread in Markdown format, separated by horizontal rules.
+func (e *Exporter) MarkdownThread(posts []*k3.Post) string {
+	var out []string
+	for _, post := range posts {
+		out = append(out, e.Markdown(post))
+	}
+	return strings.Join(out, "\n\n---\n\n")
+}
+
+// PlainText renders the post as plain text, with a list of references at the end.
+//
+// The text of each link is followed by a reference number in square brackets, and the URIs are listed
+// at the end of the text. Links whose text is their URI, mentions, and tags don't get a reference.
+func (e *Exporter) PlainText(post *k3.Post) string {
+	return e.PlainTextThread([]*k3.Post{post})
+}
+
+// PlainTextThread renders the posts in the thread as plain text, separated by blank lines,
+// with a single list of references at the end.
+func (e *Exporter) PlainTextThread(posts []*k3.Post) string {
+	var refs []string
+	refNums := map[string]int{}
+	var out []string
+	for _, post := range posts {
+		sb := strings.Builder{}
+		for _, block := range post.Blocks {
+			sb.WriteString(block.Text)
+			if block.Link == nil || *block.Link == block.Text {
+				continue
+			}
+			num, found := refNums[*block.Link]
+			if !found {
+				refs = append(refs, *block.Link)
+				num = len(refs)
+				refNums[*block.Link] = num
+			}
+			fmt.Fprintf(&sb, "[%d]", num)
+		}
+		out = append(out, sb.String())
+	}
+	if len(refs) > 0 {
+		sb := strings.Builder{}
+		for i, ref := range refs {
+			if i > 0 {
+				sb.WriteString("\n")
+			}
+			fmt.Fprintf(&sb, "[%d] %s", i+1, ref)
+		}
+		out = append(out, sb.String())
+	}
+	return strings.Join(out, "\n\n")
+}
+
+// blockUrl returns the URL a block should link to, or an empty string if it shouldn't link anywhere.
+//
+// If a block has several features, links have priority over mentions, and mentions have priority over tags.
+// Links with schemes other than http and https, such as javascript: or data:, are not followed.
+func (e *Exporter) blockUrl(block k3.PostBlock) string {
+	if block.Link != nil {
+		if !isWebUrl(*block.Link) {
+			return ""
+		}
+		return *block.Link
+	}
+	if block.Mention != nil {
+		return e.profileUrl(*block.Mention)
+	}
+	if block.Tag != nil {
+		return e.tagUrl(*block.Tag)
+	}
+	return ""
+}
+
+// isWebUrl returns true if the URI uses the http or https schemes.
+func isWebUrl(uri string) bool {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	return scheme == "http" || scheme == "https"
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`, `<`, `\<`, `>`, `\>`,
+	`#`, `\#`, `~`, `\~`, `|`, `\|`)
+
+func escapeMarkdown(text string) string {
+	return markdownEscaper.Replace(text)
+}
+
+var markdownUrlEscaper = strings.NewReplacer(` `, `%20`, `(`, `%28`, `)`, `%29`)
+
+func markdownUrl(uri string) string {
+	return markdownUrlEscaper.Replace(uri)
+}
@@ -0,0 +1,110 @@
+package export_test
+
+import (
+	"testing"
+
+	"github.com/jtarrio/k3"
+	"github.com/jtarrio/k3/export"
+	"github.com/stretchr/testify/assert"
+)
+
+func samplePost() *k3.Post {
+	return k3.NewPost().
+		AddText("Read <the> ").AddLink("release notes", "https://example.com/notes?a=1&b=2").
+		AddText(" by ").AddMention("@alice", "did:plc:alice").
+		AddText("\nand ").AddTag("#go", "go").AddText(" *now*")
+}
+
+func TestHtml(t *testing.T) {
+	e := export.NewExporter()
+	assert.Equal(t,
+		`<p>Read &lt;the&gt; <a href="https://example.com/notes?a=1&amp;b=2">release notes</a>`+
+			` by <a href="https://bsky.app/profile/did:plc:alice">@alice</a><br>`+"\n"+
+			`and <a href="https://bsky.app/search?q=%23go">#go</a> *now*</p>`,
+		e.Html(samplePost()))
+}
+
+func TestHtmlThread(t *testing.T) {
+	e := export.NewExporter()
+	thread := []*k3.Post{k3.NewPost().AddText("One"), k3.NewPost().AddText("Two")}
+	assert.Equal(t, "<p>One</p>\n<p>Two</p>", e.HtmlThread(thread))
+}
+
+func TestMarkdown(t *testing.T) {
+	e := export.NewExporter()
+	assert.Equal(t,
+		`Read \<the\> [release notes](https://example.com/notes?a=1&b=2)`+
+			` by [@alice](https://bsky.app/profile/did:plc:alice)\`+"\n"+
+			`and [\#go](https://bsky.app/search?q=%23go) \*now\*`,
+		e.Markdown(samplePost()))
+}
+
+func TestMarkdownParagraphs(t *testing.T) {
+	e := export.NewExporter()
+	post := k3.NewPost().AddText("One\ntwo\n\nthree").AddLink("link", "https://example.com/a (b)")
+	assert.Equal(t, "One\\\ntwo\n\nthree[link](https://example.com/a%20%28b%29)", e.Markdown(post))
+}
+
+func TestMarkdownThread(t *testing.T) {
+	e := export.NewExporter()
+	thread := []*k3.Post{k3.NewPost().AddText("One"), k3.NewPost().AddText("Two")}
+	assert.Equal(t, "One\n\n---\n\nTwo", e.MarkdownThread(thread))
+}
+
+func TestPlainText(t *testing.T) {
+	e := export.NewExporter()
+	assert.Equal(t,
+		"Read <the> release notes[1] by @alice\nand #go *now*\n\n"+
+			"[1] https://example.com/notes?a=1&b=2",
+		e.PlainText(samplePost()))
+}
+
+func TestPlainTextThread(t *testing.T) {
+	e := export.NewExporter()
+	thread := []*k3.Post{
+		k3.NewPost().AddText("See ").AddLink("this", "https://example.com/1").AddText(" and ").AddLink("that", "https://example.com/2"),
+		k3.NewPost().AddText("Again ").AddLink("this", "https://example.com/1").AddText(" at ").AddLink("https://example.com/3", "https://example.com/3"),
+		k3.NewPost().AddText("No links"),
+	}
+	assert.Equal(t,
+		"See this[1] and that[2]\n\n"+
+			"Again this[1] at https://example.com/3\n\n"+
+			"No links\n\n"+
+			"[1] https://example.com/1\n"+
+			"[2] https://example.com/2",
+		e.PlainTextThread(thread))
+}
+
+func TestCustomUrls(t *testing.T) {
+	e := export.NewExporter(
+		export.WithProfileUrl(func(did string) string { return "https://example.com/u/" + did }),
+		export.WithTagUrl(func(tag string) string { return "https://example.com/t/" + tag }))
+	post := k3.NewPost().AddMention("@bob", "did:plc:bob").AddText(" ").AddTag("#k3", "k3")
+	assert.Equal(t,
+		`<p><a href="https://example.com/u/did:plc:bob">@bob</a> <a href="https://example.com/t/k3">#k3</a></p>`,
+		e.Html(post))
+}
+
+func TestFeaturePriority(t *testing.T) {
+	e := export.NewExporter()
+	post := k3.NewPost().AddBlock(k3.NewBlock("all", k3.WithTag("t"), k3.WithMention("did:plc:m"), k3.WithLink("https://example.com")))
+	assert.Equal(t, `<p><a href="https://example.com">all</a></p>`, e.Html(post))
+	post = k3.NewPost().AddBlock(k3.NewBlock("some", k3.WithTag("t"), k3.WithMention("did:plc:m")))
+	assert.Equal(t, `<p><a href="https://bsky.app/profile/did:plc:m">some</a></p>`, e.Html(post))
+}
+
+func TestCashtagUrl(t *testing.T) {
+	e := export.NewExporter()
+	post := k3.NewPost().AddCashtag("$aapl", "aapl")
+	assert.Equal(t, `<p><a href="https://bsky.app/search?q=%24AAPL">$aapl</a></p>`, e.Html(post))
+}
+
+func TestUnsafeLinks(t *testing.T) {
+	e := export.NewExporter()
+	post := k3.NewPost().
+		AddLink("click", "javascript:alert(1)").AddText(" ").
+		AddLink("image", "data:text/html,<script>alert(1)</script>").AddText(" ").
+		AddLink("web", "HTTP://example.com")
+	assert.Equal(t, `<p>click image <a href="HTTP://example.com">web</a></p>`, e.Html(post))
+	assert.Equal(t, `click image [web](HTTP://example.com)`, e.Markdown(post))
+}
@@ -0,0 +1,162 @@
+// Package fanout publishes the same posts from several accounts at the same time.
+//
+// Each account has its own client.Client and is published to through its own multiposter.Multiposter,
+// with a bound on the number of accounts that are published to concurrently. If some of the accounts fail,
+// Resume publishes the remaining posts only to those accounts.
+package fanout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/bluesky-social/indigo/api/bsky"
+	"github.com/jtarrio/k3/client"
+	"github.com/jtarrio/k3/multiposter"
+)
+
+// DefaultParallelism is the default maximum number of accounts that are published to at the same time.
+const DefaultParallelism = 4
+
+// New creates a new Fanout that publishes with the given clients, identified by account name.
+func New(clients map[string]client.Client, options ...FanoutOption) *Fanout {
+	f := &Fanout{
+		parallelism:    DefaultParallelism,
+		accountOptions: map[string][]multiposter.MultiposterOption{},
+		onResult:       func(string, *multiposter.PublishResult) {},
+	}
+	for _, option := range options {
+		option(f)
+	}
+	f.multiposters = map[string]multiposter.Multiposter{}
+	for account, c := range clients {
+		options := append(slices.Clone(f.multiposterOptions), f.accountOptions[account]...)
+		f.multiposters[account] = multiposter.New(c, options...)
+	}
+	return f
+}
+
+// WithParallelism sets the maximum number of accounts that are published to at the same time.
+func WithParallelism(parallelism int) FanoutOption {
+	return func(f *Fanout) {
+		f.parallelism = max(1, parallelism)
+	}
+}
+
+// WithMultiposterOptions sets the options for the multiposters used to publish to every account.
+func WithMultiposterOptions(options ...multiposter.MultiposterOption) FanoutOption {
+	return func(f *Fanout) {
+		f.multiposterOptions = options
+	}
+}
+
+// WithAccountOptions sets additional options for the multiposter used to publish to the given account.
+//
+// These options are applied after the ones set with WithMultiposterOptions. This is useful to give each
+// account its own rate limiter, journal or observer.
+func WithAccountOptions(account string, options ...multiposter.MultiposterOption) FanoutOption {
+	return func(f *Fanout) {
+		f.accountOptions[account] = options
+	}
+}
+
+// WithResultHandler sets a function that is called after publishing to each account.
+//
+// The function is called from several goroutines, but never more than once at the same time.
+func WithResultHandler(handler func(account string, result *multiposter.PublishResult)) FanoutOption {
+	return func(f *Fanout) {
+		f.onResult = handler
+	}
+}
+
+type FanoutOption func(*Fanout)
+
+// Fanout publishes the same posts from several accounts concurrently.
+type Fanout struct {
+	parallelism        int
+	multiposterOptions []multiposter.MultiposterOption
+	accountOptions     map[string][]multiposter.MultiposterOption
+	onResult           func(string, *multiposter.PublishResult)
+	multiposters       map[string]multiposter.Multiposter
+}
+
+// Result contains the result of a Publish or Resume operation for each account.
+type Result struct {
+	// Accounts contains the result of publishing to each account, by account name.
+	Accounts map[string]*multiposter.PublishResult
+}
+
+// Failed returns the names of the accounts that failed, in alphabetical order.
+func (r *Result) Failed() []string {
+	var out []string
+	for account, result := range r.Accounts {
+		if result.Error != nil {
+			out = append(out, account)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// Err returns an error that contains the errors of all the accounts that failed, or nil if none failed.
+func (r *Result) Err() error {
+	var errs []error
+	for _, account := range r.Failed() {
+		errs = append(errs, fmt.Errorf("account %s: %w", account, r.Accounts[account].Error))
+	}
+	return errors.Join(errs...)
+}
+
+// Publish publishes the posts to every account.
+func (f *Fanout) Publish(ctx context.Context, posts []*bsky.FeedPost) *Result {
+	return f.run(ctx, slices.Sorted(maps.Keys(f.multiposters)), func(ctx context.Context, m multiposter.Multiposter, account string) *multiposter.PublishResult {
+		return m.Publish(ctx, posts)
+	})
+}
+
+// Resume publishes the remaining posts to the accounts that failed in a previous call to Publish or Resume.
+//
+// The results of the accounts that succeeded are copied from the previous result.
+func (f *Fanout) Resume(ctx context.Context, previousResult *Result) *Result {
+	var failed []string
+	for _, account := range previousResult.Failed() {
+		if _, found := f.multiposters[account]; found {
+			failed = append(failed, account)
+		}
+	}
+	result := f.run(ctx, failed, func(ctx context.Context, m multiposter.Multiposter, account string) *multiposter.PublishResult {
+		return m.Resume(ctx, previousResult.Accounts[account])
+	})
+	for account, accountResult := range previousResult.Accounts {
+		if _, found := result.Accounts[account]; !found {
+			result.Accounts[account] = accountResult
+		}
+	}
+	return result
+}
+
+// run calls the publish function for each of the given accounts, with bounded parallelism.
+func (f *Fanout) run(ctx context.Context, accounts []string, publish func(context.Context, multiposter.Multiposter, string) *multiposter.PublishResult) *Result {
+	result := &Result{Accounts: map[string]*multiposter.PublishResult{}}
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, f.parallelism)
+	for _, account := range accounts {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			accountResult := publish(ctx, f.multiposters[account], account)
+			mutex.Lock()
+			defer mutex.Unlock()
+			result.Accounts[account] = accountResult
+			f.onResult(account, accountResult)
+		}()
+	}
+	wg.Wait()
+	return result
+}
@@ -0,0 +1,101 @@
+package fanout_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/bluesky-social/indigo/api/bsky"
+	"github.com/jtarrio/k3"
+	"github.com/jtarrio/k3/client"
+	"github.com/jtarrio/k3/fanout"
+	"github.com/jtarrio/k3/multiposter"
+	"github.com/jtarrio/k3/posts"
+	atptesting "github.com/jtarrio/k3/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getPosts(count int) []*bsky.FeedPost {
+	var out []*bsky.FeedPost
+	converter := posts.NewConverter()
+	for i := range count {
+		out = append(out, converter.ToFeedPost(k3.NewPost().AddText(fmt.Sprintf("Post #%d", i+1))))
+	}
+	return out
+}
+
+func TestPublishToAllAccounts(t *testing.T) {
+	clients := map[string]*atptesting.FakeClient{
+		"alice": atptesting.NewFakeClient(),
+		"bob":   atptesting.NewFakeClient().FailAfter(1),
+		"carol": atptesting.NewFakeClient(),
+	}
+	var handled []string
+	f := fanout.New(map[string]client.Client{"alice": clients["alice"], "bob": clients["bob"], "carol": clients["carol"]},
+		fanout.WithMultiposterOptions(multiposter.AsThread()),
+		fanout.WithResultHandler(func(account string, result *multiposter.PublishResult) {
+			handled = append(handled, account)
+		}))
+
+	result := f.Publish(context.Background(), getPosts(2))
+	assert.ElementsMatch(t, []string{"alice", "bob", "carol"}, handled)
+	assert.Equal(t, []string{"bob"}, result.Failed())
+	assert.ErrorIs(t, result.Err(), atptesting.ErrFakeClientFailure)
+	assert.EqualError(t, result.Err(), "account bob: failure posting")
+	assert.Len(t, result.Accounts["alice"].Published, 2)
+	assert.Len(t, result.Accounts["bob"].Published, 1)
+	assert.Equal(t, []string{"Post #1", "Post #2"}, clients["alice"].Texts())
+	assert.Equal(t, []string{"Post #1"}, clients["bob"].Texts())
+
+	// Only the failed account is published to again.
+	handled = nil
+	clients["bob"].FailAfter(-1)
+	result = f.Resume(context.Background(), result)
+	assert.Equal(t, []string{"bob"}, handled)
+	assert.Empty(t, result.Failed())
+	assert.NoError(t, result.Err())
+	for account, c := range clients {
+		assert.Len(t, result.Accounts[account].Published, 2)
+		assert.Equal(t, []string{"Post #1", "Post #2"}, c.Texts())
+	}
+	reply := clients["bob"].Posts()[1].Reply
+	require.NotNil(t, reply)
+	assert.Equal(t, "at://did:plc:me/app.bsky.feed.post/0", reply.Root.Uri)
+}
+
+func TestBoundedParallelism(t *testing.T) {
+	var mutex sync.Mutex
+	active, maxActive := 0, 0
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	block := func() {
+		mutex.Lock()
+		active++
+		maxActive = max(maxActive, active)
+		mutex.Unlock()
+		started <- struct{}{}
+		<-release
+		mutex.Lock()
+		active--
+		mutex.Unlock()
+	}
+	clients := map[string]client.Client{}
+	for i := range 5 {
+		clients[fmt.Sprintf("account%d", i)] = atptesting.NewFakeClient().OnPublish(block)
+	}
+	f := fanout.New(clients, fanout.WithParallelism(2))
+
+	done := make(chan *fanout.Result)
+	go func() { done <- f.Publish(context.Background(), getPosts(1)) }()
+	<-started
+	<-started
+	for range 5 {
+		release <- struct{}{}
+	}
+	result := <-done
+	assert.Empty(t, result.Failed())
+	assert.Len(t, result.Accounts, 5)
+	assert.Equal(t, 2, maxActive)
+}
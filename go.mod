@@ -2,7 +2,11 @@ module github.com/jtarrio/k3
 
 go 1.23.4
 
-require github.com/bluesky-social/indigo v0.0.0-20250308030553-89e09de2353e
+require (
+	github.com/bluesky-social/indigo v0.0.0-20250308030553-89e09de2353e
+	golang.org/x/text v0.23.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/beorn7/perks v1.0.1 // indirect
@@ -27,7 +31,6 @@ require (
 	go.opentelemetry.io/auto/sdk v1.1.0 // indirect
 	golang.org/x/time v0.3.0 // indirect
 	google.golang.org/protobuf v1.33.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
 require (
@@ -0,0 +1,215 @@
+package html
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// findMainContent returns the node that contains the main content of the document,
+// along with the set of its descendants that look like boilerplate and should be skipped.
+//
+// If the document contains <article> or <main> elements, the one with the most text is chosen.
+// Otherwise, the nodes are scored according to the amount of text they contain, the number of
+// links in the text, and their class names and IDs, and the node with the highest score is chosen.
+func findMainContent(doc *html.Node) (*html.Node, map[*html.Node]bool) {
+	e := &extractor{textLen: map[*html.Node]int{}, linkLen: map[*html.Node]int{}}
+	e.measure(doc, false)
+
+	var best *html.Node
+	walk(doc, func(n *html.Node) bool {
+		if isElement(n, "article", "main") || getAttr(n, "role") == "main" {
+			if best == nil || e.textLen[n] > e.textLen[best] {
+				best = n
+			}
+		}
+		return true
+	})
+	if best == nil || e.textLen[best] < minArticleLength {
+		best = e.bestScoring(doc)
+	}
+	if best == nil {
+		best = doc
+	}
+	return best, e.boilerplate(best)
+}
+
+// minArticleLength is the minimum number of characters that an <article> or <main> element must contain to be chosen.
+const minArticleLength = 100
+
+type extractor struct {
+	textLen map[*html.Node]int
+	linkLen map[*html.Node]int
+}
+
+// measure computes the length of the text and the length of the text inside links for each node.
+func (e *extractor) measure(n *html.Node, inLink bool) (int, int) {
+	switch n.Type {
+	case html.TextNode:
+		l := utf8.RuneCountInString(strings.Join(strings.Fields(n.Data), " "))
+		if inLink {
+			return l, l
+		}
+		return l, 0
+	case html.ElementNode:
+		if isElement(n, "script", "style", "head", "noscript", "template", "svg", "iframe") {
+			return 0, 0
+		}
+		inLink = inLink || n.Data == "a"
+	}
+	text, link := 0, 0
+	for s := n.FirstChild; s != nil; s = s.NextSibling {
+		t, l := e.measure(s, inLink)
+		text += t
+		link += l
+	}
+	e.textLen[n] = text
+	e.linkLen[n] = link
+	return text, link
+}
+
+func (e *extractor) linkDensity(n *html.Node) float64 {
+	if e.textLen[n] == 0 {
+		return 0
+	}
+	return float64(e.linkLen[n]) / float64(e.textLen[n])
+}
+
+// bestScoring scores the nodes that contain paragraphs, and returns the one with the highest score.
+func (e *extractor) bestScoring(doc *html.Node) *html.Node {
+	scores := map[*html.Node]float64{}
+	var candidates []*html.Node
+	addScore := func(n *html.Node, score float64) {
+		if n == nil || n.Type != html.ElementNode {
+			return
+		}
+		if _, found := scores[n]; !found {
+			scores[n] = initialScore(n)
+			candidates = append(candidates, n)
+		}
+		scores[n] += score
+	}
+	walk(doc, func(n *html.Node) bool {
+		if isElement(n, "script", "style", "head", "nav", "aside", "footer", "form") {
+			return false
+		}
+		if !isElement(n, "p", "pre", "td", "blockquote") {
+			return true
+		}
+		l := e.textLen[n] - e.linkLen[n]
+		if l < 25 {
+			return true
+		}
+		score := 1 + float64(strings.Count(innerText(n), ",")) + min(float64(l)/100, 3)
+		addScore(n.Parent, score)
+		if n.Parent != nil {
+			addScore(n.Parent.Parent, score/2)
+		}
+		return true
+	})
+	var best *html.Node
+	bestScore := 0.0
+	for _, n := range candidates {
+		score := scores[n] * (1 - e.linkDensity(n))
+		if best == nil || score > bestScore {
+			best = n
+			bestScore = score
+		}
+	}
+	return best
+}
+
+// boilerplate returns the set of descendants of the given node that don't look like part of the main content.
+func (e *extractor) boilerplate(root *html.Node) map[*html.Node]bool {
+	skip := map[*html.Node]bool{}
+	for s := root.FirstChild; s != nil; s = s.NextSibling {
+		walk(s, func(n *html.Node) bool {
+			if n.Type != html.ElementNode {
+				return true
+			}
+			if isElement(n, "nav", "aside", "footer", "form", "button", "dialog", "noscript") || classWeight(n) < 0 {
+				skip[n] = true
+				return false
+			}
+			if isElement(n, "div", "section", "ul", "ol", "table") && e.linkDensity(n) > 0.5 {
+				skip[n] = true
+				return false
+			}
+			return true
+		})
+	}
+	return skip
+}
+
+func initialScore(n *html.Node) float64 {
+	score := float64(classWeight(n))
+	switch n.Data {
+	case "article", "main":
+		score += 10
+	case "div", "section":
+		score += 5
+	case "pre", "td", "blockquote":
+		score += 3
+	case "ul", "ol", "dl", "dd", "dt", "li", "form", "address":
+		score -= 3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		score -= 5
+	}
+	return score
+}
+
+var positiveNames = regexp.MustCompile(`(?i)article|blog|body|content|entry|main|page|post|story|text`)
+var negativeNames = regexp.MustCompile(`(?i)-ad-|^ad-|advert|banner|breadcrumb|comment|consent|cookie|disqus|footer|gdpr|masthead|menu|modal|nav|newsletter|popup|promo|related|share|sidebar|social|sponsor|subscribe|widget`)
+
+// classWeight returns a positive value if the node's class or ID indicate main content,
+// and a negative value if they indicate boilerplate.
+func classWeight(n *html.Node) int {
+	weight := 0
+	for _, name := range []string{getAttr(n, "class"), getAttr(n, "id")} {
+		if len(name) == 0 {
+			continue
+		}
+		if negativeNames.MatchString(name) {
+			weight -= 25
+		}
+		if positiveNames.MatchString(name) {
+			weight += 25
+		}
+	}
+	return weight
+}
+
+func innerText(n *html.Node) string {
+	sb := strings.Builder{}
+	walk(n, func(n *html.Node) bool {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+		}
+		return true
+	})
+	return sb.String()
+}
+
+// walk calls fn for the given node and its descendants. If fn returns false, the node's descendants are not visited.
+func walk(n *html.Node, fn func(*html.Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for s := n.FirstChild; s != nil; s = s.NextSibling {
+		walk(s, fn)
+	}
+}
+
+func isElement(n *html.Node, tags ...string) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	for _, tag := range tags {
+		if n.Data == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -3,19 +3,30 @@
 // The importer parses the provided HTML code and applies some simple formatting,
 // converting links wherever found.
 //
-// Supported tags include <p>, <div>, <h1> through <h6>, <hr>, <ul>, <ol>, <li>, <pre>, and <br>.
+// Supported tags include <p>, <div>, <h1> through <h6>, <hr>, <ul>, <ol>, <li>, <pre>, <br>,
+// <blockquote>, <table>, <dl>, and <code>.
 //
 // Other tags are ignored but their content is still added to the post. Those include <b>, <i>, <span>, etc.
+// Optionally, the content of <b>, <strong>, <i>, <em>, and <code> can be styled using Unicode characters.
 //
 // Some tags are ignored and their content is not added to the post. Those include <script>, <link>, <iframe>, etc.
+//
+// The importer can also extract the main content of a web page, leaving out navigation menus, banners, footers, etc.
+//
+// The formatting rules can be changed with ImporterOptions. You can choose the strings used for list bullets,
+// horizontal rules, and quotes, override the handling of any tag, and ignore more elements using selectors.
 package html
 
 import (
 	"fmt"
+	"maps"
+	"net/url"
 	"strings"
 	"unicode"
 
 	"github.com/jtarrio/k3"
+	"github.com/jtarrio/k3/import/text"
+	"github.com/jtarrio/k3/styled"
 	"golang.org/x/net/html"
 )
 
@@ -25,32 +36,224 @@ type Importer interface {
 	Import(html string) (*k3.Post, error)
 }
 
-// NewImporter creates a new HTML importer.
-func NewImporter() Importer {
-	return &importer{}
+// NewImporter creates a new HTML importer with the given options.
+func NewImporter(options ...ImporterOption) Importer {
+	i := &importer{
+		tags:          maps.Clone(htmlTags),
+		bullet:        "* ",
+		rule:          "-----",
+		quotePrefix:   "> ",
+		codeDelimiter: "`",
+	}
+	for _, option := range options {
+		option(i)
+	}
+	return i
+}
+
+// WithTagHandler makes the importer use the given handler for the elements with the given tag name.
+//
+// This can be used to change how a supported tag is converted, or to add support for a new tag.
+func WithTagHandler(tag string, handler TagHandler) ImporterOption {
+	return func(i *importer) {
+		i.tags[strings.ToLower(tag)] = htmlTag{
+			preFn: func(c *converter, n *html.Node) {
+				if handler.Start != nil {
+					handler.Start(c, n)
+				}
+			},
+			postFn: func(c *converter, n *html.Node) {
+				if handler.End != nil {
+					handler.End(c, n)
+				}
+			},
+		}
+	}
+}
+
+// WithBullet sets the string that is added before each item of an unordered list.
+//
+// By default, the bullet is "* ".
+func WithBullet(bullet string) ImporterOption {
+	return func(i *importer) {
+		i.bullet = bullet
+	}
+}
+
+// WithRule sets the string that is used to represent a horizontal rule (<hr>).
+//
+// By default, the rule is "-----".
+func WithRule(rule string) ImporterOption {
+	return func(i *importer) {
+		i.rule = rule
+	}
+}
+
+// WithQuotePrefix sets the string that is added at the start of each line inside a <blockquote>.
+//
+// By default, the prefix is "> ".
+func WithQuotePrefix(prefix string) ImporterOption {
+	return func(i *importer) {
+		i.quotePrefix = prefix
+	}
 }
 
-type importer struct{}
+// WithCodeDelimiter sets the string that is added before and after the content of a <code> element.
+//
+// By default, the delimiter is "`". The delimiter is not added for <code> elements inside a <pre>.
+func WithCodeDelimiter(delimiter string) ImporterOption {
+	return func(i *importer) {
+		i.codeDelimiter = delimiter
+	}
+}
 
-func (*importer) Import(input string) (*k3.Post, error) {
+// WithIgnoredSelectors makes the importer ignore the elements matching any of the given selectors, along with their content.
+//
+// Selectors use a subset of the CSS syntax: they can contain a tag name, an ID (#id), classes (.class),
+// and attributes ([attr] or [attr=value]). Several selectors can be separated with commas.
+// For example: "nav, footer, div.cookie-banner, [role=navigation]".
+func WithIgnoredSelectors(selectors ...string) ImporterOption {
+	return func(i *importer) {
+		for _, s := range selectors {
+			parsed, err := parseSelectors(s)
+			if err != nil {
+				i.optionErr = err
+				continue
+			}
+			i.ignored = append(i.ignored, parsed...)
+		}
+	}
+}
+
+// WithUnicodeStyles makes the importer convert the content of <b>, <strong>, <i>, and <em> elements
+// into bold and italic Unicode characters, and the content of <code> elements into monospace characters.
+//
+// Characters that don't have a styled version are left unchanged. See package styled for more details.
+// You may want to use this option together with WithCodeDelimiter("") to avoid adding delimiters to monospace text.
+func WithUnicodeStyles() ImporterOption {
+	return func(i *importer) {
+		i.unicodeStyles = true
+	}
+}
+
+// WithMainContentExtraction makes the importer convert only the main content of the document.
+//
+// This is useful to import whole web pages, as it leaves out navigation menus, cookie banners, footers, etc.
+// If the document contains an <article> or <main> element, its content is used. Otherwise, the importer
+// chooses the element whose text has the most paragraphs and the fewest links.
+func WithMainContentExtraction() ImporterOption {
+	return func(i *importer) {
+		i.extractMain = true
+	}
+}
+
+// WithLinkCanonicalization makes the importer canonicalize the URLs of all absolute links.
+//
+// The URLs are processed with text.CanonicalizeUrl with the given options, which removes tracking parameters,
+// among other things. Relative links are left unchanged.
+func WithLinkCanonicalization(options ...text.CanonicalizerOption) ImporterOption {
+	return func(i *importer) {
+		i.canonicalize = func(uri string) string {
+			parsed, err := url.Parse(uri)
+			if err != nil || !parsed.IsAbs() {
+				return uri
+			}
+			return text.CanonicalizeUrl(parsed, options...).String()
+		}
+	}
+}
+
+// WithLanguageDetector makes the importer fill in the post's languages using the given detector.
+func WithLanguageDetector(d k3.LanguageDetector) ImporterOption {
+	return func(i *importer) {
+		i.languageDetector = d
+	}
+}
+
+// TagHandler specifies how to convert an HTML element.
+type TagHandler struct {
+	// Start is called before the element's children are converted.
+	Start func(w Writer, n *html.Node)
+	// End is called after the element's children are converted.
+	End func(w Writer, n *html.Node)
+}
+
+// Writer is an interface that TagHandlers use to add content to the post.
+type Writer interface {
+	// AddText adds some text to the post. Whitespace is collapsed, except inside <pre> elements.
+	AddText(text string)
+	// EndParagraph ends the current paragraph, so any further text starts on a new line.
+	EndParagraph()
+	// StartLink makes all text added until the matching EndLink call link to the given URI.
+	StartLink(uri string)
+	// EndLink finishes the link started with the latest StartLink call.
+	EndLink()
+	// StartIgnore makes the importer skip all text added until the matching EndIgnore call.
+	StartIgnore()
+	// EndIgnore finishes the section started with the latest StartIgnore call.
+	EndIgnore()
+	// PushPrefix adds a string that will be added at the start of every new line.
+	PushPrefix(prefix string)
+	// PopPrefix removes the latest prefix added with PushPrefix.
+	PopPrefix()
+}
+
+type ImporterOption func(*importer)
+
+type importer struct {
+	tags             map[string]htmlTag
+	ignored          []selector
+	bullet           string
+	rule             string
+	quotePrefix      string
+	codeDelimiter    string
+	unicodeStyles    bool
+	extractMain      bool
+	canonicalize     func(string) string
+	languageDetector k3.LanguageDetector
+	optionErr        error
+}
+
+func (i *importer) Import(input string) (*k3.Post, error) {
+	if i.optionErr != nil {
+		return nil, i.optionErr
+	}
 	doc, err := html.Parse(strings.NewReader(input))
 	if err != nil {
 		return nil, &HtmlParseError{err}
 	}
 
-	conv := &converter{post: k3.NewPost()}
-	conv.convert(doc)
+	conv := i.newConverter()
+	root := doc
+	if i.extractMain {
+		root, conv.skip = findMainContent(doc)
+	}
+	conv.convert(root)
+	if i.languageDetector != nil {
+		conv.post.DetectLanguages(i.languageDetector)
+	}
 	return conv.post, nil
 }
 
+func (i *importer) newConverter() *converter {
+	return &converter{importer: i, post: k3.NewPost()}
+}
+
 type converter struct {
+	*importer
 	post       *k3.Post
 	inPara     bool
 	inPre      bool
+	inCode     int
+	bold       int
+	italic     int
+	monospace  int
 	wantSpace  bool
 	linkTarget []string
 	listIndex  []int
+	prefixes   []string
 	ignore     int
+	skip       map[*html.Node]bool
 }
 
 func (c *converter) convert(n *html.Node) {
@@ -58,7 +261,15 @@ func (c *converter) convert(n *html.Node) {
 	case html.TextNode:
 		c.addText(n.Data)
 	case html.ElementNode:
-		tag, ok := htmlTags[n.Data]
+		if c.skip[n] {
+			return
+		}
+		for _, s := range c.ignored {
+			if s.matches(n) {
+				return
+			}
+		}
+		tag, ok := c.tags[n.Data]
 		if !ok {
 			tag = htmlTag{doNothing, doNothing}
 		}
@@ -75,29 +286,39 @@ func (c *converter) convert(n *html.Node) {
 }
 
 var htmlTags = map[string]htmlTag{
-	"a":      {startAnchor, endAnchor},
-	"br":     {paragraphBoundary, doNothing},
-	"p":      {paragraphBoundary, paragraphBoundary},
-	"div":    {paragraphBoundary, paragraphBoundary},
-	"h1":     {paragraphBoundary, paragraphBoundary},
-	"h2":     {paragraphBoundary, paragraphBoundary},
-	"h3":     {paragraphBoundary, paragraphBoundary},
-	"h4":     {paragraphBoundary, paragraphBoundary},
-	"h5":     {paragraphBoundary, paragraphBoundary},
-	"h6":     {paragraphBoundary, paragraphBoundary},
-	"pre":    {startPre, endPre},
-	"hr":     {startHr, doNothing},
-	"ol":     {startOl, endList},
-	"ul":     {startUl, endList},
-	"li":     {startLi, paragraphBoundary},
-	"head":   {startIgnore, endIgnore},
-	"script": {startIgnore, endIgnore},
-	"applet": {startIgnore, endIgnore},
-	"object": {startIgnore, endIgnore},
-	"svg":    {startIgnore, endIgnore},
-	"style":  {startIgnore, endIgnore},
-	"link":   {startIgnore, endIgnore},
-	"iframe": {startIgnore, endIgnore},
+	"a":          {startAnchor, endAnchor},
+	"br":         {paragraphBoundary, doNothing},
+	"p":          {paragraphBoundary, paragraphBoundary},
+	"div":        {paragraphBoundary, paragraphBoundary},
+	"h1":         {paragraphBoundary, paragraphBoundary},
+	"h2":         {paragraphBoundary, paragraphBoundary},
+	"h3":         {paragraphBoundary, paragraphBoundary},
+	"h4":         {paragraphBoundary, paragraphBoundary},
+	"h5":         {paragraphBoundary, paragraphBoundary},
+	"h6":         {paragraphBoundary, paragraphBoundary},
+	"pre":        {startPre, endPre},
+	"code":       {startCode, endCode},
+	"b":          {startBold, endBold},
+	"strong":     {startBold, endBold},
+	"i":          {startItalic, endItalic},
+	"em":         {startItalic, endItalic},
+	"hr":         {startHr, doNothing},
+	"blockquote": {startBlockquote, endPrefixed},
+	"ol":         {startOl, endList},
+	"ul":         {startUl, endList},
+	"li":         {startLi, paragraphBoundary},
+	"dl":         {paragraphBoundary, paragraphBoundary},
+	"dt":         {paragraphBoundary, paragraphBoundary},
+	"dd":         {startDd, endPrefixed},
+	"table":      {startTable, endTable},
+	"head":       {startIgnore, endIgnore},
+	"script":     {startIgnore, endIgnore},
+	"applet":     {startIgnore, endIgnore},
+	"object":     {startIgnore, endIgnore},
+	"svg":        {startIgnore, endIgnore},
+	"style":      {startIgnore, endIgnore},
+	"link":       {startIgnore, endIgnore},
+	"iframe":     {startIgnore, endIgnore},
 }
 
 type htmlTag struct {
@@ -123,17 +344,7 @@ func endIgnore(c *converter, _ *html.Node) {
 }
 
 func startAnchor(c *converter, n *html.Node) {
-	if c.inPara && c.wantSpace {
-		c.addTextBlock(" ")
-		c.wantSpace = false
-	}
-	for _, a := range n.Attr {
-		if a.Key == "href" {
-			c.linkTarget = append(c.linkTarget, a.Val)
-			return
-		}
-	}
-	c.linkTarget = append(c.linkTarget, "")
+	c.StartLink(getAttr(n, "href"))
 }
 
 func endAnchor(c *converter, _ *html.Node) {
@@ -152,26 +363,85 @@ func endPre(c *converter, n *html.Node) {
 	c.inPre = false
 }
 
+func startCode(c *converter, _ *html.Node) {
+	c.monospace++
+	if c.inPre {
+		return
+	}
+	c.startText()
+	c.addTextBlock(c.codeDelimiter)
+	c.inCode++
+}
+
+func endCode(c *converter, _ *html.Node) {
+	if c.monospace > 0 {
+		c.monospace--
+	}
+	if c.inPre || c.inCode == 0 {
+		return
+	}
+	c.inCode--
+	c.addTextBlock(c.codeDelimiter)
+}
+
+func startBold(c *converter, _ *html.Node) {
+	c.bold++
+}
+
+func endBold(c *converter, _ *html.Node) {
+	if c.bold > 0 {
+		c.bold--
+	}
+}
+
+func startItalic(c *converter, _ *html.Node) {
+	c.italic++
+}
+
+func endItalic(c *converter, _ *html.Node) {
+	if c.italic > 0 {
+		c.italic--
+	}
+}
+
 func startHr(c *converter, n *html.Node) {
 	paragraphBoundary(c, n)
-	c.addText(`-----`)
+	c.addText(c.rule)
+	paragraphBoundary(c, n)
+}
+
+func startBlockquote(c *converter, n *html.Node) {
+	paragraphBoundary(c, n)
+	c.prefixes = append(c.prefixes, c.quotePrefix)
+}
+
+func startDd(c *converter, n *html.Node) {
+	paragraphBoundary(c, n)
+	c.prefixes = append(c.prefixes, "  ")
+}
+
+func endPrefixed(c *converter, n *html.Node) {
 	paragraphBoundary(c, n)
+	c.PopPrefix()
 }
 
 func startUl(c *converter, n *html.Node) {
 	paragraphBoundary(c, n)
 	c.listIndex = append(c.listIndex, 0)
+	c.prefixes = append(c.prefixes, "  ")
 }
 
 func startOl(c *converter, n *html.Node) {
 	paragraphBoundary(c, n)
 	c.listIndex = append(c.listIndex, 1)
+	c.prefixes = append(c.prefixes, "  ")
 }
 
 func endList(c *converter, n *html.Node) {
 	paragraphBoundary(c, n)
 	if len(c.listIndex) > 0 {
 		c.listIndex = c.listIndex[:len(c.listIndex)-1]
+		c.PopPrefix()
 	}
 }
 
@@ -180,7 +450,7 @@ func startLi(c *converter, n *html.Node) {
 	if len(c.listIndex) > 0 {
 		idx := c.listIndex[len(c.listIndex)-1]
 		if idx == 0 {
-			c.addText("* ")
+			c.addText(c.bullet)
 		} else {
 			c.addText(fmt.Sprintf("%d. ", idx))
 			c.listIndex[len(c.listIndex)-1] = idx + 1
@@ -192,11 +462,29 @@ func (c *converter) addText(txt string) {
 	if c.inPre {
 		if !c.inPara {
 			c.addTextBlock("\n")
+			c.addTextBlock(c.linePrefix())
 			c.inPara = true
 		}
+		txt = c.applyStyle(txt)
+		if prefix := c.linePrefix(); len(prefix) > 0 {
+			txt = strings.ReplaceAll(txt, "\n", "\n"+prefix)
+		}
 		c.addTextBlock(txt)
 		return
 	}
+	if c.inCode > 0 {
+		txt = strings.Map(func(r rune) rune {
+			if unicode.IsSpace(r) {
+				return ' '
+			}
+			return r
+		}, txt)
+		if len(txt) > 0 {
+			c.startText()
+			c.addTextBlock(c.applyStyle(txt))
+		}
+		return
+	}
 	const zeroWidthSpace rune = '\u200b'
 	for _, r := range txt {
 		if unicode.IsSpace(r) {
@@ -204,32 +492,91 @@ func (c *converter) addText(txt string) {
 				c.wantSpace = true
 			}
 		} else {
-			if !c.inPara {
-				if len(c.post.Blocks) > 0 {
-					c.addTextBlock("\n")
-				}
-				if len(c.listIndex) > 0 {
-					c.addTextBlock(strings.Repeat("  ", len(c.listIndex)))
-				}
-			} else if c.wantSpace {
-				c.addTextBlock(" ")
-				c.wantSpace = false
-			}
-			c.addTextBlock(string([]rune{r}))
-			c.inPara = true
+			c.startText()
+			c.addTextBlock(c.applyStyle(string([]rune{r})))
 		}
 	}
 }
 
+// startText prepares the post to receive more text, starting a new line or adding a space if needed.
+func (c *converter) startText() {
+	if !c.inPara {
+		if len(c.post.Blocks) > 0 {
+			c.addTextBlock("\n")
+		}
+		c.addTextBlock(c.linePrefix())
+		c.inPara = true
+	} else if c.wantSpace {
+		c.addTextBlock(" ")
+		c.wantSpace = false
+	}
+}
+
+func (c *converter) applyStyle(txt string) string {
+	if !c.unicodeStyles {
+		return txt
+	}
+	return styled.Apply(txt, styled.StyleOf(c.bold > 0, c.italic > 0, c.monospace > 0))
+}
+
+func (c *converter) linePrefix() string {
+	return strings.Join(c.prefixes, "")
+}
+
 func (c *converter) addTextBlock(txt string) {
-	if c.ignore > 0 {
+	if len(c.linkTarget) > 0 {
+		c.addBlock(k3.NewBlock(txt, k3.WithLink(c.linkTarget[len(c.linkTarget)-1])))
 		return
 	}
-	if len(c.linkTarget) > 0 {
-		c.post.AddLink(txt, c.linkTarget[len(c.linkTarget)-1])
+	c.addBlock(k3.NewBlock(txt))
+}
+
+func (c *converter) addBlock(block k3.PostBlock) {
+	if c.ignore > 0 {
 		return
 	}
-	c.post.AddText(txt)
+	c.post.AddBlock(block)
+}
+
+func (c *converter) AddText(text string) {
+	c.addText(text)
+}
+
+func (c *converter) EndParagraph() {
+	paragraphBoundary(c, nil)
+}
+
+func (c *converter) StartLink(uri string) {
+	if c.inPara && c.wantSpace {
+		c.addTextBlock(" ")
+		c.wantSpace = false
+	}
+	if c.canonicalize != nil && len(uri) > 0 {
+		uri = c.canonicalize(uri)
+	}
+	c.linkTarget = append(c.linkTarget, uri)
+}
+
+func (c *converter) EndLink() {
+	endAnchor(c, nil)
+}
+
+func (c *converter) StartIgnore() {
+	startIgnore(c, nil)
+}
+
+func (c *converter) EndIgnore() {
+	endIgnore(c, nil)
+}
+
+func (c *converter) PushPrefix(prefix string) {
+	c.prefixes = append(c.prefixes, prefix)
+}
+
+func (c *converter) PopPrefix() {
+	if len(c.prefixes) > 0 {
+		c.prefixes = c.prefixes[:len(c.prefixes)-1]
+	}
 }
 
 type HtmlParseError struct {
@@ -1,11 +1,18 @@
 package html_test
 
 import (
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/jtarrio/k3"
 	"github.com/jtarrio/k3/import/html"
+	"github.com/jtarrio/k3/import/text"
+	"github.com/jtarrio/k3/langdetect"
+	"github.com/jtarrio/k3/posts"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gohtml "golang.org/x/net/html"
 )
 
 func TestEasyText(t *testing.T) {
@@ -180,3 +187,212 @@ done.`)
 Several levels of ignoring done.`)
 	assert.Equal(t, expected, post)
 }
+
+func TestHtmlWithBlockquotes(t *testing.T) {
+	post, err := html.NewImporter().Import(`
+<p>Somebody said:</p>
+<blockquote><p>En esto, descubrieron treinta o cuarenta molinos de viento.</p>
+<p>Y así como don Quijote los vio, dijo a su escudero:</p>
+<blockquote>La ventura va guiando nuestras cosas.</blockquote>
+</blockquote>
+<p>The end.</p>`)
+	assert.NoError(t, err)
+	expected := k3.NewPost().AddText(`Somebody said:
+> En esto, descubrieron treinta o cuarenta molinos de viento.
+> Y así como don Quijote los vio, dijo a su escudero:
+> > La ventura va guiando nuestras cosas.
+The end.`)
+	assert.Equal(t, expected, post)
+}
+
+func TestHtmlWithTables(t *testing.T) {
+	post, err := html.NewImporter().Import(`
+<p>Some people:</p>
+<table>
+<thead><tr><th>Name</th><th>Role</th><th>Age</th></tr></thead>
+<tbody>
+<tr><td>Don Quijote</td><td><a href="url1">Knight</a></td><td>50</td></tr>
+<tr><td>Sancho</td><td>Squire
+</td><td>40</td></tr>
+</tbody>
+</table>
+<p>The end.</p>`)
+	assert.NoError(t, err)
+	expected := k3.NewPost().AddText(`Some people:
+Name        | Role   | Age
+------------|--------|----
+Don Quijote | `).AddLink(`Knight`, `url1`).AddText(` | 50
+Sancho      | Squire | 40
+The end.`)
+	assert.Equal(t, expected, post)
+}
+
+func TestHtmlWithDefinitionLists(t *testing.T) {
+	post, err := html.NewImporter().Import(`
+<dl>
+<dt>Rocinante</dt><dd>Don Quijote's horse.</dd>
+<dt>Rucio</dt><dd>Sancho's donkey.</dd>
+</dl>`)
+	assert.NoError(t, err)
+	expected := k3.NewPost().AddText(`Rocinante
+  Don Quijote's horse.
+Rucio
+  Sancho's donkey.`)
+	assert.Equal(t, expected, post)
+}
+
+func TestHtmlWithCode(t *testing.T) {
+	post, err := html.NewImporter().Import(`
+<p>Call <code>post.AddText(  "hello"  )</code> to add text.</p>
+<pre><code>if x {
+  y()
+}</code></pre>`)
+	assert.NoError(t, err)
+	expected := k3.NewPost().AddText("Call `post.AddText(  \"hello\"  )` to add text.\nif x {\n  y()\n}")
+	assert.Equal(t, expected, post)
+}
+
+func TestFormattingOptions(t *testing.T) {
+	importer := html.NewImporter(
+		html.WithBullet("• "),
+		html.WithRule("~~~"),
+		html.WithQuotePrefix("| "),
+		html.WithCodeDelimiter(""))
+	post, err := importer.Import(`
+<ul><li>One</li><li>Two</li></ul>
+<hr>
+<blockquote>Quoted <code>code</code></blockquote>`)
+	assert.NoError(t, err)
+	expected := k3.NewPost().AddText(`  • One
+  • Two
+~~~
+| Quoted code`)
+	assert.Equal(t, expected, post)
+}
+
+func TestCustomTagHandler(t *testing.T) {
+	importer := html.NewImporter(
+		html.WithTagHandler("h1", html.TagHandler{
+			Start: func(w html.Writer, n *gohtml.Node) {
+				w.EndParagraph()
+				w.AddText("== ")
+			},
+			End: func(w html.Writer, n *gohtml.Node) {
+				w.AddText(" ==")
+				w.EndParagraph()
+			},
+		}),
+		html.WithTagHandler("x-user", html.TagHandler{
+			Start: func(w html.Writer, n *gohtml.Node) {
+				w.StartLink("https://bsky.app/profile/" + n.Attr[0].Val)
+			},
+			End: func(w html.Writer, n *gohtml.Node) {
+				w.EndLink()
+			},
+		}))
+	post, err := importer.Import(`<h1>Title</h1><p>Written by <x-user handle="jacobo.tarrio.org">Jacobo</x-user>.</p>`)
+	assert.NoError(t, err)
+	expected := k3.NewPost().AddText(`== Title ==
+Written by `).AddLink(`Jacobo`, `https://bsky.app/profile/jacobo.tarrio.org`).AddText(`.`)
+	assert.Equal(t, expected, post)
+}
+
+func TestIgnoredSelectors(t *testing.T) {
+	importer := html.NewImporter(html.WithIgnoredSelectors("nav, footer", "div.cookies", "#sidebar", "[role=banner]"))
+	post, err := importer.Import(`
+<div role="banner">Site name</div>
+<nav><a href="/">Home</a> <a href="/about">About</a></nav>
+<div class="popup cookies">We use cookies.</div>
+<div class="content">The content.</div>
+<div id="sidebar">Other things.</div>
+<footer>Copyright.</footer>`)
+	assert.NoError(t, err)
+	expected := k3.NewPost().AddText(`The content.`)
+	assert.Equal(t, expected, post)
+}
+
+func TestInvalidSelector(t *testing.T) {
+	_, err := html.NewImporter(html.WithIgnoredSelectors("div > p")).Import(`<p>Text</p>`)
+	var selectorErr *html.SelectorParseError
+	assert.ErrorAs(t, err, &selectorErr)
+}
+
+func TestUnicodeStyles(t *testing.T) {
+	importer := html.NewImporter(html.WithUnicodeStyles(), html.WithCodeDelimiter(""))
+	post, err := importer.Import(`
+<p>Some <b>bold</b>, <i>italic</i>, <strong>bold <em>italic</em></strong> and <code>code</code> text.</p>
+<p>A <a href="url1"><b>bold link</b></a> and <b>ñandú 123</b>.</p>
+<pre><code>x := 1</code></pre>`)
+	assert.NoError(t, err)
+	expected := k3.NewPost().AddText(`Some 𝐛𝐨𝐥𝐝, 𝑖𝑡𝑎𝑙𝑖𝑐, 𝐛𝐨𝐥𝐝 𝒊𝒕𝒂𝒍𝒊𝒄 and 𝚌𝚘𝚍𝚎 text.
+A `).AddLink(`𝐛𝐨𝐥𝐝 𝐥𝐢𝐧𝐤`, `url1`).AddText(` and ñ𝐚𝐧𝐝ú 𝟏𝟐𝟑.
+𝚡 := 𝟷`)
+	assert.Equal(t, expected, post)
+}
+
+func TestUnicodeStylesAndSplit(t *testing.T) {
+	importer := html.NewImporter(html.WithUnicodeStyles())
+	post, err := importer.Import(`<b>` + strings.Repeat(`En esto, descubrieron treinta o cuarenta molinos de viento. `, 10) + `</b>`)
+	assert.NoError(t, err)
+	assert.Equal(t, 599, post.GetGraphemeLength())
+	split := posts.Split(post)
+	assert.Len(t, split, 3)
+	for _, part := range split {
+		assert.LessOrEqual(t, part.GetGraphemeLength(), 300)
+		assert.NotContains(t, part.GetPlainText(), "�")
+	}
+}
+
+func TestMainContentFromArticle(t *testing.T) {
+	doc, err := os.ReadFile("testdata/article.html")
+	require.NoError(t, err)
+	post, err := html.NewImporter(html.WithMainContentExtraction()).Import(string(doc))
+	assert.NoError(t, err)
+	expected := k3.NewPost().AddText(`Windmills of La Mancha
+En esto, descubrieron treinta o cuarenta molinos de viento que hay en aquel campo, y así como don Quijote los vio, dijo a su escudero:
+—La ventura va guiando nuestras cosas mejor de lo que acertáramos a desear; porque ves allí, amigo Sancho Panza, donde se descubren treinta, o pocos más, desaforados gigantes.
+—¿Qué gigantes? —dijo `).AddLink(`Sancho Panza`, `https://example.com/sancho`).AddText(`.`)
+	assert.Equal(t, expected, post)
+}
+
+func TestMainContentByScore(t *testing.T) {
+	doc, err := os.ReadFile("testdata/divs.html")
+	require.NoError(t, err)
+	post, err := html.NewImporter(html.WithMainContentExtraction()).Import(string(doc))
+	assert.NoError(t, err)
+	expected := k3.NewPost().AddText(`Windmills of La Mancha
+En esto, descubrieron treinta o cuarenta molinos de viento que hay en aquel campo, y así como don Quijote los vio, dijo a su escudero:
+—La ventura va guiando nuestras cosas mejor de lo que acertáramos a desear; porque ves allí, amigo Sancho Panza, donde se descubren treinta, o pocos más, desaforados gigantes.
+—¿Qué gigantes? —dijo Sancho Panza.`)
+	assert.Equal(t, expected, post)
+}
+
+func TestNoMainContentExtractionByDefault(t *testing.T) {
+	doc, err := os.ReadFile("testdata/article.html")
+	require.NoError(t, err)
+	post, err := html.NewImporter().Import(string(doc))
+	assert.NoError(t, err)
+	assert.Contains(t, post.GetPlainText(), "We use cookies")
+	assert.Contains(t, post.GetPlainText(), "Copyright 2025")
+}
+
+func TestLinkCanonicalization(t *testing.T) {
+	importer := html.NewImporter(html.WithLinkCanonicalization(text.WithRedirectUnwrapping()))
+	post, err := importer.Import(`
+<a href="HTTPS://Example.com:443/foo?utm_source=x&amp;a=1">One</a>
+<a href="https://www.google.com/url?q=https%3A%2F%2Fexample.net%2F%3Ffbclid%3D1">two</a>
+<a href="/relative?utm_source=x">three</a>`)
+	assert.NoError(t, err)
+	expected := k3.NewPost().
+		AddLink(`One`, `https://example.com/foo?a=1`).AddText(` `).
+		AddLink(`two`, `https://example.net/`).AddText(` `).
+		AddLink(`three`, `/relative?utm_source=x`)
+	assert.Equal(t, expected, post)
+}
+
+func TestLanguageDetection(t *testing.T) {
+	importer := html.NewImporter(html.WithLanguageDetector(langdetect.New()))
+	post, err := importer.Import(`<p>Heute ist das Wetter sehr schön, und wir gehen in den <a href="https://example.com/park">Park</a>.</p>`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"de"}, post.Languages)
+}
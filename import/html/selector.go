@@ -0,0 +1,150 @@
+package html
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// selector is a simple CSS selector that can match a tag name, an ID, classes, and attributes.
+type selector struct {
+	tag     string
+	id      string
+	classes []string
+	attrs   []attrSelector
+}
+
+type attrSelector struct {
+	key      string
+	value    string
+	hasValue bool
+}
+
+// parseSelectors parses a comma-separated list of selectors.
+func parseSelectors(input string) ([]selector, error) {
+	var out []selector
+	for _, part := range strings.Split(input, ",") {
+		s, err := parseSelector(strings.TrimSpace(part))
+		if err != nil {
+			return nil, &SelectorParseError{Selector: input, Reason: err.Error()}
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+func parseSelector(input string) (selector, error) {
+	var s selector
+	if len(input) == 0 {
+		return s, fmt.Errorf("empty selector")
+	}
+	name, rest := cutName(input)
+	if name != "*" {
+		s.tag = strings.ToLower(name)
+	}
+	for len(rest) > 0 {
+		switch rest[0] {
+		case '#':
+			name, rest = cutName(rest[1:])
+			if len(name) == 0 {
+				return s, fmt.Errorf("missing ID after '#'")
+			}
+			s.id = name
+		case '.':
+			name, rest = cutName(rest[1:])
+			if len(name) == 0 {
+				return s, fmt.Errorf("missing class name after '.'")
+			}
+			s.classes = append(s.classes, name)
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return s, fmt.Errorf("missing ']'")
+			}
+			attr := attrSelector{key: strings.TrimSpace(rest[1:end])}
+			if key, value, found := strings.Cut(attr.key, "="); found {
+				attr.key = strings.TrimSpace(key)
+				attr.value = strings.Trim(strings.TrimSpace(value), `"'`)
+				attr.hasValue = true
+			}
+			if len(attr.key) == 0 {
+				return s, fmt.Errorf("missing attribute name")
+			}
+			attr.key = strings.ToLower(attr.key)
+			s.attrs = append(s.attrs, attr)
+			rest = rest[end+1:]
+		default:
+			return s, fmt.Errorf("unexpected character '%c'", rest[0])
+		}
+	}
+	return s, nil
+}
+
+func cutName(input string) (string, string) {
+	if strings.HasPrefix(input, "*") {
+		return "*", input[1:]
+	}
+	end := strings.IndexFunc(input, func(r rune) bool {
+		return !(r == '-' || r == '_' || r >= '0' && r <= '9' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= 0x80)
+	})
+	if end < 0 {
+		end = len(input)
+	}
+	return input[:end], input[end:]
+}
+
+func (s selector) matches(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	if len(s.tag) > 0 && s.tag != n.Data {
+		return false
+	}
+	if len(s.id) > 0 && getAttr(n, "id") != s.id {
+		return false
+	}
+	if len(s.classes) > 0 {
+		classes := strings.Fields(getAttr(n, "class"))
+		for _, class := range s.classes {
+			if !slices.Contains(classes, class) {
+				return false
+			}
+		}
+	}
+	for _, attr := range s.attrs {
+		found := false
+		for _, a := range n.Attr {
+			if a.Key == attr.key && (!attr.hasValue || a.Val == attr.value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func getAttr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// SelectorParseError is returned by Import if one of the selectors passed to WithIgnoredSelectors is not valid.
+type SelectorParseError struct {
+	// Selector is the selector that could not be parsed.
+	Selector string
+	// Reason describes the problem with the selector.
+	Reason string
+}
+
+func (e *SelectorParseError) Error() string {
+	return fmt.Sprintf("invalid selector '%s': %s", e.Selector, e.Reason)
+}
@@ -0,0 +1,110 @@
+package html
+
+import (
+	"strings"
+
+	"github.com/jtarrio/k3"
+	"golang.org/x/net/html"
+)
+
+// startTable renders the whole table as aligned text, and then ignores the table's children.
+func startTable(c *converter, n *html.Node) {
+	paragraphBoundary(c, n)
+	rows, header := c.tableRows(n)
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], cell.GetGraphemeLength())
+		}
+	}
+	for r, row := range rows {
+		c.startText()
+		for i, cell := range row {
+			if i > 0 {
+				c.addTextBlock(" | ")
+			}
+			for _, block := range cell.Blocks {
+				c.addBlock(block)
+			}
+			if i < len(row)-1 {
+				c.addTextBlock(strings.Repeat(" ", widths[i]-cell.GetGraphemeLength()))
+			}
+		}
+		paragraphBoundary(c, n)
+		if r == 0 && header {
+			c.startText()
+			for i := range len(row) {
+				if i > 0 {
+					c.addTextBlock("-|-")
+				}
+				c.addTextBlock(strings.Repeat("-", widths[i]))
+			}
+			paragraphBoundary(c, n)
+		}
+	}
+	startIgnore(c, n)
+}
+
+func endTable(c *converter, n *html.Node) {
+	endIgnore(c, n)
+	paragraphBoundary(c, n)
+}
+
+// tableRows returns the converted content of each cell in the table, and whether the first row is a header.
+func (c *converter) tableRows(table *html.Node) ([][]*k3.Post, bool) {
+	var rows [][]*k3.Post
+	header := false
+	var addRow func(n *html.Node)
+	addRow = func(n *html.Node) {
+		var row []*k3.Post
+		allHeaders := true
+		for cell := n.FirstChild; cell != nil; cell = cell.NextSibling {
+			if cell.Type != html.ElementNode || (cell.Data != "td" && cell.Data != "th") {
+				continue
+			}
+			allHeaders = allHeaders && cell.Data == "th"
+			row = append(row, c.tableCell(cell))
+		}
+		if len(row) == 0 {
+			return
+		}
+		if len(rows) == 0 {
+			header = allHeaders
+		}
+		rows = append(rows, row)
+	}
+	for s := table.FirstChild; s != nil; s = s.NextSibling {
+		if s.Type != html.ElementNode {
+			continue
+		}
+		switch s.Data {
+		case "tr":
+			addRow(s)
+		case "thead", "tbody", "tfoot":
+			for r := s.FirstChild; r != nil; r = r.NextSibling {
+				if r.Type == html.ElementNode && r.Data == "tr" {
+					addRow(r)
+				}
+			}
+		}
+	}
+	return rows, header
+}
+
+// tableCell converts the content of a table cell into a single line of text.
+func (c *converter) tableCell(cell *html.Node) *k3.Post {
+	conv := c.newConverter()
+	conv.skip = c.skip
+	for s := cell.FirstChild; s != nil; s = s.NextSibling {
+		conv.convert(s)
+	}
+	out := k3.NewPost()
+	for _, block := range conv.post.Blocks {
+		block.Text = strings.ReplaceAll(block.Text, "\n", " ")
+		out.AddBlock(block)
+	}
+	return out
+}
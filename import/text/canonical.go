@@ -0,0 +1,193 @@
+package text
+
+import (
+	"net/url"
+	"strings"
+)
+
+// CanonicalUrlResolver returns a UrlResolver that parses URLs with the given resolver and then canonicalizes them
+// using CanonicalizeUrl with the given options.
+//
+// If the given resolver is nil, DefaultUrlResolver is used.
+func CanonicalUrlResolver(resolver UrlResolver, options ...CanonicalizerOption) UrlResolver {
+	if resolver == nil {
+		resolver = DefaultUrlResolver
+	}
+	return func(u string) *url.URL {
+		parsed := resolver(u)
+		if parsed == nil {
+			return nil
+		}
+		return CanonicalizeUrl(parsed, options...)
+	}
+}
+
+// CanonicalizeUrl returns a canonical version of the given URL.
+//
+// The scheme and hostname are converted to lowercase, the port number is removed if it's the default for the
+// scheme, and any tracking parameters are removed from the query string. Optionally, URLs of known redirectors
+// (like https://www.google.com/url?q=...) are replaced with the URL they redirect to.
+//
+// By default, the parameters listed in DefaultTrackingParameters are removed and redirectors are left alone.
+func CanonicalizeUrl(u *url.URL, options ...CanonicalizerOption) *url.URL {
+	c := &canonicalizer{trackingParameters: DefaultTrackingParameters}
+	for _, option := range options {
+		option(c)
+	}
+	return c.canonicalize(u, maxRedirectUnwrapping)
+}
+
+// WithTrackingParameters sets the list of parameters that are removed from the query string.
+//
+// If a parameter name ends with '*', all parameters starting with that prefix are removed.
+func WithTrackingParameters(params ...string) CanonicalizerOption {
+	return func(c *canonicalizer) {
+		c.trackingParameters = params
+	}
+}
+
+// WithExtraTrackingParameters adds some parameters to the list of parameters that are removed from the query string.
+//
+// If a parameter name ends with '*', all parameters starting with that prefix are removed.
+func WithExtraTrackingParameters(params ...string) CanonicalizerOption {
+	return func(c *canonicalizer) {
+		c.trackingParameters = append(append([]string{}, c.trackingParameters...), params...)
+	}
+}
+
+// WithRedirectUnwrapping makes the canonicalizer replace the URLs of known redirectors with the URL they redirect to.
+//
+// For example, https://www.google.com/url?q=https://example.com/ becomes https://example.com/.
+func WithRedirectUnwrapping() CanonicalizerOption {
+	return func(c *canonicalizer) {
+		c.unwrapRedirects = true
+	}
+}
+
+type CanonicalizerOption func(*canonicalizer)
+
+// DefaultTrackingParameters contains the list of query parameters that are removed by default by CanonicalizeUrl.
+var DefaultTrackingParameters = []string{
+	"utm_*", "fbclid", "gclid", "gclsrc", "dclid", "gbraid", "wbraid", "msclkid", "yclid", "twclid", "ttclid",
+	"igshid", "mc_cid", "mc_eid", "_ga", "_gl", "_hsenc", "_hsmi", "mkt_tok", "oly_anon_id", "oly_enc_id",
+	"rb_clickid", "s_cid", "vero_id", "vero_conv",
+}
+
+type canonicalizer struct {
+	trackingParameters []string
+	unwrapRedirects    bool
+}
+
+const maxRedirectUnwrapping = 5
+
+func (c *canonicalizer) canonicalize(u *url.URL, unwrapDepth int) *url.URL {
+	out := *u
+	out.Scheme = strings.ToLower(out.Scheme)
+	host := strings.ToLower(out.Hostname())
+	port := out.Port()
+	if (out.Scheme == "http" && port == "80") || (out.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if len(port) > 0 {
+		out.Host = host + ":" + port
+	} else {
+		out.Host = host
+	}
+
+	if c.unwrapRedirects && unwrapDepth > 0 {
+		if target := unwrapRedirect(&out); target != nil {
+			return c.canonicalize(target, unwrapDepth-1)
+		}
+	}
+
+	if len(out.RawQuery) > 0 {
+		var kept []string
+		for _, param := range strings.Split(out.RawQuery, "&") {
+			key, _, _ := strings.Cut(param, "=")
+			if unescaped, err := url.QueryUnescape(key); err == nil {
+				key = unescaped
+			}
+			if len(param) > 0 && !c.isTrackingParameter(key) {
+				kept = append(kept, param)
+			}
+		}
+		out.RawQuery = strings.Join(kept, "&")
+	}
+	return &out
+}
+
+func (c *canonicalizer) isTrackingParameter(key string) bool {
+	key = strings.ToLower(key)
+	for _, param := range c.trackingParameters {
+		param = strings.ToLower(param)
+		if prefix, found := strings.CutSuffix(param, "*"); found {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if key == param {
+			return true
+		}
+	}
+	return false
+}
+
+// redirector describes a known redirector URL.
+type redirector struct {
+	// hosts contains the hostnames for this redirector. An entry ending with "." matches any top level domain.
+	hosts []string
+	// path is the path of the redirector's URL.
+	path string
+	// params contains the names of the parameters that contain the target URL.
+	params []string
+}
+
+var redirectors = []redirector{
+	{hosts: []string{"google.", "www.google."}, path: "/url", params: []string{"q", "url"}},
+	{hosts: []string{"l.facebook.com", "lm.facebook.com", "m.facebook.com"}, path: "/l.php", params: []string{"u"}},
+	{hosts: []string{"l.instagram.com"}, path: "/", params: []string{"u"}},
+	{hosts: []string{"www.youtube.com", "youtube.com"}, path: "/redirect", params: []string{"q"}},
+	{hosts: []string{"t.umblr.com"}, path: "/redirect", params: []string{"z"}},
+	{hosts: []string{"slack-redir.net"}, path: "/link", params: []string{"url"}},
+	{hosts: []string{"out.reddit.com"}, path: "", params: []string{"url"}},
+}
+
+// unwrapRedirect returns the URL the given redirector URL points to, or nil if it's not a known redirector.
+func unwrapRedirect(u *url.URL) *url.URL {
+	host := u.Hostname()
+	for _, r := range redirectors {
+		if !matchesHost(host, r.hosts) || (len(r.path) > 0 && u.Path != r.path) {
+			continue
+		}
+		query := u.Query()
+		for _, param := range r.params {
+			target, err := url.Parse(query.Get(param))
+			if err != nil || !target.IsAbs() || (target.Scheme != "http" && target.Scheme != "https") || len(target.Host) == 0 {
+				continue
+			}
+			return target
+		}
+	}
+	return nil
+}
+
+func matchesHost(host string, hosts []string) bool {
+	for _, h := range hosts {
+		if prefix, found := strings.CutSuffix(h, "."); found {
+			if tld, ok := strings.CutPrefix(host, prefix+"."); ok && isCountryDomain(tld) {
+				return true
+			}
+		} else if host == h {
+			return true
+		}
+	}
+	return false
+}
+
+// isCountryDomain returns whether the given domain looks like a country's top level domain, such as "es" or "co.uk".
+func isCountryDomain(domain string) bool {
+	labels := strings.Split(domain, ".")
+	return len(labels) == 1 || (len(labels) == 2 && (labels[0] == "co" || labels[0] == "com"))
+}
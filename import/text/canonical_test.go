@@ -0,0 +1,66 @@
+package text_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/jtarrio/k3"
+	"github.com/jtarrio/k3/import/text"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalizeUrl(t *testing.T) {
+	tests := map[string]string{
+		"https://example.com/":                                     "https://example.com/",
+		"HTTPS://Example.COM/Path":                                 "https://example.com/Path",
+		"https://example.com:443/foo":                              "https://example.com/foo",
+		"http://example.com:80/foo":                                "http://example.com/foo",
+		"http://example.com:443/foo":                               "http://example.com:443/foo",
+		"https://[2001:DB8::1]:443/":                               "https://[2001:db8::1]/",
+		"https://example.com/?utm_source=x&utm_medium=y":           "https://example.com/",
+		"https://example.com/?a=1&utm_source=x&b=2&fbclid=abc#top": "https://example.com/?a=1&b=2#top",
+		"https://example.com/?GCLID=1&q=a%20b":                     "https://example.com/?q=a%20b",
+		"https://www.google.com/url?q=https://example.com/":        "https://www.google.com/url?q=https://example.com/",
+	}
+	for input, expected := range tests {
+		u, err := url.Parse(input)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, text.CanonicalizeUrl(u).String(), input)
+	}
+}
+
+func TestCanonicalizeUrlCustomParameters(t *testing.T) {
+	u, _ := url.Parse("https://example.com/?utm_source=x&ref=abc&si=def&a=1")
+	assert.Equal(t, "https://example.com/?utm_source=x&a=1", text.CanonicalizeUrl(u, text.WithTrackingParameters("ref", "s*")).String())
+	assert.Equal(t, "https://example.com/?si=def&a=1", text.CanonicalizeUrl(u, text.WithExtraTrackingParameters("ref")).String())
+}
+
+func TestCanonicalizeUrlUnwrapRedirects(t *testing.T) {
+	tests := map[string]string{
+		"https://www.google.com/url?q=https://example.com/?utm_source=x&sa=D":      "https://example.com/",
+		"https://www.google.co.uk/url?url=https%3A%2F%2Fexample.com%2Ffoo%3Fa%3D1": "https://example.com/foo?a=1",
+		"https://l.facebook.com/l.php?u=https%3A%2F%2FExample.com%2F&h=AT0":        "https://example.com/",
+		"https://www.youtube.com/redirect?q=http://example.com:80/":                "http://example.com/",
+		"https://www.google.com/search?q=https://example.com/":                     "https://www.google.com/search?q=https://example.com/",
+		"https://www.google.com/url?q=javascript:alert(1)":                         "https://www.google.com/url?q=javascript:alert(1)",
+		"https://www.google.evil.example/url?q=https://example.com/":               "https://www.google.evil.example/url?q=https://example.com/",
+	}
+	for input, expected := range tests {
+		u, err := url.Parse(input)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, text.CanonicalizeUrl(u, text.WithRedirectUnwrapping()).String(), input)
+	}
+}
+
+func TestCanonicalUrlResolver(t *testing.T) {
+	importer := text.NewImporter(text.WithUrlResolver(text.CanonicalUrlResolver(nil, text.WithRedirectUnwrapping())))
+	post := importer.Import(`
+Tracking https://Example.com:443/foo?utm_source=bot&fbclid=123
+Redirect www.google.com/url?q=https%3A%2F%2Fexample.net%2Fbar
+The end`)
+	expected := k3.NewPost().AddText(`
+Tracking `).AddLink(`example.com/foo`, "https://example.com/foo").AddText(`
+Redirect `).AddLink(`example.net/bar`, "https://example.net/bar").AddText(`
+The end`)
+	assert.Equal(t, expected, post)
+}
@@ -4,6 +4,11 @@
 // links, mentions, and tags. Some formatting is applied to the URLs to avoid displaying
 // overlong URLs, and extra validation can be added optionally.
 //
+// Internationalized domain names are supported: the links point to the punycode version of the hostname,
+// while the post's text shows the Unicode version. URLs without an "http://" or "https://" prefix are only
+// recognized if their top level domain is valid. The list of valid top level domains is embedded in this
+// package, but it can be replaced using LoadTldList or LoadTldFile.
+//
 // Note that you need to add a HandleResolver to be able to link usernames to their
 // profiles. You may want to use the ClientHandleResolver function in package client.
 package text
@@ -15,8 +20,10 @@ import (
 	"slices"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/jtarrio/k3"
+	"golang.org/x/net/idna"
 )
 
 // Importer is an interface to convert some plain text into a Bluesky post.
@@ -28,10 +35,11 @@ type Importer interface {
 // NewImporter creates a new Importer with the given options.
 func NewImporter(options ...ImporterOption) Importer {
 	i := &importer{
-		handleResolver: DefaultHandleResolver,
-		urlResolver:    DefaultUrlResolver,
-		urlFormatter:   DefaultUrlFormatter,
-		tagResolver:    DefaultTagResolver,
+		handleResolver:  DefaultHandleResolver,
+		urlResolver:     DefaultUrlResolver,
+		urlFormatter:    DefaultUrlFormatter,
+		tagResolver:     DefaultTagResolver,
+		cashtagResolver: DefaultCashtagResolver,
 	}
 	for _, option := range options {
 		option(i)
@@ -76,6 +84,22 @@ func WithTagResolver(r TagResolver) ImporterOption {
 	}
 }
 
+// WithCashtagResolver sets the function to use to resolve cashtags.
+//
+// By default, strings starting with $ followed by a letter and up to four more letters or digits are considered cashtags.
+func WithCashtagResolver(r CashtagResolver) ImporterOption {
+	return func(i *importer) {
+		i.cashtagResolver = r
+	}
+}
+
+// WithLanguageDetector makes the importer fill in the post's languages using the given detector.
+func WithLanguageDetector(d k3.LanguageDetector) ImporterOption {
+	return func(i *importer) {
+		i.languageDetector = d
+	}
+}
+
 // HandleResolver is a type for a function that takes a Bluesky handle and returns its DID, if it exists, or nil if it doesn't.
 type HandleResolver func(string) *string
 
@@ -88,19 +112,25 @@ type UrlFormatter func(*url.URL) string
 // TagResolver is a type for a function that takes a string (with initial '#') and returns the tag it corresponds to, or nil if none.
 type TagResolver func(string) *string
 
+// CashtagResolver is a type for a function that takes a string (with initial '$') and returns the ticker symbol it corresponds to, or nil if none.
+type CashtagResolver func(string) *string
+
 type ImporterOption func(*importer)
 
 type importer struct {
-	handleResolver HandleResolver
-	urlResolver    UrlResolver
-	urlFormatter   UrlFormatter
-	tagResolver    TagResolver
+	handleResolver   HandleResolver
+	urlResolver      UrlResolver
+	urlFormatter     UrlFormatter
+	tagResolver      TagResolver
+	cashtagResolver  CashtagResolver
+	languageDetector k3.LanguageDetector
 }
 
 func (i *importer) Import(text string) *k3.Post {
 	var found []found
 	found = append(found, findAll(text, usernameRe, username)...)
 	found = append(found, findAll(text, hashtagRe, tag)...)
+	found = append(found, findAll(text, cashtagRe, cashtag)...)
 	found = append(found, findAll(text, fullUrlRe, webUrl)...)
 	found = append(found, findAll(text, shortUrlRe, webUrl)...)
 	sortFound(found)
@@ -135,11 +165,22 @@ func (i *importer) Import(text string) *k3.Post {
 				out.AddTag(hashtag, *tag)
 				p = f.end
 			}
+		case cashtag:
+			str := text[f.start:f.end]
+			ticker := i.cashtagResolver(str)
+			if ticker != nil && isCashtagBoundary(text, f.start, f.end) {
+				out.AddText(text[p:f.start])
+				out.AddCashtag(str, *ticker)
+				p = f.end
+			}
 		}
 	}
 	if p < len(text) {
 		out.AddText(text[p:])
 	}
+	if i.languageDetector != nil {
+		out.DetectLanguages(i.languageDetector)
+	}
 	return out
 }
 
@@ -166,7 +207,7 @@ func findAll(str string, re *regexp.Regexp, what foundType) []found {
 // Does not accept the username part of the URL.
 var fullUrlRe = regexp.MustCompile(
 	`(https?://)` + // scheme
-		`(\[[0-9A-Fa-f:]+\]|([0-9]+\.){3}[0-9]+|[A-Za-z][A-Za-z0-9._-]*)` + // host
+		`(\[[0-9A-Fa-f:]+\]|([0-9]+\.){3}[0-9]+|\pL[\pL\pM\pN._-]*)` + // host
 		`(:[0-9]+)?` + // port
 		`((/[A-Za-z0-9._~%!$&'()*+,;=-]*)+)?` + // path
 		`(\?[A-Za-z0-9._~%!$&'()*+,;=/?-]*)?` + // query
@@ -174,8 +215,9 @@ var fullUrlRe = regexp.MustCompile(
 )
 
 // Does not require http:// or https://, but it requires a hostname with at least two components or a full IPv4.
+// The last component must be either ASCII or non-ASCII, so "例え.jpを見て" is matched as "例え.jp".
 var shortUrlRe = regexp.MustCompile(
-	`(\[[0-9A-Fa-f:]+\]|([0-9]+\.){3}[0-9]+|[A-Za-z][A-Za-z0-9._-]*\.[A-Za-z0-9_-]+)` + // host
+	`(\[[0-9A-Fa-f:]+\]|([0-9]+\.){3}[0-9]+|\pL[\pL\pM\pN._-]*\.([A-Za-z0-9_-]+|[\pL\pM]+))` + // host
 		`(:[0-9]+)?` + // port
 		`((/[A-Za-z0-9._~%!$&'()*+,;=-]*)+)?` + // path
 		`(\?[A-Za-z0-9._~%!$&'()*+,;=/?-]*)?` + // query
@@ -190,6 +232,27 @@ var hashtagRe = regexp.MustCompile(
 	`#[^\s#]+#?`,
 )
 
+var cashtagRe = regexp.MustCompile(
+	`\$[A-Za-z][A-Za-z0-9]{0,4}`,
+)
+
+// isCashtagBoundary returns whether the cashtag is not part of a longer word, like "US$" or "$ABCDEFG".
+func isCashtagBoundary(text string, start, end int) bool {
+	if start > 0 {
+		r, _ := utf8.DecodeLastRuneInString(text[:start])
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			return false
+		}
+	}
+	if end < len(text) {
+		r, _ := utf8.DecodeRuneInString(text[end:])
+		if unicode.IsLetter(r) || unicode.IsNumber(r) || r == '_' {
+			return false
+		}
+	}
+	return true
+}
+
 func sortFound(f []found) {
 	slices.SortFunc(f, func(a, b found) int {
 		if a.start != b.start {
@@ -208,6 +271,7 @@ const (
 	webUrl foundType = iota
 	username
 	tag
+	cashtag
 )
 
 type found struct {
@@ -221,9 +285,13 @@ func DefaultHandleResolver(h string) *string {
 }
 
 // DefaultUrlResolver returns every string that can be parsed as a URL, with or without an 'http(s)://' prefix.
+//
+// Internationalized hostnames are converted to punycode. If the string has a hostname with two or more components,
+// its top level domain must be valid. Single-component hostnames are only accepted if the string has a prefix.
 func DefaultUrlResolver(u string) *url.URL {
 	n := strings.Index(u, "://")
-	if n < 0 {
+	hasScheme := n >= 0
+	if !hasScheme {
 		u = "https://" + u
 	}
 	parsed, err := url.Parse(u)
@@ -233,12 +301,35 @@ func DefaultUrlResolver(u string) *url.URL {
 	if !parsed.IsAbs() {
 		return nil
 	}
+	hostname := parsed.Hostname()
+	if net.ParseIP(hostname) != nil {
+		return parsed
+	}
+	asciiHost, err := hostProfile.ToASCII(hostname)
+	if err != nil || len(asciiHost) == 0 {
+		return nil
+	}
+	if n := strings.LastIndexByte(asciiHost, '.'); n >= 0 {
+		if !IsValidTld(asciiHost[n+1:]) {
+			return nil
+		}
+	} else if !hasScheme {
+		return nil
+	}
+	if port := parsed.Port(); len(port) > 0 {
+		parsed.Host = asciiHost + ":" + port
+	} else {
+		parsed.Host = asciiHost
+	}
 	return parsed
 }
 
 // NetworkUrlResolver parses the URL like DefaultUrlResolver, but then checks that the hostname has an IP address.
 func NetworkUrlResolver(u string) *url.URL {
 	parsed := DefaultUrlResolver(u)
+	if parsed == nil {
+		return nil
+	}
 	ips, err := net.LookupIP(parsed.Hostname())
 	if err != nil || len(ips) == 0 {
 		return nil
@@ -247,17 +338,24 @@ func NetworkUrlResolver(u string) *url.URL {
 }
 
 // DefaultUrlFormatter returns the url without scheme, cut to 20 characters if it's longer than 24.
+//
+// Internationalized hostnames are shown in Unicode.
 func DefaultUrlFormatter(u *url.URL) string {
 	host := u.Host
+	if hostname := u.Hostname(); strings.Contains(hostname, "xn--") {
+		if unicodeHost, err := idna.ToUnicode(hostname); err == nil {
+			host = strings.Replace(host, hostname, unicodeHost, 1)
+		}
+	}
 	path := u.Path
-	if len(host) >= 20 || len(path) == 0 || path == "/" {
+	if utf8.RuneCountInString(host) >= 20 || len(path) == 0 || path == "/" {
 		return host
 	}
-	hostPath := host + path
+	hostPath := []rune(host + path)
 	if len(hostPath) >= 24 {
-		return hostPath[:20] + "…"
+		return string(hostPath[:20]) + "…"
 	}
-	return hostPath
+	return string(hostPath)
 }
 
 // DefaultTagResolver cuts the initial '#' and final '#', if it exists, and returns the rest unless it's entirely composed of numbers.
@@ -272,6 +370,17 @@ func DefaultTagResolver(hashtag string) *string {
 	return nil
 }
 
+// DefaultCashtagResolver cuts the initial '$' and returns the rest in uppercase.
+func DefaultCashtagResolver(cashtag string) *string {
+	ticker := strings.ToUpper(strings.TrimPrefix(cashtag, "$"))
+	return &ticker
+}
+
+// NoCashtagResolver always returns nil so no cashtags are recognized.
+func NoCashtagResolver(cashtag string) *string {
+	return nil
+}
+
 // NoTagResolver always returns nil so no hashtags are turned into links.
 func NoTagResolver(hashtag string) *string {
 	return nil
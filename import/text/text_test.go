@@ -1,11 +1,14 @@
 package text_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/jtarrio/k3"
 	"github.com/jtarrio/k3/import/text"
+	"github.com/jtarrio/k3/langdetect"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestConvertPlainText(t *testing.T) {
@@ -90,12 +93,12 @@ func TestConvertUsernames(t *testing.T) {
 	post := text.NewImporter(text.WithHandleResolver(fakeResolver)).Import(`
 An invalid @username
 A @valid.username
-This is @invalid.url.shaped
+This is @invalid.example.com
 Something else`)
 	expected := k3.NewPost().AddText(`
 An invalid @username
 A `).AddMention(`@valid.username`, `cid:web:valid.username`).AddText(`
-This is @`).AddLink(`invalid.url.shaped`, `https://invalid.url.shaped`).AddText(`
+This is @`).AddLink(`invalid.example.com`, `https://invalid.example.com`).AddText(`
 Something else`)
 	assert.Equal(t, expected, post)
 }
@@ -103,11 +106,11 @@ Something else`)
 func TestIgnoreUsernamesByDefault(t *testing.T) {
 	post := text.NewImporter().Import(`
 It doesn't matter whether the @username
-is @valid.username or @invalid.username
+is @valid.bsky.social or @invalid.bsky.social
 it is not converted as a username.`)
 	expected := k3.NewPost().AddText(`
 It doesn't matter whether the @username
-is @`).AddLink(`valid.username`, `https://valid.username`).AddText(` or @`).AddLink(`invalid.username`, `https://invalid.username`).AddText(`
+is @`).AddLink(`valid.bsky.social`, `https://valid.bsky.social`).AddText(` or @`).AddLink(`invalid.bsky.social`, `https://invalid.bsky.social`).AddText(`
 it is not converted as a username.`)
 	assert.Equal(t, expected, post)
 }
@@ -139,7 +142,7 @@ Last line`)
 Some #hashtag
 A#doubleHashtag#isAllowed
 You can have #123numbers but not only #123 numbers
-You can also have #🙂emoji and #`).AddLink(`punc.tuation`, `https://punc.tuation`).AddText(`
+You can also have #🙂emoji and #punc.tuation
 Last line`)
 	assert.Equal(t, expected, post)
 }
@@ -160,3 +163,74 @@ Hashtag or handle? `).AddTag(`#example@example.com`, `example@example.com`).AddT
 `)
 	assert.Equal(t, expected, post)
 }
+
+func TestConvertInternationalizedUrls(t *testing.T) {
+	post := text.NewImporter().Import(`
+Full https://bücher.de/buch
+Short bücher.de
+Punycode xn--bcher-kva.de
+Japanese 例え.jpを見て
+Cyrillic http://пример.рф/
+The end`)
+	expected := k3.NewPost().AddText(`
+Full `).AddLink(`bücher.de/buch`, "https://xn--bcher-kva.de/buch").AddText(`
+Short `).AddLink(`bücher.de`, "https://xn--bcher-kva.de").AddText(`
+Punycode `).AddLink(`bücher.de`, "https://xn--bcher-kva.de").AddText(`
+Japanese `).AddLink(`例え.jp`, "https://xn--r8jz45g.jp").AddText(`を見て
+Cyrillic `).AddLink(`пример.рф`, "http://xn--e1afmkfd.xn--p1ai/").AddText(`
+The end`)
+	assert.Equal(t, expected, post)
+}
+
+func TestValidateTlds(t *testing.T) {
+	post := text.NewImporter().Import(`
+Not links: file.txt v1.2 version.2 foo.notatld
+Links: example.com example.co.uk example.social
+Explicit: http://intranet/ http://foo.notatld/`)
+	expected := k3.NewPost().AddText(`
+Not links: file.txt v1.2 version.2 foo.notatld
+Links: `).AddLink(`example.com`, "https://example.com").AddText(` `).AddLink(`example.co.uk`, "https://example.co.uk").AddText(` `).AddLink(`example.social`, "https://example.social").AddText(`
+Explicit: `).AddLink(`intranet`, "http://intranet/").AddText(` http://foo.notatld/`)
+	assert.Equal(t, expected, post)
+}
+
+func TestLoadTldList(t *testing.T) {
+	defer func() {
+		require.NoError(t, text.LoadTldFile("tlds.txt"))
+	}()
+	assert.True(t, text.IsValidTld("com"))
+	assert.True(t, text.IsValidTld("рф"))
+	assert.True(t, text.IsValidTld("XN--P1AI"))
+	assert.False(t, text.IsValidTld("txt"))
+
+	err := text.LoadTldList(strings.NewReader("# Version 1\nTXT\nрф\n"))
+	require.NoError(t, err)
+	assert.False(t, text.IsValidTld("com"))
+	assert.True(t, text.IsValidTld("txt"))
+	assert.True(t, text.IsValidTld("xn--p1ai"))
+
+	err = text.LoadTldList(strings.NewReader("# Empty list\n"))
+	assert.Error(t, err)
+	assert.True(t, text.IsValidTld("txt"))
+}
+
+func TestLanguageDetection(t *testing.T) {
+	importer := text.NewImporter(text.WithLanguageDetector(langdetect.New()))
+	post := importer.Import(`Avui fa molt bon temps i anirem a passejar per la platja: https://example.com/platja`)
+	assert.Equal(t, []string{"ca"}, post.Languages)
+
+	post = text.NewImporter().Import(`Avui fa molt bon temps i anirem a passejar per la platja.`)
+	assert.Empty(t, post.Languages)
+}
+
+func TestConvertCashtags(t *testing.T) {
+	post := text.NewImporter().Import(`Buying $aapl and ($MSFT), but not US$5, $TOOLONG or $100.`)
+	expected := k3.NewPost().
+		AddText(`Buying `).AddCashtag(`$aapl`, `AAPL`).
+		AddText(` and (`).AddCashtag(`$MSFT`, `MSFT`).
+		AddText(`), but not US$5, $TOOLONG or $100.`)
+	assert.Equal(t, expected, post)
+
+	post = text.NewImporter(text.WithCashtagResolver(text.NoCashtagResolver)).Import(`Buying $AAPL`)
+	assert.Equal(t, k3.NewPost().AddText(`Buying $AAPL`), post)
+}
@@ -0,0 +1,96 @@
+package text
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/idna"
+)
+
+// IsValidTld returns whether the given string is a valid top level domain, such as "com" or "es".
+//
+// The check is case-insensitive, and internationalized domains can be given in Unicode or in punycode.
+func IsValidTld(tld string) bool {
+	tld, err := hostProfile.ToASCII(strings.TrimSuffix(tld, "."))
+	if err != nil {
+		return false
+	}
+	tldList.RLock()
+	defer tldList.RUnlock()
+	return tldList.tlds[tld]
+}
+
+// LoadTldList replaces the list of valid top level domains with the list read from the given reader.
+//
+// The list must have the same format as https://data.iana.org/TLD/tlds-alpha-by-domain.txt:
+// one domain per line, with optional comments starting with '#'.
+func LoadTldList(r io.Reader) error {
+	tlds, err := parseTldList(r)
+	if err != nil {
+		return err
+	}
+	tldList.Lock()
+	defer tldList.Unlock()
+	tldList.tlds = tlds
+	return nil
+}
+
+// LoadTldFile replaces the list of valid top level domains with the list read from the given file.
+//
+// The file must have the same format as https://data.iana.org/TLD/tlds-alpha-by-domain.txt.
+func LoadTldFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return LoadTldList(f)
+}
+
+//go:embed tlds.txt
+var embeddedTlds string
+
+var tldList = struct {
+	sync.RWMutex
+	tlds map[string]bool
+}{tlds: mustParseTldList(embeddedTlds)}
+
+func parseTldList(r io.Reader) (map[string]bool, error) {
+	tlds := map[string]bool{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		text = strings.TrimSpace(text)
+		if len(text) == 0 {
+			continue
+		}
+		tld, err := hostProfile.ToASCII(text)
+		if err != nil || strings.ContainsAny(tld, ". \t") {
+			return nil, fmt.Errorf("invalid top level domain on line %d: '%s'", line, text)
+		}
+		tlds[tld] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(tlds) == 0 {
+		return nil, fmt.Errorf("the list of top level domains is empty")
+	}
+	return tlds, nil
+}
+
+func mustParseTldList(list string) map[string]bool {
+	tlds, err := parseTldList(strings.NewReader(list))
+	if err != nil {
+		panic(err)
+	}
+	return tlds
+}
+
+// hostProfile converts hostnames to their ASCII form, allowing underscores in the host names.
+var hostProfile = idna.New(idna.MapForLookup(), idna.StrictDomainName(false), idna.Transitional(false))
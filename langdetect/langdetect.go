@@ -0,0 +1,284 @@
+// Package langdetect provides an offline language detector based on character n-grams.
+package langdetect
+
+import (
+	"cmp"
+	"embed"
+	"math"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// New creates a new Detector with the given options.
+func New(options ...DetectorOption) *Detector {
+	d := &Detector{threshold: DefaultThreshold, maxLanguages: MaxLanguages}
+	for _, option := range options {
+		option(d)
+	}
+	return d
+}
+
+// WithThreshold sets the minimum confidence, between 0 and 1, that a language must have to be detected.
+func WithThreshold(threshold float64) DetectorOption {
+	return func(d *Detector) {
+		d.threshold = threshold
+	}
+}
+
+// WithMaxLanguages sets the maximum number of languages to return. It can't be more than MaxLanguages.
+func WithMaxLanguages(maxLanguages int) DetectorOption {
+	return func(d *Detector) {
+		d.maxLanguages = min(max(maxLanguages, 1), MaxLanguages)
+	}
+}
+
+// WithLanguages restricts the detector to the given languages.
+//
+// Codes that are not in SupportedLanguages are ignored. If none of the codes is supported, nothing is detected.
+func WithLanguages(languages ...string) DetectorOption {
+	return func(d *Detector) {
+		supported := SupportedLanguages()
+		d.languages = []string{}
+		for _, lang := range languages {
+			if slices.Contains(supported, lang) {
+				d.languages = append(d.languages, lang)
+			}
+		}
+	}
+}
+
+type DetectorOption func(*Detector)
+
+// DefaultThreshold is the default minimum confidence for a language to be detected.
+const DefaultThreshold = 0.8
+
+// MaxLanguages is the maximum number of languages that can be assigned to a post.
+const MaxLanguages = 3
+
+// Detector guesses the languages a text is written in.
+//
+// The text is divided into sentences, and the language of each sentence is detected separately.
+// The languages are then ranked by the amount of text written in them. A language is only returned
+// if it makes up at least a fifth of the text.
+type Detector struct {
+	threshold    float64
+	maxLanguages int
+	// languages contains the languages to detect, or nil to detect all the supported languages.
+	languages []string
+}
+
+// Guess contains a language and the confidence, between 0 and 1, that a text is written in it.
+type Guess struct {
+	Language   string
+	Confidence float64
+}
+
+// DetectLanguages returns the codes of the languages the text is written in, most prominent first.
+func (d *Detector) DetectLanguages(text string) []string {
+	weights := map[string]int{}
+	total := 0
+	for _, sentence := range splitSentences(text) {
+		letters := countLetters(sentence)
+		if letters < minSentenceLetters {
+			continue
+		}
+		guesses := d.Detect(sentence)
+		if len(guesses) > 0 && guesses[0].Confidence >= d.threshold {
+			weights[guesses[0].Language] += letters
+			total += letters
+		}
+	}
+	if total == 0 {
+		guesses := d.Detect(text)
+		if len(guesses) > 0 && guesses[0].Confidence >= d.threshold {
+			return []string{guesses[0].Language}
+		}
+		return nil
+	}
+
+	var out []string
+	for lang, weight := range weights {
+		if weight*5 >= total {
+			out = append(out, lang)
+		}
+	}
+	slices.SortFunc(out, func(a, b string) int {
+		if weights[a] != weights[b] {
+			return weights[b] - weights[a]
+		}
+		return strings.Compare(a, b)
+	})
+	if len(out) > d.maxLanguages {
+		out = out[:d.maxLanguages]
+	}
+	return out
+}
+
+// Detect returns the confidence for each supported language that the whole text is written in it,
+// in decreasing order of confidence.
+func (d *Detector) Detect(text string) []Guess {
+	grams := ngrams(text)
+	if countLetters(text) < minTextLetters {
+		return nil
+	}
+	loadProfiles()
+	var guesses []Guess
+	for _, p := range profiles {
+		if d.languages != nil && !slices.Contains(d.languages, p.language) {
+			continue
+		}
+		score := 0.0
+		for _, g := range grams {
+			score += p.logProb(g)
+		}
+		guesses = append(guesses, Guess{Language: p.language, Confidence: score / float64(len(grams))})
+	}
+	if len(guesses) == 0 {
+		return nil
+	}
+
+	// Turn the average log-probabilities into confidences. The evidence is capped so that
+	// long texts don't produce overconfident results.
+	evidence := float64(min(len(grams), maxEvidence)) * evidenceWeight
+	best := slices.MaxFunc(guesses, func(a, b Guess) int { return cmp.Compare(a.Confidence, b.Confidence) }).Confidence
+	sum := 0.0
+	for i := range guesses {
+		guesses[i].Confidence = math.Exp((guesses[i].Confidence - best) * evidence)
+		sum += guesses[i].Confidence
+	}
+	for i := range guesses {
+		guesses[i].Confidence /= sum
+	}
+	slices.SortFunc(guesses, func(a, b Guess) int {
+		if c := cmp.Compare(b.Confidence, a.Confidence); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Language, b.Language)
+	})
+	return guesses
+}
+
+// SupportedLanguages returns the codes of the languages the detector can recognize.
+func SupportedLanguages() []string {
+	loadProfiles()
+	var out []string
+	for _, p := range profiles {
+		out = append(out, p.language)
+	}
+	return out
+}
+
+const (
+	// minTextLetters is the minimum number of letters that a text must have to try to detect its language.
+	minTextLetters = 3
+	// minSentenceLetters is the minimum number of letters that a sentence must have to be considered on its own.
+	minSentenceLetters = 12
+	// maxEvidence is the maximum number of n-grams whose evidence is taken into account.
+	maxEvidence = 40
+	// evidenceWeight is the weight of each n-gram when turning scores into confidences.
+	evidenceWeight = 0.5
+	// maxN is the length of the longest n-grams.
+	maxN = 3
+)
+
+//go:embed corpus/*.txt
+var corpus embed.FS
+
+var (
+	profiles     []*profile
+	profilesOnce sync.Once
+)
+
+// profile contains the n-gram frequencies for a language.
+type profile struct {
+	language string
+	logProbs map[string]float64
+	// unseen contains the log-probability for n-grams that don't appear in the corpus, for each length.
+	unseen [maxN + 1]float64
+}
+
+func (p *profile) logProb(gram string) float64 {
+	if lp, found := p.logProbs[gram]; found {
+		return lp
+	}
+	return p.unseen[min(len([]rune(gram)), maxN)]
+}
+
+func loadProfiles() {
+	profilesOnce.Do(func() {
+		entries, err := corpus.ReadDir("corpus")
+		if err != nil {
+			panic(err)
+		}
+		for _, entry := range entries {
+			data, err := corpus.ReadFile(path.Join("corpus", entry.Name()))
+			if err != nil {
+				panic(err)
+			}
+			profiles = append(profiles, newProfile(strings.TrimSuffix(entry.Name(), ".txt"), string(data)))
+		}
+	})
+}
+
+func newProfile(language string, text string) *profile {
+	counts := map[string]int{}
+	var totals [maxN + 1]int
+	for _, g := range ngrams(text) {
+		counts[g]++
+		totals[len([]rune(g))]++
+	}
+	p := &profile{language: language, logProbs: map[string]float64{}}
+	for g, count := range counts {
+		n := len([]rune(g))
+		p.logProbs[g] = math.Log(float64(count) / float64(totals[n]+1))
+	}
+	for n := 1; n <= maxN; n++ {
+		// Unseen n-grams are considered a bit less likely than n-grams that appear once in the corpus.
+		p.unseen[n] = math.Log(0.1 / float64(totals[n]+1))
+	}
+	return p
+}
+
+// ngrams returns the 1-, 2- and 3-grams of the words in the text.
+//
+// The text is converted to lowercase and only letters are kept. Each word is surrounded by spaces,
+// so n-grams at the beginning and the end of words are different from n-grams in the middle.
+func ngrams(text string) []string {
+	var out []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !isLetter(r) }) {
+		runes := []rune(" " + word + " ")
+		for n := 1; n <= maxN; n++ {
+			for i := 0; i+n <= len(runes); i++ {
+				if n == 1 && runes[i] == ' ' {
+					continue
+				}
+				out = append(out, string(runes[i:i+n]))
+			}
+		}
+	}
+	return out
+}
+
+func isLetter(r rune) bool {
+	return unicode.IsLetter(r) || unicode.Is(unicode.Mn, r) || r == '\''
+}
+
+func countLetters(text string) int {
+	count := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			count++
+		}
+	}
+	return count
+}
+
+// splitSentences divides the text at sentence-ending punctuation and line breaks.
+func splitSentences(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return r == '.' || r == '!' || r == '?' || r == '\n' || r == '。' || r == '！' || r == '？' || r == '؟'
+	})
+}
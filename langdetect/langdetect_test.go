@@ -0,0 +1,81 @@
+package langdetect_test
+
+import (
+	"testing"
+
+	"github.com/jtarrio/k3/langdetect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectLanguages(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected []string
+	}{
+		{"The quick brown fox jumps over the lazy dog while everyone watches.", []string{"en"}},
+		{"En un lugar de la Mancha, de cuyo nombre no quiero acordarme.", []string{"es"}},
+		{"Il était une fois une petite fille qui vivait dans un village.", []string{"fr"}},
+		{"Heute ist das Wetter sehr schön, und wir gehen in den Park.", []string{"de"}},
+		{"Avui fa molt bon temps i anirem a passejar per la platja.", []string{"ca"}},
+		{"Hoxe vai moi bo tempo e imos dar un paseo pola praia.", []string{"gl"}},
+		{"Сегодня очень хорошая погода, и мы пойдём гулять в парк.", []string{"ru"}},
+		{"今日はとても良い天気なので、公園に散歩に行きます。", []string{"ja"}},
+		{"今天天气很好，我们去公园散步吧。", []string{"zh"}},
+		{"오늘은 날씨가 아주 좋아서 공원에 산책하러 갑니다.", []string{"ko"}},
+	}
+	detector := langdetect.New()
+	for _, test := range tests {
+		assert.Equal(t, test.expected, detector.DetectLanguages(test.text), test.text)
+	}
+}
+
+func TestDetectMixedLanguages(t *testing.T) {
+	detector := langdetect.New()
+	text := "The quick brown fox jumps over the lazy dog while everyone watches.\n" +
+		"En un lugar de la Mancha, de cuyo nombre no quiero acordarme, vivía un hidalgo."
+	assert.Equal(t, []string{"es", "en"}, detector.DetectLanguages(text))
+
+	detector = langdetect.New(langdetect.WithMaxLanguages(1))
+	assert.Equal(t, []string{"es"}, detector.DetectLanguages(text))
+}
+
+func TestDetectTooShort(t *testing.T) {
+	detector := langdetect.New()
+	assert.Empty(t, detector.DetectLanguages(""))
+	assert.Empty(t, detector.DetectLanguages("ok"))
+	assert.Empty(t, detector.DetectLanguages("12345 :) https://"))
+}
+
+func TestThreshold(t *testing.T) {
+	text := "Bon dia"
+	assert.Empty(t, langdetect.New(langdetect.WithThreshold(0.999)).DetectLanguages(text))
+	assert.Len(t, langdetect.New(langdetect.WithThreshold(0)).DetectLanguages(text), 1)
+}
+
+func TestWithLanguages(t *testing.T) {
+	detector := langdetect.New(langdetect.WithLanguages("en", "fr"))
+	guesses := detector.Detect("En un lugar de la Mancha, de cuyo nombre no quiero acordarme.")
+	assert.Len(t, guesses, 2)
+	for _, guess := range guesses {
+		assert.Contains(t, []string{"en", "fr"}, guess.Language)
+	}
+}
+
+func TestWithUnsupportedLanguages(t *testing.T) {
+	detector := langdetect.New(langdetect.WithLanguages("en", "tlh"))
+	guesses := detector.Detect("En un lugar de la Mancha, de cuyo nombre no quiero acordarme.")
+	require.Len(t, guesses, 1)
+	assert.Equal(t, "en", guesses[0].Language)
+
+	detector = langdetect.New(langdetect.WithLanguages("tlh"))
+	assert.Empty(t, detector.Detect("En un lugar de la Mancha, de cuyo nombre no quiero acordarme."))
+	assert.Empty(t, detector.DetectLanguages("En un lugar de la Mancha, de cuyo nombre no quiero acordarme."))
+}
+
+func TestSupportedLanguages(t *testing.T) {
+	languages := langdetect.SupportedLanguages()
+	assert.Contains(t, languages, "en")
+	assert.Contains(t, languages, "ja")
+	assert.Len(t, languages, 20)
+}
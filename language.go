@@ -0,0 +1,29 @@
+package k3
+
+import "strings"
+
+// LanguageDetector is an interface for objects that guess the languages a text is written in.
+type LanguageDetector interface {
+	// DetectLanguages returns the codes of the languages the text is written in, most prominent first,
+	// or an empty list if the language could not be determined.
+	DetectLanguages(text string) []string
+}
+
+// DetectLanguages sets the post's languages to the ones the detector finds in its text.
+//
+// The text of links, mentions, tags, and blocks with custom features is not taken into account. If no language is detected,
+// the post's languages are not modified.
+func (p *Post) DetectLanguages(detector LanguageDetector) *Post {
+	sb := strings.Builder{}
+	for _, block := range p.Blocks {
+		if !block.HasFeatures() {
+			sb.WriteString(block.Text)
+		} else {
+			sb.WriteString(" ")
+		}
+	}
+	if langs := detector.DetectLanguages(sb.String()); len(langs) > 0 {
+		p.Languages = langs
+	}
+	return p
+}
@@ -0,0 +1,121 @@
+// Package mastodon publishes k3.Post objects to Mastodon and other servers that implement the Mastodon API.
+//
+// A Converter turns each post into one or more statuses, and a Publisher sends them to the server
+// through a Client, as a thread.
+package mastodon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Client is an interface for talking to a Mastodon server.
+type Client interface {
+	// PostStatus publishes the given status, returning its ID and URLs.
+	PostStatus(ctx context.Context, status *Status) (*PublishedStatus, error)
+}
+
+// Status contains the parameters of a status to publish, as defined in the Mastodon API.
+type Status struct {
+	// Status is the text of the status.
+	Status string `json:"status"`
+	// InReplyToID is the ID of the status this status replies to, if any.
+	InReplyToID string `json:"in_reply_to_id,omitempty"`
+	// Sensitive marks the status as containing sensitive content.
+	Sensitive bool `json:"sensitive,omitempty"`
+	// SpoilerText is shown instead of the status text until the reader expands it.
+	SpoilerText string `json:"spoiler_text,omitempty"`
+	// Visibility specifies who can see the status. If empty, the account's default is used.
+	Visibility Visibility `json:"visibility,omitempty"`
+	// Language is the ISO 639 code of the status's language.
+	Language string `json:"language,omitempty"`
+}
+
+// Visibility specifies who can see a status.
+type Visibility string
+
+const (
+	// Public statuses are visible to everyone and appear in public timelines.
+	Public Visibility = "public"
+	// Unlisted statuses are visible to everyone but don't appear in public timelines.
+	Unlisted Visibility = "unlisted"
+	// Private statuses are only visible to followers.
+	Private Visibility = "private"
+	// Direct statuses are only visible to the mentioned users.
+	Direct Visibility = "direct"
+)
+
+// PublishedStatus holds the result of the PostStatus method.
+type PublishedStatus struct {
+	// ID contains the status's ID in the server.
+	ID string `json:"id"`
+	// Uri contains the status's ActivityPub URI.
+	Uri string `json:"uri"`
+	// Url contains the URL of the status's web page.
+	Url string `json:"url"`
+}
+
+// New creates a new client for the Mastodon server at the given URL, authenticated with the given access token.
+func New(server string, accessToken string, options ...ClientOption) Client {
+	client := &clientImpl{
+		server:      strings.TrimSuffix(server, "/"),
+		accessToken: accessToken,
+		http:        http.DefaultClient,
+	}
+	for _, option := range options {
+		option(client)
+	}
+	return client
+}
+
+// WithHttpClient makes the client use a different http.Client to connect to the server.
+func WithHttpClient(client *http.Client) ClientOption {
+	return func(c *clientImpl) {
+		c.http = client
+	}
+}
+
+// ClientOption is a modifier for New.
+type ClientOption func(*clientImpl)
+
+type clientImpl struct {
+	server      string
+	accessToken string
+	http        *http.Client
+}
+
+func (c *clientImpl) PostStatus(ctx context.Context, status *Status) (*PublishedStatus, error) {
+	body, err := json.Marshal(status)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.server+"/api/v1/statuses", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not publish status: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		var apiError struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&apiError); err != nil || apiError.Error == "" {
+			return nil, fmt.Errorf("could not publish status: %s", resp.Status)
+		}
+		return nil, fmt.Errorf("could not publish status: %s: %s", resp.Status, apiError.Error)
+	}
+	var output PublishedStatus
+	if err := json.NewDecoder(resp.Body).Decode(&output); err != nil {
+		return nil, fmt.Errorf("could not read the published status: %w", err)
+	}
+	return &output, nil
+}
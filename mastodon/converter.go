@@ -0,0 +1,350 @@
+package mastodon
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/jtarrio/k3"
+	"github.com/jtarrio/k3/posts"
+)
+
+// DefaultMaxLength is the default maximum length of a status, which is Mastodon's default limit.
+const DefaultMaxLength = 500
+
+// urlLength is the length Mastodon counts for every URL in a status, regardless of its actual length.
+const urlLength = 23
+
+// NewConverter creates a new Converter with the given options.
+func NewConverter(options ...ConverterOption) *Converter {
+	c := &Converter{
+		maxLength: DefaultMaxLength,
+		mention:   DefaultMention,
+		partFn:    posts.DefaultPartFunction,
+		prefix:    true,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// WithMaxLength sets the maximum length of a status. Longer posts are split into several statuses.
+func WithMaxLength(maxLength int) ConverterOption {
+	return func(c *Converter) {
+		c.maxLength = maxLength
+	}
+}
+
+// WithMentions makes the converter use the given function to turn a mentioned user's DID and the text
+// of the mention into a Mastodon address, such as "@alice@example.com".
+// If the function returns an empty string, the mention is written as plain text.
+func WithMentions(mention func(did string, text string) string) ConverterOption {
+	return func(c *Converter) {
+		c.mention = mention
+	}
+}
+
+// WithVisibility sets the visibility of the statuses.
+func WithVisibility(visibility Visibility) ConverterOption {
+	return func(c *Converter) {
+		c.visibility = visibility
+	}
+}
+
+// WithPrefix uses the given function as a part numbering function, and prepends its result to each status
+// when a post is split.
+func WithPrefix(fn posts.PartFunction) ConverterOption {
+	return func(c *Converter) {
+		c.partFn = fn
+		c.prefix = true
+	}
+}
+
+// WithSuffix uses the given function as a part numbering function, and appends its result to each status
+// when a post is split.
+func WithSuffix(fn posts.PartFunction) ConverterOption {
+	return func(c *Converter) {
+		c.partFn = fn
+		c.prefix = false
+	}
+}
+
+type ConverterOption func(*Converter)
+
+// Converter turns k3.Post objects into Mastodon statuses.
+type Converter struct {
+	maxLength  int
+	mention    func(did string, text string) string
+	visibility Visibility
+	partFn     posts.PartFunction
+	prefix     bool
+}
+
+// DefaultMention addresses Bluesky users through the Bridgy Fed bridge, turning a mention
+// like "@alice.bsky.social" into "@alice.bsky.social@bsky.brid.gy".
+//
+// Mentions whose text is already a Mastodon address, like "@alice@example.com", are kept as they are.
+// For mentions whose text is neither a handle nor a Mastodon address, it returns an empty string.
+func DefaultMention(did string, text string) string {
+	handle := strings.TrimPrefix(text, "@")
+	if user, domain, found := strings.Cut(handle, "@"); found {
+		if _, err := syntax.ParseHandle(domain); err != nil || user == "" || strings.ContainsFunc(user, unicode.IsSpace) {
+			return ""
+		}
+		return text
+	}
+	if _, err := syntax.ParseHandle(handle); err != nil {
+		return ""
+	}
+	return "@" + handle + "@bsky.brid.gy"
+}
+
+// MentionMap returns a function for WithMentions that looks up the Mastodon address of each DID in the given map.
+// DIDs that are not in the map are handled by DefaultMention.
+func MentionMap(addresses map[string]string) func(did string, text string) string {
+	return func(did string, text string) string {
+		if address, found := addresses[did]; found {
+			return address
+		}
+		return DefaultMention(did, text)
+	}
+}
+
+// ToStatuses converts a post into one or more statuses, splitting it if it is too long.
+//
+// Links are written as their URL, or as their text followed by the URL in parentheses. Mentions are written as
+// Mastodon addresses, or as plain text if they have none, and tags become hashtags; tags whose text is not already a hashtag are added at the end.
+// Cashtags are kept as text. If the post has known self-labels, the statuses are marked as sensitive and the labels
+// are used as the spoiler text.
+//
+// Lengths are counted as Mastodon does: every URL counts as 23 characters, and mentions count without their domain.
+// The spoiler text counts towards the limit of every status.
+//
+// A post without text produces no statuses. It returns an error if the post has to be split but the maximum length,
+// minus the spoiler text, can't hold a part number and some text.
+func (c *Converter) ToStatuses(post *k3.Post) ([]*Status, error) {
+	template := Status{Visibility: c.visibility}
+	if len(post.Languages) > 0 {
+		template.Language, _, _ = strings.Cut(post.Languages[0], "-")
+	}
+	var labels []string
+	for _, label := range post.Labels {
+		if slices.Contains(k3.KnownLabels, label) {
+			labels = append(labels, label)
+		}
+	}
+	if len(labels) > 0 {
+		template.Sensitive = true
+		template.SpoilerText = strings.Join(labels, ", ")
+	}
+
+	t := &tokenizer{}
+	var hashtags []string
+	for _, block := range post.Blocks {
+		switch {
+		case block.Link != nil:
+			if block.Text != *block.Link {
+				t.addText(block.Text + " (")
+				t.addAtom(*block.Link, urlLength)
+				t.addText(")")
+			} else {
+				t.addAtom(*block.Link, urlLength)
+			}
+		case block.Mention != nil:
+			address := c.mention(*block.Mention, block.Text)
+			if address == "" {
+				t.addText(block.Text)
+				break
+			}
+			user, _, _ := strings.Cut(strings.TrimPrefix(address, "@"), "@")
+			t.addAtom(address, len([]rune(user))+1)
+		case block.Tag != nil && !strings.HasPrefix(*block.Tag, "$"):
+			hashtag := toHashtag(*block.Tag)
+			if strings.HasPrefix(block.Text, "#") && hashtag != "" {
+				t.addText(hashtag)
+			} else {
+				t.addText(block.Text)
+				if hashtag != "" && !strings.Contains(block.Text, hashtag) {
+					hashtags = append(hashtags, hashtag)
+				}
+			}
+		default:
+			t.addText(block.Text)
+		}
+	}
+	seen := map[string]bool{}
+	for _, hashtag := range hashtags {
+		if !seen[strings.ToLower(hashtag)] {
+			seen[strings.ToLower(hashtag)] = true
+			t.addText(" " + hashtag)
+		}
+	}
+
+	tokens := t.finish()
+	if strings.TrimSpace(joinTokens(tokens)) == "" {
+		return nil, nil
+	}
+	texts, err := c.split(tokens, c.maxLength-len([]rune(template.SpoilerText)))
+	if err != nil {
+		return nil, err
+	}
+	var out []*Status
+	for _, text := range texts {
+		status := template
+		status.Status = text
+		out = append(out, &status)
+	}
+	return out, nil
+}
+
+// toHashtag returns the hashtag for a tag, keeping only the characters that Mastodon allows in hashtags.
+func toHashtag(tag string) string {
+	var sb strings.Builder
+	for _, r := range strings.TrimPrefix(tag, "#") {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || r == '_' || r == '·' {
+			sb.WriteRune(r)
+		}
+	}
+	if sb.Len() == 0 {
+		return ""
+	}
+	return "#" + sb.String()
+}
+
+// split groups the tokens into texts that, together with the part numbers, fit within the limit.
+//
+// Words that don't fit in a status by themselves are split, except for URLs and mentions, which can't be split.
+func (c *Converter) split(tokens []token, limit int) ([]string, error) {
+	total := 0
+	for _, tok := range tokens {
+		total += tok.length
+	}
+	if total <= limit {
+		return []string{strings.TrimSpace(joinTokens(tokens))}, nil
+	}
+
+	allTokens := tokens
+	maxCount := 9
+	for {
+		room := limit - len([]rune(c.partFn(maxCount, maxCount))) - 1
+		if room < 1 {
+			return nil, fmt.Errorf("a status of up to %d characters can't hold the part numbers", limit)
+		}
+		tokens := splitWords(allTokens, room)
+		var groups [][]token
+		var group []token
+		var groupLen int
+		startGroup := func(i int) {
+			group = []token{tokens[i]}
+			groupLen = tokens[i].length + len([]rune(c.partFn(len(groups)+1, maxCount))) + 1
+		}
+		startGroup(0)
+		for i := 2; i < len(tokens); i += 2 {
+			l := tokens[i-1].length + tokens[i].length
+			if groupLen+l > limit {
+				groups = append(groups, group)
+				startGroup(i)
+			} else {
+				groupLen += l
+				group = append(group, tokens[i-1], tokens[i])
+			}
+		}
+		groups = append(groups, group)
+		if len(groups) > maxCount {
+			maxCount = maxCount*10 + 9
+			continue
+		}
+		var out []string
+		for i, group := range groups {
+			text := strings.TrimSpace(joinTokens(group))
+			if c.prefix {
+				out = append(out, c.partFn(i+1, len(groups))+" "+text)
+			} else {
+				out = append(out, text+" "+c.partFn(i+1, len(groups)))
+			}
+		}
+		return out, nil
+	}
+}
+
+// splitWords splits the words that are longer than the given length into several words, with empty spacing between them.
+func splitWords(tokens []token, length int) []token {
+	var out []token
+	for i, tok := range tokens {
+		if i%2 == 1 || tok.atom || tok.length <= length {
+			out = append(out, tok)
+			continue
+		}
+		runes := []rune(tok.text)
+		for len(runes) > length {
+			out = append(out, token{text: string(runes[:length]), length: length}, token{})
+			runes = runes[length:]
+		}
+		out = append(out, token{text: string(runes), length: len(runes)})
+	}
+	return out
+}
+
+// token is a word or a run of spacing in a status, with the length Mastodon counts for it.
+type token struct {
+	text   string
+	length int
+	// atom is true if the word contains a URL or a mention.
+	atom bool
+}
+
+func joinTokens(tokens []token) string {
+	var sb strings.Builder
+	for _, tok := range tokens {
+		sb.WriteString(tok.text)
+	}
+	return sb.String()
+}
+
+// tokenizer creates a list of tokens that contain words and spacing between words, in that order.
+type tokenizer struct {
+	tokens  []token
+	current strings.Builder
+	length  int
+	atom    bool
+	inSpace bool
+}
+
+func (t *tokenizer) flush() {
+	t.tokens = append(t.tokens, token{text: t.current.String(), length: t.length, atom: t.atom})
+	t.current.Reset()
+	t.length = 0
+	t.atom = false
+}
+
+// addText adds plain text, where every character counts as one.
+func (t *tokenizer) addText(text string) {
+	for _, r := range text {
+		space := unicode.IsSpace(r)
+		if space != t.inSpace {
+			t.flush()
+			t.inSpace = space
+		}
+		t.current.WriteRune(r)
+		t.length++
+	}
+}
+
+// addAtom adds text that can't be split and has no spaces, such as a URL or a mention, with the given length.
+func (t *tokenizer) addAtom(text string, length int) {
+	if t.inSpace {
+		t.flush()
+		t.inSpace = false
+	}
+	t.current.WriteString(text)
+	t.length += length
+	t.atom = true
+}
+
+func (t *tokenizer) finish() []token {
+	t.flush()
+	return t.tokens
+}
@@ -0,0 +1,144 @@
+package mastodon_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/jtarrio/k3"
+	"github.com/jtarrio/k3/mastodon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertFeatures(t *testing.T) {
+	c := mastodon.NewConverter(mastodon.WithMentions(mastodon.MentionMap(map[string]string{"did:plc:bob": "@bob@example.com"})))
+	post := k3.NewPost().
+		AddText("Hello ").
+		AddMention("@alice.bsky.social", "did:plc:alice").
+		AddText(" and ").
+		AddMention("@bob.bsky.social", "did:plc:bob").
+		AddText(", read ").
+		AddLink("https://example.com/news", "https://example.com/news").
+		AddText(" or ").
+		AddLink("our blog", "https://example.com/blog").
+		AddText(". ").
+		AddTag("#Release", "Release").
+		AddText(" of ").
+		AddTag("Go", "golang").
+		AddText(" for ").
+		AddCashtag("$GOOG", "GOOG").
+		AddLanguage("en-US")
+	statuses, err := c.ToStatuses(post)
+	require.NoError(t, err)
+	assert.Equal(t, []*mastodon.Status{{
+		Status:   "Hello @alice.bsky.social@bsky.brid.gy and @bob@example.com, read https://example.com/news or our blog (https://example.com/blog). #Release of Go for $GOOG #golang",
+		Language: "en",
+	}}, statuses)
+}
+
+func TestConvertMentionsWithoutHandle(t *testing.T) {
+	c := mastodon.NewConverter()
+	post := k3.NewPost().
+		AddText("Thanks to ").
+		AddMention("the author", "did:plc:alice").
+		AddText(" and ").
+		AddMention("@did:plc:bob", "did:plc:bob").
+		AddText(" and ").
+		AddMention("@carol@example.com", "did:plc:carol")
+	statuses, err := c.ToStatuses(post)
+	require.NoError(t, err)
+	assert.Equal(t, []*mastodon.Status{{Status: "Thanks to the author and @did:plc:bob and @carol@example.com"}}, statuses)
+
+	assert.Equal(t, "", mastodon.DefaultMention("did:plc:alice", "the author"))
+	assert.Equal(t, "", mastodon.DefaultMention("did:plc:bob", "@did:plc:bob"))
+	assert.Equal(t, "", mastodon.DefaultMention("did:plc:dave", "@the dave@example.com"))
+	assert.Equal(t, "@alice.bsky.social@bsky.brid.gy", mastodon.DefaultMention("did:plc:alice", "@alice.bsky.social"))
+}
+
+func TestConvertLabels(t *testing.T) {
+	c := mastodon.NewConverter(mastodon.WithVisibility(mastodon.Unlisted))
+	post := k3.NewPost().AddText("Spoilers ahead").AddLabel(k3.LabelGraphicMedia)
+	statuses, err := c.ToStatuses(post)
+	require.NoError(t, err)
+	assert.Equal(t, []*mastodon.Status{{
+		Status:      "Spoilers ahead",
+		Sensitive:   true,
+		SpoilerText: "graphic-media",
+		Visibility:  mastodon.Unlisted,
+	}}, statuses)
+}
+
+func TestSplitLongPost(t *testing.T) {
+	c := mastodon.NewConverter(mastodon.WithMaxLength(100))
+	url := "https://example.com/a/very/long/url/that/counts/as/twenty/three/characters"
+	post := k3.NewPost()
+	var expectedWords []string
+	for i := range 20 {
+		if i > 0 {
+			post.AddText(" ")
+		}
+		post.AddText(fmt.Sprintf("word%d ", i)).AddLink(url, url)
+		expectedWords = append(expectedWords, fmt.Sprintf("word%d", i), url)
+	}
+	statuses, err := c.ToStatuses(post)
+	require.NoError(t, err)
+	// Every URL counts as 23 characters, so three words and three URLs fit in each status.
+	require.Len(t, statuses, 7)
+	var words []string
+	for i, status := range statuses {
+		fields := strings.Fields(status.Status)
+		assert.Equal(t, fmt.Sprintf("[%d/7]", i+1), fields[0])
+		words = append(words, fields[1:]...)
+	}
+	assert.Equal(t, expectedWords, words)
+}
+
+func TestSplitCountsSpoilerText(t *testing.T) {
+	c := mastodon.NewConverter(mastodon.WithMaxLength(20), mastodon.WithSuffix(func(num, total int) string { return "" }))
+	post := k3.NewPost().AddText("one two three four five").AddLabel(k3.LabelNudity)
+	statuses, err := c.ToStatuses(post)
+	require.NoError(t, err)
+	var texts []string
+	for _, status := range statuses {
+		texts = append(texts, strings.TrimSpace(status.Status))
+	}
+	assert.Equal(t, []string{"one two three", "four five"}, texts)
+}
+
+func TestConvertEmptyPost(t *testing.T) {
+	c := mastodon.NewConverter()
+	for _, post := range []*k3.Post{k3.NewPost(), k3.NewPost().AddText("  \n ").AddLabel(k3.LabelNudity)} {
+		statuses, err := c.ToStatuses(post)
+		require.NoError(t, err)
+		assert.Empty(t, statuses)
+	}
+}
+
+func TestSplitLongWords(t *testing.T) {
+	c := mastodon.NewConverter(mastodon.WithMaxLength(20))
+	word := strings.Repeat("abcdefghij", 15)
+	for _, post := range []*k3.Post{k3.NewPost().AddText(word), k3.NewPost().AddText(word).AddLabel(k3.LabelPorn)} {
+		statuses, err := c.ToStatuses(post)
+		require.NoError(t, err)
+		var text string
+		for _, status := range statuses {
+			assert.LessOrEqual(t, utf8.RuneCountInString(status.SpoilerText+status.Status), 20, status.Status)
+			_, part, _ := strings.Cut(status.Status, " ")
+			text += part
+		}
+		assert.Equal(t, word, text)
+	}
+}
+
+func TestLimitTooSmallForPartNumbers(t *testing.T) {
+	c := mastodon.NewConverter(mastodon.WithMaxLength(6))
+	_, err := c.ToStatuses(k3.NewPost().AddText("this post doesn't fit"))
+	assert.EqualError(t, err, "a status of up to 6 characters can't hold the part numbers")
+
+	// Posts that don't have to be split are fine.
+	statuses, err := c.ToStatuses(k3.NewPost().AddText("short"))
+	require.NoError(t, err)
+	assert.Equal(t, []*mastodon.Status{{Status: "short"}}, statuses)
+}
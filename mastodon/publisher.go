@@ -0,0 +1,81 @@
+package mastodon
+
+import (
+	"context"
+
+	"github.com/jtarrio/k3"
+)
+
+// PublishResult contains the result of a Publish or Resume operation.
+type PublishResult struct {
+	// Published contains the result of publishing each individual status. If the operation failed at some point,
+	// the results of the successful operations are here.
+	Published []*PublishedStatus
+	// Remaining contains the list of all statuses that are left to be published.
+	Remaining []*Status
+	// Error contains the error returned by the last publish operation, if any.
+	Error error
+}
+
+// NewPublisher creates a new Publisher with the given client and options.
+func NewPublisher(client Client, options ...PublisherOption) *Publisher {
+	p := &Publisher{client: client, converter: NewConverter()}
+	for _, option := range options {
+		option(p)
+	}
+	return p
+}
+
+// WithConverter makes the publisher use the given converter to turn posts into statuses.
+func WithConverter(converter *Converter) PublisherOption {
+	return func(p *Publisher) {
+		p.converter = converter
+	}
+}
+
+type PublisherOption func(*Publisher)
+
+// Publisher publishes posts to a Mastodon server as threads.
+type Publisher struct {
+	client    Client
+	converter *Converter
+}
+
+// Publish converts the posts into statuses and publishes them as a thread, with each status replying to the previous one.
+//
+// A post that is too long is split into several statuses, which are also part of the thread.
+// If a post can't be converted, nothing is published and the result contains the error.
+func (p *Publisher) Publish(ctx context.Context, posts []*k3.Post) *PublishResult {
+	var statuses []*Status
+	for _, post := range posts {
+		postStatuses, err := p.converter.ToStatuses(post)
+		if err != nil {
+			return &PublishResult{Error: err}
+		}
+		statuses = append(statuses, postStatuses...)
+	}
+	return p.doPublish(ctx, statuses, nil)
+}
+
+// Resume publishes the remaining statuses, picking up where a previous call to Publish failed.
+func (p *Publisher) Resume(ctx context.Context, previousResult *PublishResult) *PublishResult {
+	return p.doPublish(ctx, previousResult.Remaining, previousResult.Published)
+}
+
+func (p *Publisher) doPublish(ctx context.Context, statuses []*Status, previousResults []*PublishedStatus) *PublishResult {
+	result := &PublishResult{Published: previousResults}
+	for i, status := range statuses {
+		thisStatus := *status
+		if len(result.Published) > 0 {
+			thisStatus.InReplyToID = result.Published[len(result.Published)-1].ID
+		}
+		published, err := p.client.PostStatus(ctx, &thisStatus)
+		if err != nil {
+			result.Remaining = statuses[i:]
+			result.Error = err
+			return result
+		}
+		result.Published = append(result.Published, published)
+	}
+	return result
+}
@@ -0,0 +1,74 @@
+package mastodon_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jtarrio/k3"
+	"github.com/jtarrio/k3/mastodon"
+	atptesting "github.com/jtarrio/k3/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishThread(t *testing.T) {
+	server := atptesting.NewFakeMastodonServer("secret")
+	defer server.Close()
+	p := mastodon.NewPublisher(mastodon.New(server.URL(), "secret"))
+
+	long := k3.NewPost().AddText(strings.Repeat("All work and no play makes Jack a dull boy. ", 20))
+	short := k3.NewPost().AddText("The end.")
+	result := p.Publish(context.Background(), []*k3.Post{long, short})
+	require.NoError(t, result.Error)
+	require.Len(t, server.Statuses, 3)
+	assert.Len(t, result.Published, 3)
+	assert.Empty(t, result.Remaining)
+	assert.True(t, strings.HasPrefix(server.Statuses[0].Status, "[1/2] All work"))
+	assert.True(t, strings.HasPrefix(server.Statuses[1].Status, "[2/2] "))
+	assert.Equal(t, "The end.", server.Statuses[2].Status)
+	assert.Equal(t, "", server.Statuses[0].InReplyToID)
+	for i := 1; i < 3; i++ {
+		assert.Equal(t, result.Published[i-1].ID, server.Statuses[i].InReplyToID)
+	}
+	assert.Equal(t, server.URL()+"/@me/"+result.Published[0].ID, result.Published[0].Url)
+}
+
+func TestPublishNothingIfConversionFails(t *testing.T) {
+	server := atptesting.NewFakeMastodonServer("secret")
+	defer server.Close()
+	p := mastodon.NewPublisher(mastodon.New(server.URL(), "secret"), mastodon.WithConverter(mastodon.NewConverter(mastodon.WithMaxLength(6))))
+
+	result := p.Publish(context.Background(), []*k3.Post{k3.NewPost().AddText("Fits"), k3.NewPost().AddText("Does not fit")})
+	assert.ErrorContains(t, result.Error, "can't hold the part numbers")
+	assert.Empty(t, server.Statuses)
+}
+
+func TestResumeThread(t *testing.T) {
+	server := atptesting.NewFakeMastodonServer("secret").FailAfter(1)
+	defer server.Close()
+	p := mastodon.NewPublisher(mastodon.New(server.URL(), "secret"))
+
+	posts := []*k3.Post{k3.NewPost().AddText("One"), k3.NewPost().AddText("Two"), k3.NewPost().AddText("Three")}
+	result := p.Publish(context.Background(), posts)
+	assert.ErrorContains(t, result.Error, "Service unavailable")
+	assert.Len(t, result.Published, 1)
+	assert.Len(t, result.Remaining, 2)
+
+	server.FailAfter(-1)
+	result = p.Resume(context.Background(), result)
+	require.NoError(t, result.Error)
+	require.Len(t, server.Statuses, 3)
+	assert.Equal(t, "Three", server.Statuses[2].Status)
+	assert.Equal(t, server.Statuses[0].ID, server.Statuses[1].InReplyToID)
+	assert.Equal(t, server.Statuses[1].ID, server.Statuses[2].InReplyToID)
+}
+
+func TestInvalidToken(t *testing.T) {
+	server := atptesting.NewFakeMastodonServer("secret")
+	defer server.Close()
+	c := mastodon.New(server.URL(), "wrong")
+	_, err := c.PostStatus(context.Background(), &mastodon.Status{Status: "Hello"})
+	assert.EqualError(t, err, "could not publish status: 401 Unauthorized: The access token is invalid")
+	assert.Empty(t, server.Statuses)
+}
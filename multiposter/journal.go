@@ -0,0 +1,322 @@
+package multiposter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/bluesky-social/indigo/api/bsky"
+	"github.com/jtarrio/k3/client"
+	"github.com/jtarrio/k3/internal/atomicfile"
+)
+
+// Journal is an interface for recording the progress of publish operations, so they can be recovered
+// with RecoverFromJournal if the process stops in the middle of one.
+type Journal interface {
+	// Record adds an entry to the journal. The entry must be durably stored when the function returns.
+	Record(entry *JournalEntry) error
+	// Interrupted returns the operations that were begun but not ended, in the order they were begun.
+	Interrupted() ([]*InterruptedPublish, error)
+}
+
+// JournalEntryKind identifies the kind of a journal entry.
+type JournalEntryKind string
+
+const (
+	// JournalBegin is recorded before an operation starts publishing posts.
+	JournalBegin JournalEntryKind = "begin"
+	// JournalIntent is recorded right before publishing a post.
+	JournalIntent JournalEntryKind = "intent"
+	// JournalPublished is recorded right after a post was published.
+	JournalPublished JournalEntryKind = "published"
+	// JournalGatesPublished is recorded after the threadgate and postgate of a post were published.
+	JournalGatesPublished JournalEntryKind = "gates"
+	// JournalEnd is recorded after all the posts of an operation were published.
+	JournalEnd JournalEntryKind = "end"
+)
+
+// JournalEntry is an entry in the journal.
+type JournalEntry struct {
+	// ID identifies the operation the entry belongs to.
+	ID string `json:"id"`
+	// Kind is the kind of entry.
+	Kind JournalEntryKind `json:"kind"`
+	// Thread is true if the posts are published as a thread. Only for JournalBegin.
+	Thread bool `json:"thread,omitempty"`
+	// Posts contains the posts to publish. Only for JournalBegin.
+	Posts []*bsky.FeedPost `json:"posts,omitempty"`
+	// Published contains the posts that had already been published when the operation began, if it
+	// was begun by Resume. Only for JournalBegin.
+	Published []*client.PublishResult `json:"published,omitempty"`
+	// Result is the result of publishing a post. Only for JournalPublished.
+	Result *client.PublishResult `json:"result,omitempty"`
+	// Gates is true if the latest published post needs a threadgate or a postgate that hasn't been published yet.
+	// Only for JournalBegin and JournalPublished.
+	Gates bool `json:"gates,omitempty"`
+}
+
+// InterruptedPublish contains the state of an operation that was begun but not ended.
+type InterruptedPublish struct {
+	// ID identifies the operation.
+	ID string
+	// Thread is true if the posts are published as a thread.
+	Thread bool
+	// Posts contains all the posts the operation was asked to publish.
+	Posts []*bsky.FeedPost
+	// Published contains the results of the posts that are known to have been published.
+	Published []*client.PublishResult
+	// Remaining contains the posts that are not known to have been published.
+	Remaining []*bsky.FeedPost
+	// Uncertain is true if the first remaining post was being published when the operation was interrupted,
+	// so it may or may not have been published.
+	Uncertain bool
+	// GatesPending is true if the threadgate or postgate of the last published post may not have been published.
+	GatesPending bool
+}
+
+// NewMemoryJournal returns a Journal that keeps its entries in memory.
+//
+// This journal doesn't survive a restart, so it's only useful for testing.
+func NewMemoryJournal() Journal {
+	return &memoryJournal{}
+}
+
+type memoryJournal struct {
+	mutex   sync.Mutex
+	entries []*JournalEntry
+}
+
+func (j *memoryJournal) Record(entry *JournalEntry) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.entries = addEntry(j.entries, entry)
+	return nil
+}
+
+func (j *memoryJournal) Interrupted() ([]*InterruptedPublish, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return replay(j.entries), nil
+}
+
+// NewFileJournal returns a Journal that stores its entries in a JSON file.
+//
+// The file is created if it doesn't exist. Every entry rewrites the file atomically, and the entries of
+// an operation are removed from the file when the operation ends.
+func NewFileJournal(path string) (Journal, error) {
+	j := &fileJournal{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file journalFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("could not read journal file %s: %w", path, err)
+	}
+	if file.Version != journalFileVersion {
+		return nil, fmt.Errorf("unsupported journal file version %d", file.Version)
+	}
+	j.entries = file.Entries
+	return j, nil
+}
+
+type fileJournal struct {
+	path    string
+	mutex   sync.Mutex
+	entries []*JournalEntry
+}
+
+const journalFileVersion = 1
+
+type journalFile struct {
+	Version int             `json:"version"`
+	Entries []*JournalEntry `json:"entries"`
+}
+
+func (j *fileJournal) Record(entry *JournalEntry) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	entries := addEntry(slices.Clone(j.entries), entry)
+	data, err := json.MarshalIndent(journalFile{Version: journalFileVersion, Entries: entries}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := atomicfile.Write(j.path, data); err != nil {
+		return err
+	}
+	j.entries = entries
+	return nil
+}
+
+func (j *fileJournal) Interrupted() ([]*InterruptedPublish, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return replay(j.entries), nil
+}
+
+// addEntry appends an entry to the list, or removes all the entries of the operation if it ended.
+func addEntry(entries []*JournalEntry, entry *JournalEntry) []*JournalEntry {
+	if entry.Kind == JournalEnd {
+		return slices.DeleteFunc(entries, func(e *JournalEntry) bool { return e.ID == entry.ID })
+	}
+	return append(entries, entry)
+}
+
+// replay returns the operations that were begun but not ended in the given list of entries.
+func replay(entries []*JournalEntry) []*InterruptedPublish {
+	var out []*InterruptedPublish
+	ops := map[string]*InterruptedPublish{}
+	for _, entry := range entries {
+		if entry.Kind == JournalBegin {
+			op := &InterruptedPublish{
+				ID:           entry.ID,
+				Thread:       entry.Thread,
+				Posts:        entry.Posts,
+				Published:    slices.Clone(entry.Published),
+				Remaining:    entry.Posts,
+				GatesPending: entry.Gates,
+			}
+			ops[entry.ID] = op
+			out = append(out, op)
+			continue
+		}
+		op, found := ops[entry.ID]
+		if !found {
+			continue
+		}
+		switch entry.Kind {
+		case JournalIntent:
+			op.Uncertain = true
+		case JournalPublished:
+			op.Published = append(op.Published, entry.Result)
+			if len(op.Remaining) > 0 {
+				op.Remaining = op.Remaining[1:]
+			}
+			op.Uncertain = false
+			op.GatesPending = entry.Gates
+		case JournalGatesPublished:
+			op.GatesPending = false
+		case JournalEnd:
+			delete(ops, entry.ID)
+			out = slices.DeleteFunc(out, func(o *InterruptedPublish) bool { return o == op })
+		}
+	}
+	return out
+}
+
+// RecoveryPolicy specifies what RecoverFromJournal does with interrupted operations.
+type RecoveryPolicy int
+
+const (
+	// FinishInterrupted publishes the remaining posts of each interrupted operation.
+	//
+	// If a post was being published when the operation was interrupted, it is published again,
+	// so it may appear twice, unless the multiposter uses WithRkeys to make publishing idempotent.
+	FinishInterrupted RecoveryPolicy = iota
+	// RollBackInterrupted deletes the posts that each interrupted operation published, with their threadgates and postgates.
+	// The client must implement client.Deleter.
+	//
+	// If a post was being published when the operation was interrupted, it is not deleted,
+	// as it's not known whether it was published or where.
+	RollBackInterrupted
+)
+
+// RecoverFromJournal finishes or rolls back the operations that were interrupted, according to the policy.
+//
+// The options must be the same ones that were used to create the multiposter for the original operations,
+// except for AsThread and AsSequence, which are taken from the journal. The journal is used to record the
+// progress of the recovery, so if it fails, the operation can be recovered again or resumed with Resume.
+//
+// It returns a result for each interrupted operation. For rolled back operations, Remaining contains all the posts.
+func RecoverFromJournal(ctx context.Context, client client.Client, journal Journal, policy RecoveryPolicy, options ...MultiposterOption) ([]*PublishResult, error) {
+	if policy == FinishInterrupted {
+		if err := newMultiposter(client, options...).checkClient(); err != nil {
+			return nil, err
+		}
+	}
+	interrupted, err := journal.Interrupted()
+	if err != nil {
+		return nil, err
+	}
+	var out []*PublishResult
+	for _, op := range interrupted {
+		m := newMultiposter(client, options...)
+		m.threaded = op.Thread
+		m.journal = journal
+		switch policy {
+		case FinishInterrupted:
+			previous := &PublishResult{Published: op.Published, Remaining: op.Remaining, journalID: op.ID}
+			if op.GatesPending && len(op.Published) > 0 {
+				isRoot := !m.threaded || len(op.Published) == 1
+				previous.pendingGates = &pendingGates{
+					post:       op.Published[len(op.Published)-1],
+					threadgate: m.replyRules != nil && isRoot,
+					postgate:   m.disableQuotes,
+				}
+			}
+			out = append(out, m.Resume(ctx, previous))
+		case RollBackInterrupted:
+			out = append(out, m.rollBack(ctx, op))
+		}
+	}
+	return out, nil
+}
+
+// rollBack deletes the posts published by an interrupted operation, newest first, with their threadgates and postgates.
+func (m multiposter) rollBack(ctx context.Context, op *InterruptedPublish) *PublishResult {
+	deleter, ok := m.client.(client.Deleter)
+	if !ok {
+		return &PublishResult{Published: op.Published, Remaining: op.Remaining, Error: errors.New("the client can't delete posts"), journalID: op.ID}
+	}
+	published := op.Published
+	for len(published) > 0 {
+		var uris []string
+		postUri := published[len(published)-1].Uri
+		if m.replyRules != nil && (!m.threaded || len(published) == 1) {
+			uris = append(uris, gateUri(postUri, "app.bsky.feed.threadgate"))
+		}
+		if m.disableQuotes {
+			uris = append(uris, gateUri(postUri, "app.bsky.feed.postgate"))
+		}
+		for _, uri := range append(uris, postUri) {
+			if err := deleter.Delete(ctx, uri); err != nil {
+				return &PublishResult{Published: published, Remaining: op.Remaining, Error: err, journalID: op.ID}
+			}
+		}
+		published = published[:len(published)-1]
+	}
+	if err := m.record(&JournalEntry{ID: op.ID, Kind: JournalEnd}); err != nil {
+		return &PublishResult{Remaining: op.Posts, Error: err}
+	}
+	return &PublishResult{Remaining: op.Posts}
+}
+
+// gateUri returns the URI of the record in the given collection that has the same record key as the given post,
+// which is where its threadgate or postgate is stored.
+func gateUri(postUri string, collection string) string {
+	repo, _, _ := strings.Cut(strings.TrimPrefix(postUri, "at://"), "/")
+	return fmt.Sprintf("at://%s/%s/%s", repo, collection, postUri[strings.LastIndexByte(postUri, '/')+1:])
+}
+
+// newJournalID returns a random ID for an operation.
+func newJournalID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+func journalError(err error) error {
+	return fmt.Errorf("could not write to the journal: %w", err)
+}
@@ -0,0 +1,125 @@
+package multiposter_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/jtarrio/k3/client"
+	"github.com/jtarrio/k3/multiposter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournalRecordsProgress(t *testing.T) {
+	c := &fakeClient{failAfter: 3}
+	journal := multiposter.NewMemoryJournal()
+	m := multiposter.New(c, multiposter.AsThread(), multiposter.WithJournal(journal))
+	posts := getPosts(10)
+
+	result := m.Publish(context.Background(), posts)
+	assert.Equal(t, errPublish, result.Error)
+	interrupted, err := journal.Interrupted()
+	require.NoError(t, err)
+	require.Len(t, interrupted, 1)
+	assert.True(t, interrupted[0].Thread)
+	assert.Equal(t, posts, interrupted[0].Posts)
+	assert.Equal(t, result.Published, interrupted[0].Published)
+	assert.Equal(t, posts[3:], interrupted[0].Remaining)
+	assert.True(t, interrupted[0].Uncertain)
+	assert.False(t, interrupted[0].GatesPending)
+
+	c.failAfter = -1
+	result = m.Resume(context.Background(), result)
+	assert.NoError(t, result.Error)
+	interrupted, err = journal.Interrupted()
+	require.NoError(t, err)
+	assert.Empty(t, interrupted)
+}
+
+func TestRecoverFinish(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.json")
+	journal, err := multiposter.NewFileJournal(path)
+	require.NoError(t, err)
+	c := &fakeClient{failAfter: 3}
+	posts := getPosts(5)
+	result := multiposter.New(c, multiposter.AsThread(), multiposter.WithJournal(journal)).Publish(context.Background(), posts)
+	assert.Equal(t, errPublish, result.Error)
+
+	// After a restart, the operation is read from the file and finished.
+	c.failAfter = -1
+	journal, err = multiposter.NewFileJournal(path)
+	require.NoError(t, err)
+	results, err := multiposter.RecoverFromJournal(context.Background(), c, journal, multiposter.FinishInterrupted)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.NoError(t, results[0].Error)
+	assert.Len(t, results[0].Published, 5)
+	require.Len(t, c.posts, 5)
+	for i, post := range c.posts {
+		assert.Equal(t, posts[i].Text, post.post.Text)
+		if i > 0 {
+			assert.Equal(t, uriOf(0), post.post.Reply.Root.Uri)
+			assert.Equal(t, uriOf(i-1), post.post.Reply.Parent.Uri)
+		}
+	}
+
+	journal, err = multiposter.NewFileJournal(path)
+	require.NoError(t, err)
+	interrupted, err := journal.Interrupted()
+	require.NoError(t, err)
+	assert.Empty(t, interrupted)
+}
+
+func TestRecoverRollBack(t *testing.T) {
+	journal := multiposter.NewMemoryJournal()
+	c := &fakeClient{failAfter: 3}
+	posts := getPosts(5)
+	multiposter.New(c, multiposter.WithJournal(journal)).Publish(context.Background(), posts)
+
+	results, err := multiposter.RecoverFromJournal(context.Background(), c, journal, multiposter.RollBackInterrupted)
+	require.NoError(t, err)
+	assert.Equal(t, []*multiposter.PublishResult{{Remaining: posts}}, results)
+	assert.Equal(t, []string{uriOf(2), uriOf(1), uriOf(0)}, c.deleted)
+	interrupted, err := journal.Interrupted()
+	require.NoError(t, err)
+	assert.Empty(t, interrupted)
+
+	// The threadgates and postgates are deleted too.
+	c = &fakeClient{failAfter: 2, failGatesAfter: -1}
+	options := []multiposter.MultiposterOption{multiposter.WithReplyRules(), multiposter.WithQuotesDisabled(), multiposter.WithJournal(journal)}
+	multiposter.New(c, options...).Publish(context.Background(), posts)
+	require.Len(t, c.threadgates, 2)
+	require.Len(t, c.postgates, 2)
+
+	results, err = multiposter.RecoverFromJournal(context.Background(), c, journal, multiposter.RollBackInterrupted, options...)
+	require.NoError(t, err)
+	assert.Equal(t, []*multiposter.PublishResult{{Remaining: posts}}, results)
+	assert.Equal(t, []string{
+		"at://xxxx/app.bsky.feed.threadgate/1", "at://xxxx/app.bsky.feed.postgate/1", uriOf(1),
+		"at://xxxx/app.bsky.feed.threadgate/0", "at://xxxx/app.bsky.feed.postgate/0", uriOf(0),
+	}, c.deleted)
+}
+
+func TestRecoverPendingGates(t *testing.T) {
+	journal := multiposter.NewMemoryJournal()
+	c := &fakeClient{failAfter: -1, failGatesAfter: 0}
+	options := []multiposter.MultiposterOption{multiposter.WithReplyRules(multiposter.AllowFollowers()), multiposter.WithJournal(journal)}
+	posts := getPosts(2)
+	result := multiposter.New(c, append(options, multiposter.AsThread())...).Publish(context.Background(), posts)
+	assert.Equal(t, errPublish, result.Error)
+	interrupted, err := journal.Interrupted()
+	require.NoError(t, err)
+	require.Len(t, interrupted, 1)
+	assert.True(t, interrupted[0].GatesPending)
+	assert.Equal(t, []*client.PublishResult{{Uri: uriOf(0), Cid: cidOf(0)}}, interrupted[0].Published)
+
+	c.failGatesAfter = -1
+	results, err := multiposter.RecoverFromJournal(context.Background(), c, journal, multiposter.FinishInterrupted, options...)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.NoError(t, results[0].Error)
+	assert.Len(t, c.posts, 2)
+	require.Len(t, c.threadgates, 1)
+	assert.Equal(t, uriOf(0), c.threadgates[0].Post)
+}
@@ -2,10 +2,15 @@ package multiposter
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/bluesky-social/indigo/api/atproto"
 	"github.com/bluesky-social/indigo/api/bsky"
+	"github.com/jtarrio/k3"
 	"github.com/jtarrio/k3/client"
+	"github.com/jtarrio/k3/tid"
 )
 
 // Multiposter is an interface to post multiple messages at once, either as a sequence of individual messages or as a thread.
@@ -27,13 +32,27 @@ type PublishResult struct {
 	Remaining []*bsky.FeedPost
 	// Error contains the error returned by the last publish operation, if any.
 	Error error
+	// pendingGates contains the threadgate and postgate that could not be published for the last published post.
+	pendingGates *pendingGates
+	// journalID identifies the operation in the journal, if any.
+	journalID string
+}
+
+type pendingGates struct {
+	post       *client.PublishResult
+	threadgate bool
+	postgate   bool
 }
 
 // New creates a new Multiposter with the given client and options.
 //
 // By default, posts are published as a sequence of posts, but you can use the AsThread option to publish them as a thread.
 func New(client client.Client, options ...MultiposterOption) Multiposter {
-	m := &multiposter{client: client}
+	return newMultiposter(client, options...)
+}
+
+func newMultiposter(client client.Client, options ...MultiposterOption) *multiposter {
+	m := &multiposter{client: client, clock: k3.SystemClock(), onProgress: func(Progress) {}, observer: BaseObserver{}}
 	for _, option := range options {
 		option(m)
 	}
@@ -60,50 +79,356 @@ func AsSequence() MultiposterOption {
 	}
 }
 
+// WithReplyRules limits who can reply to the published posts by creating a threadgate on the root of each thread.
+//
+// Only the users that match at least one of the rules can reply. If no rules are given, nobody can reply.
+// When the posts are published as a sequence, every post gets a threadgate.
+// The client must implement client.GatePublisher.
+func WithReplyRules(rules ...*bsky.FeedThreadgate_Allow_Elem) MultiposterOption {
+	return func(m *multiposter) {
+		m.replyRules = append([]*bsky.FeedThreadgate_Allow_Elem{}, rules...)
+	}
+}
+
+// AllowMentioned returns a reply rule that allows the users mentioned in the post to reply.
+func AllowMentioned() *bsky.FeedThreadgate_Allow_Elem {
+	return &bsky.FeedThreadgate_Allow_Elem{FeedThreadgate_MentionRule: &bsky.FeedThreadgate_MentionRule{}}
+}
+
+// AllowFollowers returns a reply rule that allows the users who follow the poster to reply.
+func AllowFollowers() *bsky.FeedThreadgate_Allow_Elem {
+	return &bsky.FeedThreadgate_Allow_Elem{FeedThreadgate_FollowerRule: &bsky.FeedThreadgate_FollowerRule{}}
+}
+
+// AllowFollowing returns a reply rule that allows the users followed by the poster to reply.
+func AllowFollowing() *bsky.FeedThreadgate_Allow_Elem {
+	return &bsky.FeedThreadgate_Allow_Elem{FeedThreadgate_FollowingRule: &bsky.FeedThreadgate_FollowingRule{}}
+}
+
+// AllowList returns a reply rule that allows the members of the list with the given URI to reply.
+func AllowList(listUri string) *bsky.FeedThreadgate_Allow_Elem {
+	return &bsky.FeedThreadgate_Allow_Elem{FeedThreadgate_ListRule: &bsky.FeedThreadgate_ListRule{List: listUri}}
+}
+
+// WithClock makes the multiposter use the given clock to assign creation times to threadgates and postgates, and to wait between posts.
+func WithClock(clock k3.Clock) MultiposterOption {
+	return func(m *multiposter) {
+		m.clock = clock
+	}
+}
+
+// WithQuotesDisabled prevents the published posts from being quoted by creating a postgate for each post.
+// The client must implement client.GatePublisher.
+func WithQuotesDisabled() MultiposterOption {
+	return func(m *multiposter) {
+		m.disableQuotes = true
+	}
+}
+
+// WithMinimumDelay makes the multiposter wait at least the given time between consecutive posts.
+func WithMinimumDelay(delay time.Duration) MultiposterOption {
+	return func(m *multiposter) {
+		m.minDelay = delay
+	}
+}
+
+// WithRateLimiter makes the multiposter wait for the given rate limiter before publishing each post.
+//
+// Threadgates and postgates are not paced, and are published right after their post.
+func WithRateLimiter(limiter *RateLimiter) MultiposterOption {
+	return func(m *multiposter) {
+		m.limiter = limiter
+	}
+}
+
+// WithProgressHandler sets a function that is called every time the multiposter has to wait before publishing a post.
+//
+// If the context is done while waiting, Publish and Resume return early with the context's error.
+func WithProgressHandler(handler func(progress Progress)) MultiposterOption {
+	return func(m *multiposter) {
+		m.onProgress = handler
+	}
+}
+
+// RkeyFunc returns the record key to use for a post.
+type RkeyFunc func(post *bsky.FeedPost) (string, error)
+
+// WithRkeys makes the multiposter publish each post with the record key returned by the given function.
+//
+// If the function always returns the same record key for the same post, publishing is idempotent:
+// when Resume or RecoverFromJournal retry a post that was actually saved, the existing post is used
+// instead of creating a duplicate. The client must implement client.RkeyPublisher.
+func WithRkeys(rkeys RkeyFunc) MultiposterOption {
+	return func(m *multiposter) {
+		m.rkeys = rkeys
+	}
+}
+
+// CreationTimeRkeys returns an RkeyFunc that generates a TID from the post's creation time and the given clock identifier,
+// which must be between 0 and tid.MaxClockID. It panics if the clock identifier is out of range.
+//
+// Posts that are published together must have different creation times, which you can ensure by using
+// a k3.NewIncreasingClock in the posts.Converter.
+func CreationTimeRkeys(clockID uint) RkeyFunc {
+	if clockID > tid.MaxClockID {
+		panic(fmt.Sprintf("clock identifier %d is greater than %d", clockID, tid.MaxClockID))
+	}
+	return func(post *bsky.FeedPost) (string, error) {
+		createdAt, err := time.Parse(time.RFC3339Nano, post.CreatedAt)
+		if err != nil {
+			return "", fmt.Errorf("invalid creation time '%s': %w", post.CreatedAt, err)
+		}
+		return tid.FromTime(createdAt, clockID), nil
+	}
+}
+
+// WithJournal makes the multiposter record its progress in the given journal,
+// so the operations that are interrupted can be recovered with RecoverFromJournal.
+//
+// An operation stays in the journal until all its posts are published, so if Publish or Resume fail,
+// the operation can still be recovered later.
+func WithJournal(journal Journal) MultiposterOption {
+	return func(m *multiposter) {
+		m.journal = journal
+	}
+}
+
 type MultiposterOption func(*multiposter)
 
 type multiposter struct {
-	client   client.Client
-	threaded bool
+	client        client.Client
+	clock         k3.Clock
+	threaded      bool
+	replyRules    []*bsky.FeedThreadgate_Allow_Elem
+	disableQuotes bool
+	journal       Journal
+	rkeys         RkeyFunc
+	minDelay      time.Duration
+	limiter       *RateLimiter
+	onProgress    func(Progress)
+	observer      Observer
 }
 
 func (m multiposter) Publish(ctx context.Context, posts []*bsky.FeedPost) *PublishResult {
-	return m.doPublish(ctx, posts, nil)
+	if err := m.checkClient(); err != nil {
+		m.observer.Failed(0, err)
+		return &PublishResult{Remaining: posts, Error: err}
+	}
+	journalID, err := m.begin(&JournalEntry{Posts: posts})
+	if err != nil {
+		m.observer.Failed(0, err)
+		return &PublishResult{Remaining: posts, Error: err}
+	}
+	return m.doPublish(ctx, posts, nil, journalID)
 }
 
 func (m multiposter) Resume(ctx context.Context, previousResult *PublishResult) *PublishResult {
-	return m.doPublish(ctx, previousResult.Remaining, previousResult.Published)
+	next := len(previousResult.Published)
+	if previousResult.pendingGates != nil {
+		next--
+	}
+	if err := m.checkClient(); err != nil {
+		m.observer.Failed(next, err)
+		result := *previousResult
+		result.Error = err
+		return &result
+	}
+	if len(previousResult.Remaining) > 0 || previousResult.pendingGates != nil {
+		m.observer.Retrying(next)
+	}
+	journalID := previousResult.journalID
+	if journalID == "" {
+		var err error
+		journalID, err = m.begin(&JournalEntry{
+			Posts:     previousResult.Remaining,
+			Published: previousResult.Published,
+			Gates:     previousResult.pendingGates != nil,
+		})
+		if err != nil {
+			m.observer.Failed(next, err)
+			result := *previousResult
+			result.Error = err
+			return &result
+		}
+	}
+	if previousResult.pendingGates != nil {
+		gates := *previousResult.pendingGates
+		err := m.publishGates(ctx, &gates)
+		if err == nil {
+			err = m.record(&JournalEntry{ID: journalID, Kind: JournalGatesPublished})
+		}
+		if err != nil {
+			m.observer.Failed(next, err)
+			result := *previousResult
+			result.Error = err
+			result.pendingGates = &gates
+			result.journalID = journalID
+			return &result
+		}
+	}
+	return m.doPublish(ctx, previousResult.Remaining, previousResult.Published, journalID)
 }
 
-func (m multiposter) doPublish(ctx context.Context, posts []*bsky.FeedPost, previousResults []*client.PublishResult) *PublishResult {
+func (m multiposter) doPublish(ctx context.Context, posts []*bsky.FeedPost, previousResults []*client.PublishResult, journalID string) *PublishResult {
 	var threadRoot, threadParent *client.PublishResult
 	if len(previousResults) > 0 {
 		threadRoot = previousResults[0]
 		threadParent = previousResults[len(previousResults)-1]
 	}
-	result := &PublishResult{Published: previousResults}
+	result := &PublishResult{Published: previousResults, journalID: journalID}
+	fail := func(index int, remaining []*bsky.FeedPost, err error) *PublishResult {
+		m.observer.Failed(index, err)
+		result.Remaining = remaining
+		result.Error = err
+		return result
+	}
+	total := len(previousResults) + len(posts)
+	var previous time.Time
 	for i := range posts {
+		index := len(result.Published)
+		if err := m.pace(ctx, index, total, previous); err != nil {
+			return fail(index, posts[i:], err)
+		}
 		var thisPost *bsky.FeedPost
 		if m.threaded && threadParent != nil && threadRoot != nil {
 			thisPost = setReplyField(posts[i], threadParent, threadRoot)
 		} else {
 			thisPost = posts[i]
 		}
-		singleResult, err := m.client.Publish(ctx, thisPost)
+		if err := m.record(&JournalEntry{ID: journalID, Kind: JournalIntent}); err != nil {
+			return fail(index, posts[i:], err)
+		}
+		singleResult, err := m.publishPost(ctx, thisPost)
+		previous = m.clock.Now()
 		if err != nil {
-			result.Remaining = posts[i:]
-			result.Error = err
-			return result
+			return fail(index, posts[i:], err)
 		}
+		isRoot := threadRoot == nil || !m.threaded
 		threadParent = singleResult
 		if threadRoot == nil {
 			threadRoot = singleResult
 		}
 		result.Published = append(result.Published, singleResult)
+		m.observer.Published(index, singleResult)
+		gates := &pendingGates{post: singleResult, threadgate: m.replyRules != nil && isRoot, postgate: m.disableQuotes}
+		needGates := gates.threadgate || gates.postgate
+		err = m.record(&JournalEntry{ID: journalID, Kind: JournalPublished, Result: singleResult, Gates: needGates})
+		if err == nil {
+			err = m.publishGates(ctx, gates)
+		}
+		if err == nil && needGates {
+			err = m.record(&JournalEntry{ID: journalID, Kind: JournalGatesPublished})
+		}
+		if err != nil {
+			if needGates {
+				result.pendingGates = gates
+			}
+			return fail(index, posts[i+1:], err)
+		}
+	}
+	if err := m.record(&JournalEntry{ID: journalID, Kind: JournalEnd}); err != nil {
+		return fail(total, nil, err)
 	}
+	m.observer.Completed(result)
 	return result
 }
 
+// begin records the beginning of an operation in the journal, if there is one, and returns the operation's ID.
+func (m multiposter) begin(entry *JournalEntry) (string, error) {
+	if m.journal == nil {
+		return "", nil
+	}
+	id, err := newJournalID()
+	if err != nil {
+		return "", err
+	}
+	entry.ID = id
+	entry.Kind = JournalBegin
+	entry.Thread = m.threaded
+	if err := m.record(entry); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// record adds an entry to the journal, if there is one.
+func (m multiposter) record(entry *JournalEntry) error {
+	if m.journal == nil || entry.ID == "" {
+		return nil
+	}
+	if err := m.journal.Record(entry); err != nil {
+		return journalError(err)
+	}
+	return nil
+}
+
+// checkClient returns an error if the client doesn't implement the optional interfaces that the options need,
+// so the operation fails before it publishes anything.
+func (m multiposter) checkClient() error {
+	if _, ok := m.client.(client.RkeyPublisher); m.rkeys != nil && !ok {
+		return errors.New("the client can't publish posts with record keys")
+	}
+	if _, ok := m.client.(client.GatePublisher); (m.replyRules != nil || m.disableQuotes) && !ok {
+		return errors.New("the client can't publish threadgates and postgates")
+	}
+	return nil
+}
+
+// publishPost publishes a single post, with a record key if the multiposter has an RkeyFunc.
+func (m multiposter) publishPost(ctx context.Context, post *bsky.FeedPost) (*client.PublishResult, error) {
+	if m.rkeys == nil {
+		return m.client.Publish(ctx, post)
+	}
+	rkey, err := m.rkeys(post)
+	if err != nil {
+		return nil, err
+	}
+	publisher, ok := m.client.(client.RkeyPublisher)
+	if !ok {
+		return nil, errors.New("the client can't publish posts with record keys")
+	}
+	return publisher.PublishWithRkey(ctx, post, rkey)
+}
+
+// publishGates creates the pending threadgate and postgate for a post, and marks them as done as they are published.
+func (m multiposter) publishGates(ctx context.Context, gates *pendingGates) error {
+	if !gates.threadgate && !gates.postgate {
+		return nil
+	}
+	publisher, ok := m.client.(client.GatePublisher)
+	if !ok {
+		return errors.New("the client can't publish threadgates and postgates")
+	}
+	createdAt := m.clock.Now().UTC().Format("2006-01-02T15:04:05.999Z07:00")
+	if gates.threadgate {
+		threadgate := &bsky.FeedThreadgate{
+			LexiconTypeID: "app.bsky.feed.threadgate",
+			Allow:         m.replyRules,
+			CreatedAt:     createdAt,
+			Post:          gates.post.Uri,
+		}
+		if _, err := publisher.PublishThreadgate(ctx, threadgate); err != nil {
+			return err
+		}
+		gates.threadgate = false
+	}
+	if gates.postgate {
+		postgate := &bsky.FeedPostgate{
+			LexiconTypeID: "app.bsky.feed.postgate",
+			CreatedAt:     createdAt,
+			EmbeddingRules: []*bsky.FeedPostgate_EmbeddingRules_Elem{
+				{FeedPostgate_DisableRule: &bsky.FeedPostgate_DisableRule{}},
+			},
+			Post: gates.post.Uri,
+		}
+		if _, err := publisher.PublishPostgate(ctx, postgate); err != nil {
+			return err
+		}
+		gates.postgate = false
+	}
+	return nil
+}
+
 func setReplyField(thisPost *bsky.FeedPost, threadParent *client.PublishResult, threadRoot *client.PublishResult) *bsky.FeedPost {
 	postCopy := *thisPost
 	postCopy.Reply = &bsky.FeedPost_ReplyRef{
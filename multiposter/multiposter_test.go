@@ -15,6 +15,7 @@ import (
 	"github.com/jtarrio/k3/posts"
 	atptesting "github.com/jtarrio/k3/testing"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestPostSequence(t *testing.T) {
@@ -174,6 +175,155 @@ func TestResumeThread(t *testing.T) {
 	assert.Equal(t, expectedPublished, c.posts)
 }
 
+func TestThreadgateOnThreadRoot(t *testing.T) {
+	clock := &atptesting.FakeClock{Time: time.Date(2025, time.January, 2, 12, 34, 56, 789000000, time.UTC)}
+	c := &fakeClient{failAfter: -1, failGatesAfter: -1}
+	m := multiposter.New(c, multiposter.AsThread(), multiposter.WithClock(clock),
+		multiposter.WithReplyRules(multiposter.AllowMentioned(), multiposter.AllowList("at://xxxx/app.bsky.graph.list/1")))
+	result := m.Publish(context.Background(), getPosts(3))
+	assert.NoError(t, result.Error)
+	assert.Len(t, result.Published, 3)
+
+	expected := []*bsky.FeedThreadgate{{
+		LexiconTypeID: "app.bsky.feed.threadgate",
+		Allow: []*bsky.FeedThreadgate_Allow_Elem{
+			{FeedThreadgate_MentionRule: &bsky.FeedThreadgate_MentionRule{}},
+			{FeedThreadgate_ListRule: &bsky.FeedThreadgate_ListRule{List: "at://xxxx/app.bsky.graph.list/1"}},
+		},
+		CreatedAt: "2025-01-02T12:34:56.789Z",
+		Post:      uriOf(0),
+	}}
+	assert.Equal(t, expected, c.threadgates)
+	assert.Empty(t, c.postgates)
+}
+
+func TestThreadgateOnEachPostInSequence(t *testing.T) {
+	c := &fakeClient{failAfter: -1, failGatesAfter: -1}
+	m := multiposter.New(c, multiposter.WithReplyRules())
+	result := m.Publish(context.Background(), getPosts(3))
+	assert.NoError(t, result.Error)
+
+	assert.Len(t, c.threadgates, 3)
+	for i, threadgate := range c.threadgates {
+		assert.Equal(t, uriOf(i), threadgate.Post)
+		assert.NotNil(t, threadgate.Allow)
+		assert.Empty(t, threadgate.Allow)
+	}
+}
+
+func TestNobodyCanReplyOnServer(t *testing.T) {
+	fakeServer := atptesting.NewFakeServer()
+	fakeServer.AddUser("testuser", "testpass")
+	defer fakeServer.Close()
+	c := client.New("testuser", "testpass", client.WithHost(fakeServer.URL()))
+	result := multiposter.New(c, multiposter.WithReplyRules()).Publish(context.Background(), getPosts(1))
+	assert.NoError(t, result.Error)
+
+	// The record must contain an empty list of rules, because a record without a list lets everybody reply.
+	require.Len(t, fakeServer.Threadgates, 1)
+	assert.NotNil(t, fakeServer.Threadgates[0].Record.Allow)
+	assert.Empty(t, fakeServer.Threadgates[0].Record.Allow)
+}
+
+func TestQuotesDisabled(t *testing.T) {
+	clock := &atptesting.FakeClock{Time: time.Date(2025, time.January, 2, 12, 34, 56, 789000000, time.UTC)}
+	c := &fakeClient{failAfter: -1, failGatesAfter: -1}
+	m := multiposter.New(c, multiposter.AsThread(), multiposter.WithClock(clock), multiposter.WithQuotesDisabled())
+	result := m.Publish(context.Background(), getPosts(2))
+	assert.NoError(t, result.Error)
+
+	var expected []*bsky.FeedPostgate
+	for i := range 2 {
+		expected = append(expected, &bsky.FeedPostgate{
+			LexiconTypeID: "app.bsky.feed.postgate",
+			CreatedAt:     "2025-01-02T12:34:56.789Z",
+			EmbeddingRules: []*bsky.FeedPostgate_EmbeddingRules_Elem{
+				{FeedPostgate_DisableRule: &bsky.FeedPostgate_DisableRule{}},
+			},
+			Post: uriOf(i),
+		})
+	}
+	assert.Equal(t, expected, c.postgates)
+	assert.Empty(t, c.threadgates)
+}
+
+func TestResumeAfterGateFailure(t *testing.T) {
+	c := &fakeClient{failAfter: -1, failGatesAfter: 1}
+	m := multiposter.New(c, multiposter.AsThread(), multiposter.WithReplyRules(multiposter.AllowFollowers()), multiposter.WithQuotesDisabled())
+	posts := getPosts(3)
+	result := m.Publish(context.Background(), posts)
+	assert.Equal(t, errPublish, result.Error)
+	assert.Len(t, result.Published, 1)
+	assert.Equal(t, posts[1:], result.Remaining)
+	assert.Len(t, c.threadgates, 1)
+	assert.Empty(t, c.postgates)
+
+	c.failGatesAfter = -1
+	result = m.Resume(context.Background(), result)
+	assert.NoError(t, result.Error)
+	assert.Len(t, result.Published, 3)
+	assert.Empty(t, result.Remaining)
+	assert.Len(t, c.posts, 3)
+	assert.Len(t, c.threadgates, 1)
+	assert.Len(t, c.postgates, 3)
+	for i, postgate := range c.postgates {
+		assert.Equal(t, uriOf(i), postgate.Post)
+	}
+}
+
+func TestIdempotentRetry(t *testing.T) {
+	c := &fakeClient{failAfter: -1, loseResponses: true}
+	converter := posts.NewConverter(posts.WithClock(k3.NewIncreasingClock(&atptesting.FakeClock{Time: time.Date(2025, time.January, 2, 12, 34, 56, 789000000, time.UTC)})))
+	var feedPosts []*bsky.FeedPost
+	for i := range 3 {
+		feedPosts = append(feedPosts, converter.ToFeedPost(k3.NewPost().AddText(fmt.Sprintf("This is post #%d", i+1))))
+	}
+	m := multiposter.New(c, multiposter.AsThread(), multiposter.WithRkeys(multiposter.CreationTimeRkeys(7)))
+	result := m.Publish(context.Background(), feedPosts)
+	assert.Equal(t, errPublish, result.Error)
+	assert.Empty(t, result.Published)
+	assert.Len(t, c.posts, 1)
+
+	c.loseResponses = false
+	result = m.Resume(context.Background(), result)
+	assert.NoError(t, result.Error)
+	assert.Len(t, result.Published, 3)
+	assert.Len(t, c.posts, 3)
+	assert.Len(t, c.rkeys, 3)
+	assert.Contains(t, c.rkeys, "3ler3lwntkc2b")
+}
+
+func TestCreationTimeRkeys(t *testing.T) {
+	rkeys := multiposter.CreationTimeRkeys(7)
+	rkey, err := rkeys(&bsky.FeedPost{CreatedAt: "2025-01-02T12:34:56.789Z"})
+	assert.NoError(t, err)
+	assert.Equal(t, "3ler3lwntkc2b", rkey)
+	_, err = rkeys(&bsky.FeedPost{CreatedAt: "yesterday"})
+	assert.Error(t, err)
+	assert.Panics(t, func() { multiposter.CreationTimeRkeys(1024) })
+}
+
+func TestClientWithoutOptionalInterfaces(t *testing.T) {
+	// Embedding the interface hides the methods of the optional interfaces.
+	c := struct{ client.Client }{&fakeClient{failAfter: -1}}
+	result := multiposter.New(c, multiposter.WithQuotesDisabled()).Publish(context.Background(), getPosts(1))
+	assert.EqualError(t, result.Error, "the client can't publish threadgates and postgates")
+	assert.Empty(t, result.Published)
+	assert.Empty(t, c.Client.(*fakeClient).posts)
+
+	result = multiposter.New(c, multiposter.WithRkeys(multiposter.CreationTimeRkeys(7))).Publish(context.Background(), getPosts(1))
+	assert.EqualError(t, result.Error, "the client can't publish posts with record keys")
+	assert.Empty(t, result.Published)
+
+	result = multiposter.New(c, multiposter.WithReplyRules()).Resume(context.Background(), &multiposter.PublishResult{Remaining: getPosts(1)})
+	assert.EqualError(t, result.Error, "the client can't publish threadgates and postgates")
+	assert.Empty(t, result.Published)
+
+	_, err := multiposter.RecoverFromJournal(context.Background(), c, multiposter.NewMemoryJournal(), multiposter.FinishInterrupted, multiposter.WithReplyRules())
+	assert.EqualError(t, err, "the client can't publish threadgates and postgates")
+	assert.Empty(t, c.Client.(*fakeClient).posts)
+}
+
 func getPosts(count int) []*bsky.FeedPost {
 	converter := posts.NewConverter(posts.WithClock(&atptesting.FakeClock{Time: time.Date(2025, time.January, 2, 12, 34, 56, 789000000, time.UTC)}))
 	var out []*bsky.FeedPost
@@ -185,8 +335,15 @@ func getPosts(count int) []*bsky.FeedPost {
 }
 
 type fakeClient struct {
-	posts     []publishedPost
-	failAfter int
+	posts          []publishedPost
+	threadgates    []*bsky.FeedThreadgate
+	postgates      []*bsky.FeedPostgate
+	deleted        []string
+	rkeys          map[string]*client.PublishResult
+	failAfter      int
+	failGatesAfter int
+	// loseResponses makes the client save the post and then fail, as if the response was lost.
+	loseResponses bool
 }
 
 type publishedPost struct {
@@ -216,12 +373,49 @@ func (f *fakeClient) Publish(ctx context.Context, post *bsky.FeedPost) (*client.
 	}, nil
 }
 
-func cidOf(i int) string {
-	return fmt.Sprintf("%d", i)
+func (f *fakeClient) PublishWithRkey(ctx context.Context, post *bsky.FeedPost, rkey string) (*client.PublishResult, error) {
+	if result, found := f.rkeys[rkey]; found {
+		return result, nil
+	}
+	result, err := f.Publish(ctx, post)
+	if err != nil {
+		return nil, err
+	}
+	if f.rkeys == nil {
+		f.rkeys = map[string]*client.PublishResult{}
+	}
+	f.rkeys[rkey] = result
+	if f.loseResponses {
+		return nil, errPublish
+	}
+	return result, nil
 }
 
-func uriOf(i int) string {
-	return fmt.Sprintf("at://xxxx/yyyy/%d", i)
+func (f *fakeClient) PublishThreadgate(ctx context.Context, threadgate *bsky.FeedThreadgate) (*client.PublishResult, error) {
+	if f.failGatesAfter == 0 {
+		return nil, errPublish
+	}
+	f.threadgates = append(f.threadgates, threadgate)
+	if f.failGatesAfter > 0 {
+		f.failGatesAfter--
+	}
+	return &client.PublishResult{Uri: threadgate.Post, Cid: "threadgate"}, nil
+}
+
+func (f *fakeClient) PublishPostgate(ctx context.Context, postgate *bsky.FeedPostgate) (*client.PublishResult, error) {
+	if f.failGatesAfter == 0 {
+		return nil, errPublish
+	}
+	f.postgates = append(f.postgates, postgate)
+	if f.failGatesAfter > 0 {
+		f.failGatesAfter--
+	}
+	return &client.PublishResult{Uri: postgate.Post, Cid: "postgate"}, nil
+}
+
+func (f *fakeClient) Delete(ctx context.Context, uri string) error {
+	f.deleted = append(f.deleted, uri)
+	return nil
 }
 
 func (f *fakeClient) FindUserByHandle(ctx context.Context, handle string) (*client.UserData, error) {
@@ -231,3 +425,11 @@ func (f *fakeClient) FindUserByHandle(ctx context.Context, handle string) (*clie
 func (f *fakeClient) GetAccessToken(ctx context.Context) error {
 	panic("unimplemented")
 }
+
+func cidOf(i int) string {
+	return fmt.Sprintf("%d", i)
+}
+
+func uriOf(i int) string {
+	return fmt.Sprintf("at://xxxx/yyyy/%d", i)
+}
@@ -0,0 +1,37 @@
+package multiposter
+
+import "github.com/jtarrio/k3/client"
+
+// Observer receives the events of a multiposter's operations as they happen.
+//
+// The index of a post is its position in the whole series of posts, counting the posts that were published
+// before a call to Resume. Embed BaseObserver in your type to implement only the methods you need.
+type Observer interface {
+	// Published is called after each post is published, before its threadgate and postgate.
+	Published(index int, result *client.PublishResult)
+	// Retrying is called when Resume starts publishing again the post with the given index, or its threadgate and postgate.
+	Retrying(index int)
+	// Failed is called when publishing the post with the given index, or its threadgate or postgate, fails.
+	// If the operation fails after publishing all its posts, the index is the number of posts.
+	Failed(index int, err error)
+	// Waiting is called before the multiposter waits to publish the next post.
+	Waiting(progress Progress)
+	// Completed is called after all the posts were published.
+	Completed(result *PublishResult)
+}
+
+// BaseObserver is an Observer that ignores all the events.
+type BaseObserver struct{}
+
+func (BaseObserver) Published(index int, result *client.PublishResult) {}
+func (BaseObserver) Retrying(index int)                                {}
+func (BaseObserver) Failed(index int, err error)                       {}
+func (BaseObserver) Waiting(progress Progress)                         {}
+func (BaseObserver) Completed(result *PublishResult)                   {}
+
+// WithObserver makes the multiposter report the events of its operations to the given observer.
+func WithObserver(observer Observer) MultiposterOption {
+	return func(m *multiposter) {
+		m.observer = observer
+	}
+}
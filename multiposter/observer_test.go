@@ -0,0 +1,71 @@
+package multiposter_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jtarrio/k3/client"
+	"github.com/jtarrio/k3/multiposter"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingObserver struct {
+	multiposter.BaseObserver
+	events []string
+}
+
+func (o *recordingObserver) Published(index int, result *client.PublishResult) {
+	o.events = append(o.events, fmt.Sprintf("published %d %s %s", index, result.Uri, result.Cid))
+}
+
+func (o *recordingObserver) Retrying(index int) {
+	o.events = append(o.events, fmt.Sprintf("retrying %d", index))
+}
+
+func (o *recordingObserver) Failed(index int, err error) {
+	o.events = append(o.events, fmt.Sprintf("failed %d: %v", index, err))
+}
+
+func (o *recordingObserver) Completed(result *multiposter.PublishResult) {
+	o.events = append(o.events, fmt.Sprintf("completed %d", len(result.Published)))
+}
+
+func TestObserver(t *testing.T) {
+	c := &fakeClient{failAfter: 2}
+	observer := &recordingObserver{}
+	m := multiposter.New(c, multiposter.AsThread(), multiposter.WithObserver(observer))
+
+	result := m.Publish(context.Background(), getPosts(3))
+	assert.Equal(t, []string{
+		fmt.Sprintf("published 0 %s %s", uriOf(0), cidOf(0)),
+		fmt.Sprintf("published 1 %s %s", uriOf(1), cidOf(1)),
+		fmt.Sprintf("failed 2: %v", errPublish),
+	}, observer.events)
+
+	observer.events = nil
+	c.failAfter = -1
+	m.Resume(context.Background(), result)
+	assert.Equal(t, []string{
+		"retrying 2",
+		fmt.Sprintf("published 2 %s %s", uriOf(2), cidOf(2)),
+		"completed 3",
+	}, observer.events)
+}
+
+func TestObserverPendingGates(t *testing.T) {
+	c := &fakeClient{failAfter: -1, failGatesAfter: 0}
+	observer := &recordingObserver{}
+	m := multiposter.New(c, multiposter.WithQuotesDisabled(), multiposter.WithObserver(observer))
+
+	result := m.Publish(context.Background(), getPosts(2))
+	c.failGatesAfter = -1
+	m.Resume(context.Background(), result)
+	assert.Equal(t, []string{
+		fmt.Sprintf("published 0 %s %s", uriOf(0), cidOf(0)),
+		fmt.Sprintf("failed 0: %v", errPublish),
+		"retrying 0",
+		fmt.Sprintf("published 1 %s %s", uriOf(1), cidOf(1)),
+		"completed 2",
+	}, observer.events)
+}
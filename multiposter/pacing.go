@@ -0,0 +1,130 @@
+package multiposter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jtarrio/k3"
+)
+
+// Progress describes the state of a publish operation when it has to wait before publishing the next post.
+type Progress struct {
+	// Published is the number of posts that have already been published.
+	Published int
+	// Total is the total number of posts in the operation.
+	Total int
+	// Wait is how long the multiposter will wait before publishing the next post.
+	Wait time.Duration
+}
+
+// NewRateLimiter creates a token bucket that allows publishing the given number of posts per period,
+// with bursts of up to the given size.
+//
+// A RateLimiter can be shared by several multiposters that publish to the same account, so together
+// they don't exceed the limit. Only posts are counted: the threadgates and postgates that a multiposter
+// publishes for a post are published right after it, without waiting or taking a token.
+//
+// It panics if posts or per are not positive, or if burst is less than 1.
+func NewRateLimiter(posts int, per time.Duration, burst int, options ...RateLimiterOption) *RateLimiter {
+	if posts <= 0 {
+		panic("non-positive number of posts for NewRateLimiter")
+	}
+	if per <= 0 {
+		panic("non-positive period for NewRateLimiter")
+	}
+	if burst < 1 {
+		panic("burst smaller than 1 for NewRateLimiter")
+	}
+	r := &RateLimiter{
+		clock:  k3.SystemClock(),
+		rate:   float64(posts) / per.Seconds(),
+		burst:  float64(burst),
+		tokens: float64(burst),
+	}
+	for _, option := range options {
+		option(r)
+	}
+	return r
+}
+
+// WithRateLimiterClock makes the rate limiter use the given clock to refill the bucket and to wait.
+func WithRateLimiterClock(clock k3.Clock) RateLimiterOption {
+	return func(r *RateLimiter) {
+		r.clock = clock
+	}
+}
+
+type RateLimiterOption func(*RateLimiter)
+
+// RateLimiter limits how often posts are published, using a token bucket.
+type RateLimiter struct {
+	clock  k3.Clock
+	rate   float64
+	burst  float64
+	mutex  sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// Wait waits until a post can be published, or the context is done.
+func (r *RateLimiter) Wait(ctx context.Context) error {
+	return r.wait(ctx, r.reserve())
+}
+
+// reserve takes a token from the bucket and returns how long to wait until the token is available.
+//
+// Callers that wait in line are served in order, as each reservation can make the bucket go further into debt.
+func (r *RateLimiter) reserve() time.Duration {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	now := r.clock.Now()
+	if !r.last.IsZero() && now.After(r.last) {
+		r.tokens = min(r.burst, r.tokens+now.Sub(r.last).Seconds()*r.rate)
+	}
+	if now.After(r.last) {
+		r.last = now
+	}
+	r.tokens--
+	if r.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-r.tokens / r.rate * float64(time.Second))
+}
+
+// wait sleeps for the reserved time, and gives the token back if the context is done first.
+func (r *RateLimiter) wait(ctx context.Context, d time.Duration) error {
+	if err := r.clock.Sleep(ctx, d); err != nil {
+		r.mutex.Lock()
+		r.tokens = min(r.burst, r.tokens+1)
+		r.mutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+// pace waits before publishing a post until the minimum delay since the previous post has elapsed
+// and the rate limiter allows it, reporting the waits to the progress handler.
+func (m multiposter) pace(ctx context.Context, published int, total int, previous time.Time) error {
+	if m.minDelay > 0 && !previous.IsZero() {
+		if d := previous.Add(m.minDelay).Sub(m.clock.Now()); d > 0 {
+			m.waiting(Progress{Published: published, Total: total, Wait: d})
+			if err := m.clock.Sleep(ctx, d); err != nil {
+				return err
+			}
+		}
+	}
+	if m.limiter != nil {
+		d := m.limiter.reserve()
+		if d > 0 {
+			m.waiting(Progress{Published: published, Total: total, Wait: d})
+		}
+		return m.limiter.wait(ctx, d)
+	}
+	return nil
+}
+
+func (m multiposter) waiting(progress Progress) {
+	m.onProgress(progress)
+	m.observer.Waiting(progress)
+}
@@ -0,0 +1,107 @@
+package multiposter_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jtarrio/k3/multiposter"
+	atptesting "github.com/jtarrio/k3/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pacingStart = time.Date(2025, time.January, 2, 12, 34, 56, 0, time.UTC)
+
+// publishAsync publishes the given number of posts in a goroutine and returns a channel that receives the result.
+func publishAsync(ctx context.Context, m multiposter.Multiposter, count int) <-chan *multiposter.PublishResult {
+	done := make(chan *multiposter.PublishResult, 1)
+	go func() {
+		done <- m.Publish(ctx, getPosts(count))
+	}()
+	return done
+}
+
+func TestMinimumDelay(t *testing.T) {
+	clock := &atptesting.FakeClock{Time: pacingStart}
+	c := &fakeClient{failAfter: -1}
+	progress := make(chan multiposter.Progress, 10)
+	m := multiposter.New(c, multiposter.WithClock(clock), multiposter.WithMinimumDelay(10*time.Second),
+		multiposter.WithProgressHandler(func(p multiposter.Progress) { progress <- p }))
+	done := publishAsync(context.Background(), m, 3)
+
+	for i := range 2 {
+		clock.BlockUntil(1)
+		assert.Equal(t, multiposter.Progress{Published: i + 1, Total: 3, Wait: 10 * time.Second}, <-progress)
+		clock.Advance(10 * time.Second)
+	}
+	result := <-done
+	assert.NoError(t, result.Error)
+	assert.Len(t, result.Published, 3)
+	assert.Equal(t, pacingStart.Add(20*time.Second), clock.Now())
+}
+
+func TestSharedRateLimiter(t *testing.T) {
+	clock := &atptesting.FakeClock{Time: pacingStart}
+	limiter := multiposter.NewRateLimiter(1, time.Minute, 2, multiposter.WithRateLimiterClock(clock))
+	progress := make(chan multiposter.Progress, 10)
+	first := multiposter.New(&fakeClient{failAfter: -1}, multiposter.WithClock(clock), multiposter.WithRateLimiter(limiter))
+	second := multiposter.New(&fakeClient{failAfter: -1}, multiposter.WithClock(clock), multiposter.WithRateLimiter(limiter),
+		multiposter.WithProgressHandler(func(p multiposter.Progress) { progress <- p }))
+
+	// The first multiposter uses up the burst.
+	result := first.Publish(context.Background(), getPosts(2))
+	assert.NoError(t, result.Error)
+
+	// The second multiposter has to wait for the bucket to refill.
+	done := publishAsync(context.Background(), second, 2)
+	clock.BlockUntil(1)
+	assert.Equal(t, multiposter.Progress{Published: 0, Total: 2, Wait: time.Minute}, <-progress)
+	clock.Advance(30 * time.Second)
+	clock.Advance(30 * time.Second)
+	clock.BlockUntil(1)
+	assert.Equal(t, multiposter.Progress{Published: 1, Total: 2, Wait: time.Minute}, <-progress)
+	clock.Advance(time.Minute)
+	result = <-done
+	assert.NoError(t, result.Error)
+	assert.Len(t, result.Published, 2)
+}
+
+func TestWaitCancelled(t *testing.T) {
+	clock := &atptesting.FakeClock{Time: pacingStart}
+	ctx, cancel := context.WithCancel(context.Background())
+	m := multiposter.New(&fakeClient{failAfter: -1}, multiposter.WithClock(clock), multiposter.WithMinimumDelay(time.Hour))
+	done := publishAsync(ctx, m, 2)
+	clock.BlockUntil(1)
+	cancel()
+	result := <-done
+	assert.ErrorIs(t, result.Error, context.Canceled)
+	assert.Len(t, result.Published, 1)
+	assert.Len(t, result.Remaining, 1)
+}
+
+func TestRateLimiterReturnsTokenWhenCancelled(t *testing.T) {
+	clock := &atptesting.FakeClock{Time: pacingStart}
+	limiter := multiposter.NewRateLimiter(1, time.Minute, 1, multiposter.WithRateLimiterClock(clock))
+	require.NoError(t, limiter.Wait(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, limiter.Wait(ctx), context.Canceled)
+
+	clock.Advance(time.Minute)
+	done := make(chan error, 1)
+	go func() { done <- limiter.Wait(context.Background()) }()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the cancelled wait kept its token")
+	}
+}
+
+func TestInvalidRateLimiter(t *testing.T) {
+	assert.PanicsWithValue(t, "non-positive number of posts for NewRateLimiter", func() { multiposter.NewRateLimiter(0, time.Minute, 1) })
+	assert.PanicsWithValue(t, "non-positive period for NewRateLimiter", func() { multiposter.NewRateLimiter(1, 0, 1) })
+	assert.PanicsWithValue(t, "burst smaller than 1 for NewRateLimiter", func() { multiposter.NewRateLimiter(1, time.Minute, 0) })
+}
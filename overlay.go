@@ -0,0 +1,192 @@
+package k3
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Annotate applies the given features to the graphemes from start (inclusive) to end (exclusive) of the post's text.
+//
+// Features that are not given are left unchanged, so you can use this function to tag a word in a post that
+// already has other features. Use WithoutLink, WithoutMention, WithoutTag, and WithoutCustomFeature to remove a feature.
+// The range is clamped to the length of the post.
+func (p *Post) Annotate(start, end int, features ...BlockFeature) *Post {
+	return p.AnnotateBytes(p.byteOffset(start), p.byteOffset(end), features...)
+}
+
+// AnnotateBytes is like Annotate, but start and end are byte offsets into the post's text.
+//
+// If an offset falls in the middle of a character, it is moved to the start of that character.
+func (p *Post) AnnotateBytes(start, end int, features ...BlockFeature) *Post {
+	text := p.GetPlainText()
+	start = runeStart(text, start)
+	end = runeStart(text, end)
+	if start >= end {
+		return p
+	}
+	blocks := p.sliceBlocks(0, start)
+	for _, block := range p.sliceBlocks(start, end) {
+		for _, feature := range features {
+			feature(&block)
+		}
+		blocks = append(blocks, block)
+	}
+	blocks = append(blocks, p.sliceBlocks(end, len(text))...)
+	p.setBlocks(blocks)
+	return p
+}
+
+// ClearFeatures removes all links, mentions, tags, and custom features from the graphemes from start (inclusive) to end (exclusive) of the post's text.
+func (p *Post) ClearFeatures(start, end int) *Post {
+	return p.Annotate(start, end, WithoutLink(), WithoutMention(), WithoutTag(), WithoutCustomFeatures())
+}
+
+// Replace replaces the first n non-overlapping instances of old in the post's text with new.
+// If n < 0, all instances are replaced.
+//
+// The replacement text keeps the features that applied to the whole of the replaced text.
+// Features that only applied to part of the replaced text are kept for the text around it.
+func (p *Post) Replace(old, new string, n int) *Post {
+	if len(old) == 0 || n == 0 {
+		return p
+	}
+	text := p.GetPlainText()
+	var blocks []PostBlock
+	prev := 0
+	for n != 0 {
+		index := strings.Index(text[prev:], old)
+		if index < 0 {
+			break
+		}
+		start := prev + index
+		end := start + len(old)
+		blocks = append(blocks, p.sliceBlocks(prev, start)...)
+		blocks = append(blocks, commonFeatures(p.sliceBlocks(start, end), new))
+		prev = end
+		n--
+	}
+	if prev == 0 {
+		return p
+	}
+	blocks = append(blocks, p.sliceBlocks(prev, len(text))...)
+	p.setBlocks(blocks)
+	return p
+}
+
+// ReplaceAll replaces all non-overlapping instances of old in the post's text with new.
+//
+// Features are handled as in Replace.
+func (p *Post) ReplaceAll(old, new string) *Post {
+	return p.Replace(old, new, -1)
+}
+
+// WithoutLink returns a feature that removes the link from a block.
+func WithoutLink() BlockFeature {
+	return func(b *PostBlock) {
+		b.Link = nil
+	}
+}
+
+// WithoutMention returns a feature that removes the mention from a block.
+func WithoutMention() BlockFeature {
+	return func(b *PostBlock) {
+		b.Mention = nil
+	}
+}
+
+// WithoutTag returns a feature that removes the tag from a block.
+func WithoutTag() BlockFeature {
+	return func(b *PostBlock) {
+		b.Tag = nil
+	}
+}
+
+// WithoutCustomFeature returns a feature that removes the custom feature with the given type from a block.
+func WithoutCustomFeature(featureType string) BlockFeature {
+	return func(b *PostBlock) {
+		b.Custom = slices.DeleteFunc(slices.Clone(b.Custom), func(c CustomFeature) bool { return c.Type == featureType })
+	}
+}
+
+// WithoutCustomFeatures returns a feature that removes all custom features from a block.
+func WithoutCustomFeatures() BlockFeature {
+	return func(b *PostBlock) {
+		b.Custom = nil
+	}
+}
+
+// sliceBlocks returns the blocks that contain the text between the given byte offsets.
+func (p *Post) sliceBlocks(start, end int) []PostBlock {
+	var out []PostBlock
+	pos := 0
+	for _, block := range p.Blocks {
+		blockStart := pos
+		blockEnd := pos + len(block.Text)
+		pos = blockEnd
+		if blockEnd <= start || blockStart >= end {
+			continue
+		}
+		block.Text = block.Text[max(start, blockStart)-blockStart : min(end, blockEnd)-blockStart]
+		out = append(out, block)
+	}
+	return out
+}
+
+// setBlocks replaces the post's blocks, combining them as in AddBlock.
+func (p *Post) setBlocks(blocks []PostBlock) {
+	p.Blocks = nil
+	for _, block := range blocks {
+		p.AddBlock(block)
+	}
+}
+
+// byteOffset returns the byte offset of the given grapheme in the post's text.
+func (p *Post) byteOffset(grapheme int) int {
+	offset := 0
+	for _, block := range p.Blocks {
+		for i := range block.Text {
+			if grapheme <= 0 {
+				return offset + i
+			}
+			grapheme--
+		}
+		offset += len(block.Text)
+	}
+	return offset
+}
+
+// runeStart clamps the offset to the text and moves it back to the start of the character it falls in.
+func runeStart(text string, offset int) int {
+	offset = min(max(offset, 0), len(text))
+	for offset > 0 && offset < len(text) && !utf8.RuneStart(text[offset]) {
+		offset--
+	}
+	return offset
+}
+
+// commonFeatures returns a block with the given text and the features that all the given blocks share.
+func commonFeatures(blocks []PostBlock, text string) PostBlock {
+	eqStrPtr := func(a, b *string) bool { return a == b || (a != nil && b != nil && *a == *b) }
+	out := PostBlock{Text: text}
+	if len(blocks) == 0 {
+		return out
+	}
+	out.Link, out.Mention, out.Tag = blocks[0].Link, blocks[0].Mention, blocks[0].Tag
+	out.Custom = slices.Clone(blocks[0].Custom)
+	for _, block := range blocks[1:] {
+		if !eqStrPtr(out.Link, block.Link) {
+			out.Link = nil
+		}
+		if !eqStrPtr(out.Mention, block.Mention) {
+			out.Mention = nil
+		}
+		if !eqStrPtr(out.Tag, block.Tag) {
+			out.Tag = nil
+		}
+		out.Custom = slices.DeleteFunc(out.Custom, func(c CustomFeature) bool {
+			return !slices.ContainsFunc(block.Custom, c.Equal)
+		})
+	}
+	return out
+}
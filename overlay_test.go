@@ -0,0 +1,89 @@
+package k3_test
+
+import (
+	"testing"
+
+	"github.com/jtarrio/k3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnotate(t *testing.T) {
+	post := k3.NewPost().AddText("Hello, ").AddLink("wonderful world", "https://example.com/").AddText("!")
+	post.Annotate(7, 16, k3.WithTag("wonderful"))
+	expected := k3.NewPost().
+		AddText("Hello, ").
+		AddBlock(k3.NewBlock("wonderful", k3.WithLink("https://example.com/"), k3.WithTag("wonderful"))).
+		AddLink(" world", "https://example.com/").
+		AddText("!")
+	assert.Equal(t, expected, post)
+
+	post.Annotate(0, 5, k3.WithMention("did:plc:alice"))
+	assert.Equal(t, k3.NewBlock("Hello", k3.WithMention("did:plc:alice")), post.Blocks[0])
+	assert.Equal(t, "Hello, wonderful world!", post.GetPlainText())
+}
+
+func TestAnnotateGraphemesAndBytes(t *testing.T) {
+	post := k3.NewPost().AddText("¡Olá, mundo!")
+	post.Annotate(1, 4, k3.WithLink("https://example.com/"))
+	assert.Equal(t, k3.NewPost().AddText("¡").AddLink("Olá", "https://example.com/").AddText(", mundo!"), post)
+
+	post = k3.NewPost().AddText("¡Olá, mundo!")
+	post.AnnotateBytes(2, 6, k3.WithLink("https://example.com/"))
+	assert.Equal(t, k3.NewPost().AddText("¡").AddLink("Olá", "https://example.com/").AddText(", mundo!"), post)
+
+	// Offsets in the middle of a character are moved to its start.
+	post = k3.NewPost().AddText("¡Olá, mundo!")
+	post.AnnotateBytes(1, 5, k3.WithLink("https://example.com/"))
+	assert.Equal(t, k3.NewPost().AddLink("¡Ol", "https://example.com/").AddText("á, mundo!"), post)
+
+	// Ranges are clamped to the text.
+	post = k3.NewPost().AddText("abc")
+	post.Annotate(-5, 50, k3.WithTag("abc"))
+	assert.Equal(t, k3.NewPost().AddTag("abc", "abc"), post)
+
+	post.Annotate(2, 1, k3.WithTag("x"))
+	assert.Equal(t, k3.NewPost().AddTag("abc", "abc"), post)
+}
+
+func TestRemoveFeatures(t *testing.T) {
+	post := k3.NewPost().AddBlock(k3.NewBlock("#golang rocks", k3.WithTag("golang"), k3.WithLink("https://go.dev/")))
+	post.Annotate(7, 13, k3.WithoutTag())
+	expected := k3.NewPost().
+		AddBlock(k3.NewBlock("#golang", k3.WithTag("golang"), k3.WithLink("https://go.dev/"))).
+		AddLink(" rocks", "https://go.dev/")
+	assert.Equal(t, expected, post)
+
+	post.ClearFeatures(0, 100)
+	assert.Equal(t, k3.NewPost().AddText("#golang rocks"), post)
+}
+
+func TestReplace(t *testing.T) {
+	post := k3.NewPost().AddText("I like ").AddLink("cats", "https://example.com/cats").AddText(". Cats are great, cats!")
+	post.ReplaceAll("cats", "dogs")
+	expected := k3.NewPost().AddText("I like ").AddLink("dogs", "https://example.com/cats").AddText(". Cats are great, dogs!")
+	assert.Equal(t, expected, post)
+
+	post.Replace("dogs", "felines", 1)
+	expected = k3.NewPost().AddText("I like ").AddLink("felines", "https://example.com/cats").AddText(". Cats are great, dogs!")
+	assert.Equal(t, expected, post)
+
+	post.ReplaceAll("missing", "nothing")
+	assert.Equal(t, expected, post)
+}
+
+func TestReplaceAcrossBlocks(t *testing.T) {
+	post := k3.NewPost().AddText("Say hello ").AddMention("@alice", "did:plc:alice").AddText(" and friends")
+	post.ReplaceAll("o @alice a", "o @bob a")
+	expected := k3.NewPost().AddText("Say hello @bob and friends")
+	assert.Equal(t, expected, post)
+
+	post = k3.NewPost().AddText("Visit ").AddLink("example.com", "https://example.com/").AddText(" now")
+	post.ReplaceAll("example.com now", "")
+	expected = k3.NewPost().AddText("Visit ")
+	assert.Equal(t, expected, post)
+
+	post = k3.NewPost().AddLink("first link", "https://example.com/").AddLink("second", "https://example.com/2")
+	post.ReplaceAll("link", "page")
+	expected = k3.NewPost().AddLink("first page", "https://example.com/").AddLink("second", "https://example.com/2")
+	assert.Equal(t, expected, post)
+}
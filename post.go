@@ -1,6 +1,9 @@
 package k3
 
 import (
+	"fmt"
+	"maps"
+	"slices"
 	"strings"
 	"time"
 )
@@ -13,8 +16,25 @@ type Post struct {
 	Blocks []PostBlock
 	// Languages is the list of codes of languages this post is written in.
 	Languages []string
+	// Labels is the list of self-labels (content warnings) that apply to this post.
+	Labels []string
 }
 
+// Self-labels that can be applied to a post.
+const (
+	// LabelSexual indicates that the post contains sexually suggestive content.
+	LabelSexual = "sexual"
+	// LabelNudity indicates that the post contains non-sexual nudity.
+	LabelNudity = "nudity"
+	// LabelPorn indicates that the post contains sexually explicit content.
+	LabelPorn = "porn"
+	// LabelGraphicMedia indicates that the post contains graphic or disturbing content.
+	LabelGraphicMedia = "graphic-media"
+)
+
+// KnownLabels contains all the self-labels that can be applied to a post.
+var KnownLabels = []string{LabelSexual, LabelNudity, LabelPorn, LabelGraphicMedia}
+
 // PostBlock is a unit of content for a post.
 type PostBlock struct {
 	// Text contains the mandatory text for this block.
@@ -23,8 +43,18 @@ type PostBlock struct {
 	Link *string
 	// Mention contains a DID which is mentioned in this block.
 	Mention *string
-	// Tag contains a keyword that this block is tagged with.
+	// Tag contains a keyword that this block is tagged with. Cashtags are tags that start with '$'.
 	Tag *string
+	// Custom contains features other than links, mentions, and tags, such as those used by non-Bluesky AppViews.
+	Custom []CustomFeature
+}
+
+// CustomFeature is a facet feature with an arbitrary type.
+type CustomFeature struct {
+	// Type is the feature's lexicon type, such as "com.example.richtext.facet#highlight".
+	Type string
+	// Fields contains the feature's properties, except for its type.
+	Fields map[string]string
 }
 
 // NewPost creates a post.
@@ -59,10 +89,14 @@ func (p *Post) AddTag(text string, tag string) *Post {
 	return p.AddBlock(NewBlock(text, WithTag(tag)))
 }
 
-// AddBlock adds a block to the post. If two consecutive blocks have the same link URI, mention DID, and tag, they are combined.
+// AddCashtag adds a cashtag to the post with the given text and ticker symbol.
+func (p *Post) AddCashtag(text string, ticker string) *Post {
+	return p.AddBlock(NewBlock(text, WithCashtag(ticker)))
+}
+
+// AddBlock adds a block to the post. If two consecutive blocks have the same features, they are combined.
 func (p *Post) AddBlock(block PostBlock) *Post {
 	emptyStrPtr := func(a *string) bool { return a == nil || len(*a) == 0 }
-	eqStrPtr := func(a, b *string) bool { return a == b || (a != nil && b != nil && *a == *b) }
 	if len(block.Text) == 0 {
 		return p
 	}
@@ -75,12 +109,15 @@ func (p *Post) AddBlock(block PostBlock) *Post {
 	if emptyStrPtr(block.Tag) {
 		block.Tag = nil
 	}
+	if len(block.Custom) == 0 {
+		block.Custom = nil
+	}
 	if len(p.Blocks) == 0 {
 		p.Blocks = append(p.Blocks, block)
 		return p
 	}
 	latest := &p.Blocks[len(p.Blocks)-1]
-	if eqStrPtr(latest.Link, block.Link) && eqStrPtr(latest.Mention, block.Mention) && eqStrPtr(latest.Tag, block.Tag) {
+	if latest.sameFeatures(block) {
 		latest.Text += block.Text
 	} else {
 		p.Blocks = append(p.Blocks, block)
@@ -94,6 +131,34 @@ func (p *Post) AddLanguage(lang string) *Post {
 	return p
 }
 
+// AddLabel adds a self-label to the post, unless it already has it.
+func (p *Post) AddLabel(label string) *Post {
+	if !slices.Contains(p.Labels, label) {
+		p.Labels = append(p.Labels, label)
+	}
+	return p
+}
+
+// ValidateLabels checks that the post only contains known self-labels.
+func (p Post) ValidateLabels() error {
+	for _, label := range p.Labels {
+		if !slices.Contains(KnownLabels, label) {
+			return &UnknownLabelError{Label: label}
+		}
+	}
+	return nil
+}
+
+// UnknownLabelError is returned by ValidateLabels when a post has a self-label that is not in KnownLabels.
+type UnknownLabelError struct {
+	// Label is the unknown label.
+	Label string
+}
+
+func (e *UnknownLabelError) Error() string {
+	return fmt.Sprintf("unknown self-label '%s'", e.Label)
+}
+
 // GetPlainText returns the plain text of the post.
 func (p Post) GetPlainText() string {
 	sb := strings.Builder{}
@@ -151,9 +216,48 @@ func WithTag(tag string) BlockFeature {
 	}
 }
 
+// WithCashtag returns a 'tag' feature for the given ticker symbol, which is converted to uppercase and prefixed with '$'.
+func WithCashtag(ticker string) BlockFeature {
+	return WithTag("$" + strings.ToUpper(strings.TrimPrefix(ticker, "$")))
+}
+
+// WithCustomFeature returns a feature with the given type and fields. It replaces any other custom feature with the same type.
+func WithCustomFeature(featureType string, fields map[string]string) BlockFeature {
+	return func(b *PostBlock) {
+		b.Custom = slices.DeleteFunc(slices.Clone(b.Custom), func(c CustomFeature) bool { return c.Type == featureType })
+		b.Custom = append(b.Custom, CustomFeature{Type: featureType, Fields: maps.Clone(fields)})
+		slices.SortFunc(b.Custom, func(a, b CustomFeature) int { return strings.Compare(a.Type, b.Type) })
+	}
+}
+
 // BlockFeature is the type for features used in NewBlock.
 type BlockFeature func(*PostBlock)
 
+// Cashtag returns the ticker symbol of the block's cashtag, if it has one.
+func (b PostBlock) Cashtag() (string, bool) {
+	if b.Tag == nil {
+		return "", false
+	}
+	ticker, found := strings.CutPrefix(*b.Tag, "$")
+	return ticker, found && len(ticker) > 0
+}
+
+// HasFeatures returns whether the block has a link, mention, tag, or custom feature.
+func (b PostBlock) HasFeatures() bool {
+	return b.Link != nil || b.Mention != nil || b.Tag != nil || len(b.Custom) > 0
+}
+
+func (b PostBlock) sameFeatures(o PostBlock) bool {
+	eqStrPtr := func(a, b *string) bool { return a == b || (a != nil && b != nil && *a == *b) }
+	return eqStrPtr(b.Link, o.Link) && eqStrPtr(b.Mention, o.Mention) && eqStrPtr(b.Tag, o.Tag) &&
+		slices.EqualFunc(b.Custom, o.Custom, CustomFeature.Equal)
+}
+
+// Equal returns whether two custom features have the same type and fields.
+func (c CustomFeature) Equal(o CustomFeature) bool {
+	return c.Type == o.Type && maps.Equal(c.Fields, o.Fields)
+}
+
 // GetPlainText returns the block's text
 func (b PostBlock) GetPlainText() string {
 	return b.Text
@@ -72,3 +72,78 @@ func TestCombineLikeBlocks(t *testing.T) {
 func ptr[T any](v T) *T {
 	return &v
 }
+
+func TestLabels(t *testing.T) {
+	post := k3.NewPost().
+		AddText(`Contenido sensible`).
+		AddLabel(k3.LabelNudity).
+		AddLabel(k3.LabelGraphicMedia).
+		AddLabel(k3.LabelNudity)
+
+	assert.Equal(t, []string{"nudity", "graphic-media"}, post.Labels)
+	assert.NoError(t, post.ValidateLabels())
+
+	post.AddLabel("spoiler")
+	assert.Equal(t, &k3.UnknownLabelError{Label: "spoiler"}, post.ValidateLabels())
+}
+
+type fakeDetector map[string][]string
+
+func (d fakeDetector) DetectLanguages(text string) []string {
+	return d[text]
+}
+
+func TestDetectLanguages(t *testing.T) {
+	detector := fakeDetector{"Hola,   mira  ": {"es"}, "Hola,   mira ": {"es"}}
+	post := k3.NewPost().AddLanguage("en").AddText("Hola, ").AddMention("@alice", "did:plc:alice").AddText(" mira ").AddLink("esto", "https://example.com/")
+	post.DetectLanguages(detector)
+	assert.Equal(t, []string{"es"}, post.Languages)
+
+	post = k3.NewPost().AddLanguage("en").AddText("Hola, ").
+		AddBlock(k3.NewBlock("code", k3.WithCustomFeature("com.example.facet#code", nil))).AddText(" mira ")
+	post.DetectLanguages(detector)
+	assert.Equal(t, []string{"es"}, post.Languages)
+
+	post = k3.NewPost().AddLanguage("en").AddText("???")
+	post.DetectLanguages(detector)
+	assert.Equal(t, []string{"en"}, post.Languages)
+}
+
+func TestCashtags(t *testing.T) {
+	post := k3.NewPost().AddCashtag("$aapl", "aapl").AddText(" and ").AddTag("#tag", "tag")
+	ticker, ok := post.Blocks[0].Cashtag()
+	assert.True(t, ok)
+	assert.Equal(t, "AAPL", ticker)
+	assert.Equal(t, "$AAPL", *post.Blocks[0].Tag)
+
+	_, ok = post.Blocks[2].Cashtag()
+	assert.False(t, ok)
+	_, ok = post.Blocks[1].Cashtag()
+	assert.False(t, ok)
+}
+
+func TestCustomFeatures(t *testing.T) {
+	highlight := k3.WithCustomFeature("com.example.facet#highlight", map[string]string{"color": "red"})
+	post := k3.NewPost().
+		AddBlock(k3.NewBlock("one ", highlight)).
+		AddBlock(k3.NewBlock("two ", highlight)).
+		AddBlock(k3.NewBlock("three", k3.WithCustomFeature("com.example.facet#highlight", map[string]string{"color": "blue"})))
+	assert.Len(t, post.Blocks, 2)
+	assert.Equal(t, "one two ", post.Blocks[0].Text)
+	assert.True(t, post.Blocks[0].HasFeatures())
+
+	block := k3.NewBlock("text",
+		k3.WithCustomFeature("com.example.b", nil),
+		k3.WithCustomFeature("com.example.a", map[string]string{"x": "1"}),
+		k3.WithCustomFeature("com.example.a", map[string]string{"x": "2"}))
+	assert.Equal(t, []k3.CustomFeature{
+		{Type: "com.example.a", Fields: map[string]string{"x": "2"}},
+		{Type: "com.example.b"},
+	}, block.Custom)
+
+	post.Annotate(0, 3, k3.WithoutCustomFeature("com.example.facet#highlight"))
+	assert.Equal(t, "one", post.Blocks[0].Text)
+	assert.False(t, post.Blocks[0].HasFeatures())
+	post.ClearFeatures(0, 100)
+	assert.Equal(t, k3.NewPost().AddText("one two three"), post)
+}
@@ -0,0 +1,242 @@
+// Package postfile defines a stable, versioned format to store posts as JSON or YAML.
+//
+// A post file contains an object with the following fields:
+//
+//   - version (required): the version of the format. It must be 1.
+//   - creationTime (optional): the post's creation time, in RFC 3339 format.
+//   - languages (optional): a list of BCP 47 language codes.
+//   - labels (optional): a list of self-labels, which must be in k3.KnownLabels.
+//   - blocks (optional): a list of blocks. Each block is an object with a required, non-empty "text" field and
+//     optional "link" (an absolute URI), "mention" (a DID), "tag" (a keyword), and "custom" fields.
+//     The "custom" field is a list of custom features, which are objects with a "type" (a lexicon type)
+//     and optional "fields" (an object with string values).
+//
+// Fields that are not set are omitted instead of being written as null. Unknown fields are rejected.
+// The JSON schema for this format is available in the JsonSchema variable.
+package postfile
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jtarrio/k3"
+	"golang.org/x/text/language"
+	"gopkg.in/yaml.v3"
+)
+
+// Version is the version of the format written by this package.
+const Version = 1
+
+// JsonSchema contains the JSON schema for the post file format.
+//
+//go:embed schema.json
+var JsonSchema string
+
+// Document is the representation of a post in a post file.
+type Document struct {
+	Version      int        `json:"version" yaml:"version"`
+	CreationTime *time.Time `json:"creationTime,omitempty" yaml:"creationTime,omitempty"`
+	Languages    []string   `json:"languages,omitempty" yaml:"languages,omitempty"`
+	Labels       []string   `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Blocks       []Block    `json:"blocks,omitempty" yaml:"blocks,omitempty"`
+}
+
+// Block is the representation of a post block in a post file.
+type Block struct {
+	Text    string          `json:"text" yaml:"text"`
+	Link    *string         `json:"link,omitempty" yaml:"link,omitempty"`
+	Mention *string         `json:"mention,omitempty" yaml:"mention,omitempty"`
+	Tag     *string         `json:"tag,omitempty" yaml:"tag,omitempty"`
+	Custom  []CustomFeature `json:"custom,omitempty" yaml:"custom,omitempty"`
+}
+
+// CustomFeature is the representation of a block's custom feature in a post file.
+type CustomFeature struct {
+	Type   string            `json:"type" yaml:"type"`
+	Fields map[string]string `json:"fields,omitempty" yaml:"fields,omitempty"`
+}
+
+// FromPost returns the document that represents the given post.
+func FromPost(post *k3.Post) *Document {
+	doc := &Document{Version: Version, CreationTime: post.CreationTime, Languages: post.Languages, Labels: post.Labels}
+	for _, block := range post.Blocks {
+		out := Block{Text: block.Text, Link: block.Link, Mention: block.Mention, Tag: block.Tag}
+		for _, custom := range block.Custom {
+			out.Custom = append(out.Custom, CustomFeature{Type: custom.Type, Fields: custom.Fields})
+		}
+		doc.Blocks = append(doc.Blocks, out)
+	}
+	return doc
+}
+
+// ToPost validates the document and returns the post it represents.
+func (d *Document) ToPost() (*k3.Post, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	post := k3.NewPost()
+	if d.CreationTime != nil {
+		post.SetCreationTime(*d.CreationTime)
+	}
+	for _, lang := range d.Languages {
+		post.AddLanguage(lang)
+	}
+	for _, label := range d.Labels {
+		post.AddLabel(label)
+	}
+	for _, block := range d.Blocks {
+		postBlock := k3.PostBlock{Text: block.Text, Link: block.Link, Mention: block.Mention, Tag: block.Tag}
+		for _, custom := range block.Custom {
+			k3.WithCustomFeature(custom.Type, custom.Fields)(&postBlock)
+		}
+		post.AddBlock(postBlock)
+	}
+	return post, nil
+}
+
+// Validate checks that the document is valid for the current version of the format.
+func (d *Document) Validate() error {
+	if d.Version != Version {
+		return &VersionError{Version: d.Version}
+	}
+	for i, lang := range d.Languages {
+		if _, err := language.Parse(lang); err != nil {
+			return &ValidationError{Field: fmt.Sprintf("languages[%d]", i), Reason: fmt.Sprintf("'%s' is not a valid language code", lang)}
+		}
+	}
+	for i, label := range d.Labels {
+		if !slices.Contains(k3.KnownLabels, label) {
+			return &ValidationError{Field: fmt.Sprintf("labels[%d]", i), Reason: fmt.Sprintf("'%s' is not a known self-label", label)}
+		}
+	}
+	for i, block := range d.Blocks {
+		if err := block.validate(); err != nil {
+			err.Field = fmt.Sprintf("blocks[%d].%s", i, err.Field)
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Block) validate() *ValidationError {
+	if len(b.Text) == 0 {
+		return &ValidationError{Field: "text", Reason: "the text is empty"}
+	}
+	if !utf8.ValidString(b.Text) {
+		return &ValidationError{Field: "text", Reason: "the text is not valid UTF-8"}
+	}
+	if b.Link != nil {
+		if u, err := url.Parse(*b.Link); err != nil || !u.IsAbs() {
+			return &ValidationError{Field: "link", Reason: fmt.Sprintf("'%s' is not an absolute URI", *b.Link)}
+		}
+	}
+	if b.Mention != nil && !strings.HasPrefix(*b.Mention, "did:") {
+		return &ValidationError{Field: "mention", Reason: fmt.Sprintf("'%s' is not a DID", *b.Mention)}
+	}
+	if b.Tag != nil && (len(*b.Tag) == 0 || strings.ContainsFunc(*b.Tag, isSpace)) {
+		return &ValidationError{Field: "tag", Reason: fmt.Sprintf("'%s' is not a valid tag", *b.Tag)}
+	}
+	for i, custom := range b.Custom {
+		if !strings.Contains(custom.Type, ".") || strings.ContainsFunc(custom.Type, isSpace) {
+			return &ValidationError{Field: fmt.Sprintf("custom[%d].type", i), Reason: fmt.Sprintf("'%s' is not a valid lexicon type", custom.Type)}
+		}
+		if strings.HasPrefix(custom.Type, "app.bsky.richtext.facet#") {
+			return &ValidationError{Field: fmt.Sprintf("custom[%d].type", i), Reason: fmt.Sprintf("'%s' must be written as a link, mention, or tag", custom.Type)}
+		}
+	}
+	return nil
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
+
+// MarshalJson returns the JSON representation of the given post.
+func MarshalJson(post *k3.Post) ([]byte, error) {
+	doc := FromPost(post)
+	if err := doc.Validate(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// UnmarshalJson parses and validates the JSON representation of a post.
+func UnmarshalJson(data []byte) (*k3.Post, error) {
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+	if header.Version != Version {
+		return nil, &VersionError{Version: header.Version}
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var doc Document
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc.ToPost()
+}
+
+// MarshalYaml returns the YAML representation of the given post.
+func MarshalYaml(post *k3.Post) ([]byte, error) {
+	doc := FromPost(post)
+	if err := doc.Validate(); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(doc)
+}
+
+// UnmarshalYaml parses and validates the YAML representation of a post.
+func UnmarshalYaml(data []byte) (*k3.Post, error) {
+	var header struct {
+		Version int `yaml:"version"`
+	}
+	if err := yaml.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+	if header.Version != Version {
+		return nil, &VersionError{Version: header.Version}
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var doc Document
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc.ToPost()
+}
+
+// VersionError is returned when a post file has a missing or unsupported version.
+type VersionError struct {
+	// Version is the version found in the post file.
+	Version int
+}
+
+func (e *VersionError) Error() string {
+	if e.Version == 0 {
+		return "missing post file version"
+	}
+	return fmt.Sprintf("unsupported post file version %d (supported version is %d)", e.Version, Version)
+}
+
+// ValidationError is returned when a post file contains an invalid value.
+type ValidationError struct {
+	// Field is the path to the invalid field, such as "blocks[2].link".
+	Field string
+	// Reason describes the problem with the field.
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid value for '%s': %s", e.Field, e.Reason)
+}
@@ -0,0 +1,167 @@
+package postfile_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jtarrio/k3"
+	"github.com/jtarrio/k3/postfile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func samplePost() *k3.Post {
+	return k3.NewPost().
+		SetCreationTime(time.Date(2025, time.January, 2, 12, 34, 56, 789000000, time.UTC)).
+		AddLanguage("es").
+		AddLabel(k3.LabelNudity).
+		AddText("Hola ").
+		AddMention("@alice", "did:plc:alice").
+		AddText(", mira ").
+		AddLink("esto", "https://example.com/").
+		AddText(" ").
+		AddBlock(k3.NewBlock("#k3", k3.WithTag("k3"), k3.WithLink("https://example.com/k3")))
+}
+
+func TestMarshalJson(t *testing.T) {
+	data, err := postfile.MarshalJson(samplePost())
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"version": 1,
+		"creationTime": "2025-01-02T12:34:56.789Z",
+		"languages": ["es"],
+		"labels": ["nudity"],
+		"blocks": [
+			{"text": "Hola "},
+			{"text": "@alice", "mention": "did:plc:alice"},
+			{"text": ", mira "},
+			{"text": "esto", "link": "https://example.com/"},
+			{"text": " "},
+			{"text": "#k3", "link": "https://example.com/k3", "tag": "k3"}
+		]
+	}`, string(data))
+
+	post, err := postfile.UnmarshalJson(data)
+	require.NoError(t, err)
+	assert.Equal(t, samplePost(), post)
+}
+
+func TestMarshalEmptyPost(t *testing.T) {
+	data, err := postfile.MarshalJson(k3.NewPost())
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"version": 1}`, string(data))
+
+	post, err := postfile.UnmarshalJson(data)
+	require.NoError(t, err)
+	assert.Equal(t, k3.NewPost(), post)
+}
+
+func TestMarshalYaml(t *testing.T) {
+	data, err := postfile.MarshalYaml(samplePost())
+	require.NoError(t, err)
+
+	post, err := postfile.UnmarshalYaml(data)
+	require.NoError(t, err)
+	assert.Equal(t, samplePost(), post)
+}
+
+func TestUnmarshalHandWrittenYaml(t *testing.T) {
+	post, err := postfile.UnmarshalYaml([]byte(`
+version: 1
+creationTime: 2025-01-02T12:34:56.789Z
+languages: [es]
+labels: [nudity]
+blocks:
+  - text: "Hola "
+  - text: "@alice"
+    mention: did:plc:alice
+  - text: ", mira "
+  - text: esto
+    link: https://example.com/
+  - text: " "
+  - text: "#k3"
+    tag: k3
+    link: https://example.com/k3
+`))
+	require.NoError(t, err)
+	assert.Equal(t, samplePost(), post)
+}
+
+func TestVersionErrors(t *testing.T) {
+	_, err := postfile.UnmarshalJson([]byte(`{"blocks": [{"text": "hello"}]}`))
+	assert.Equal(t, &postfile.VersionError{Version: 0}, err)
+	assert.EqualError(t, err, "missing post file version")
+
+	_, err = postfile.UnmarshalJson([]byte(`{"version": 2, "embed": {}}`))
+	assert.Equal(t, &postfile.VersionError{Version: 2}, err)
+
+	_, err = postfile.UnmarshalYaml([]byte("version: 3\n"))
+	assert.Equal(t, &postfile.VersionError{Version: 3}, err)
+}
+
+func TestUnknownFields(t *testing.T) {
+	_, err := postfile.UnmarshalJson([]byte(`{"version": 1, "blocks": [{"text": "hello", "lnk": "https://example.com"}]}`))
+	assert.ErrorContains(t, err, "lnk")
+
+	_, err = postfile.UnmarshalYaml([]byte("version: 1\nlanguage: [en]\n"))
+	assert.ErrorContains(t, err, "language")
+}
+
+func TestValidation(t *testing.T) {
+	tests := []struct {
+		doc   string
+		field string
+	}{
+		{`{"version": 1, "languages": ["en", "not a language"]}`, "languages[1]"},
+		{`{"version": 1, "labels": ["porn", "spoiler"]}`, "labels[1]"},
+		{`{"version": 1, "blocks": [{"text": "a"}, {"text": ""}]}`, "blocks[1].text"},
+		{`{"version": 1, "blocks": [{"text": "a", "link": "example.com"}]}`, "blocks[0].link"},
+		{`{"version": 1, "blocks": [{"text": "a", "mention": "alice.bsky.social"}]}`, "blocks[0].mention"},
+		{`{"version": 1, "blocks": [{"text": "a", "tag": ""}]}`, "blocks[0].tag"},
+		{`{"version": 1, "blocks": [{"text": "a", "tag": "two words"}]}`, "blocks[0].tag"},
+	}
+	for _, test := range tests {
+		_, err := postfile.UnmarshalJson([]byte(test.doc))
+		var validationError *postfile.ValidationError
+		if assert.ErrorAs(t, err, &validationError, test.doc) {
+			assert.Equal(t, test.field, validationError.Field, test.doc)
+		}
+	}
+
+	_, err := postfile.MarshalJson(k3.NewPost().AddLink("a", "relative/link"))
+	assert.ErrorContains(t, err, "blocks[0].link")
+}
+
+func TestCustomFeatures(t *testing.T) {
+	post := k3.NewPost().
+		AddCashtag("$AAPL", "AAPL").
+		AddText(" ").
+		AddBlock(k3.NewBlock("hi", k3.WithLink("https://example.com/"), k3.WithCustomFeature("com.example.facet#highlight", map[string]string{"color": "red"})))
+	data, err := postfile.MarshalJson(post)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"version": 1,
+		"blocks": [
+			{"text": "$AAPL", "tag": "$AAPL"},
+			{"text": " "},
+			{"text": "hi", "link": "https://example.com/", "custom": [{"type": "com.example.facet#highlight", "fields": {"color": "red"}}]}
+		]
+	}`, string(data))
+
+	back, err := postfile.UnmarshalJson(data)
+	require.NoError(t, err)
+	assert.Equal(t, post, back)
+
+	_, err = postfile.UnmarshalYaml([]byte("version: 1\nblocks:\n  - text: a\n    custom:\n      - type: highlight\n"))
+	assert.ErrorContains(t, err, "blocks[0].custom[0].type")
+
+	_, err = postfile.UnmarshalYaml([]byte("version: 1\nblocks:\n  - text: a\n    custom:\n      - type: app.bsky.richtext.facet#link\n"))
+	assert.ErrorContains(t, err, "blocks[0].custom[0].type")
+}
+
+func TestJsonSchema(t *testing.T) {
+	var schema map[string]any
+	require.NoError(t, json.Unmarshal([]byte(postfile.JsonSchema), &schema))
+	assert.Equal(t, "object", schema["type"])
+}
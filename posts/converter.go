@@ -1,8 +1,11 @@
 package posts
 
 import (
+	"slices"
 	"time"
+	"unicode/utf8"
 
+	"github.com/bluesky-social/indigo/api/atproto"
 	"github.com/bluesky-social/indigo/api/bsky"
 	"github.com/jtarrio/k3"
 )
@@ -32,6 +35,9 @@ type Converter struct {
 // ToFeedPost generates a Bluesky FeedPost object from the content of the given post.
 //
 // The creation time, if unset, is populated with an always-increasing clock so that different posts have different creation times.
+//
+// Self-labels that are not in k3.KnownLabels are left out. Cashtags are converted into tag facets. Custom features
+// are left out, because FeedPost facets can only contain links, mentions, and tags; use ToRecord to keep them.
 func (c *Converter) ToFeedPost(post *k3.Post) *bsky.FeedPost {
 	var creationTime time.Time
 	if post.CreationTime == nil {
@@ -46,6 +52,15 @@ func (c *Converter) ToFeedPost(post *k3.Post) *bsky.FeedPost {
 		Text:          post.GetPlainText(),
 		Langs:         post.Languages,
 	}
+	selfLabels := &atproto.LabelDefs_SelfLabels{LexiconTypeID: "com.atproto.label.defs#selfLabels"}
+	for _, label := range post.Labels {
+		if slices.Contains(k3.KnownLabels, label) {
+			selfLabels.Values = append(selfLabels.Values, &atproto.LabelDefs_SelfLabel{Val: label})
+		}
+	}
+	if len(selfLabels.Values) > 0 {
+		out.Labels = &bsky.FeedPost_Labels{LabelDefs_SelfLabels: selfLabels}
+	}
 	start := 0
 	for _, block := range post.Blocks {
 		end := start + block.GetByteLength()
@@ -65,6 +80,24 @@ func (c *Converter) ToFeedPost(post *k3.Post) *bsky.FeedPost {
 	return out
 }
 
+// ToRecord generates a Record from the content of the given post, like ToFeedPost, keeping its custom features.
+func (c *Converter) ToRecord(post *k3.Post) *Record {
+	out := &Record{FeedPost: c.ToFeedPost(post)}
+	start := 0
+	for _, block := range post.Blocks {
+		end := start + block.GetByteLength()
+		if len(block.Custom) > 0 {
+			out.CustomFacets = append(out.CustomFacets, CustomFacet{
+				ByteStart: int64(start),
+				ByteEnd:   int64(end),
+				Features:  slices.Clone(block.Custom),
+			})
+		}
+		start = end
+	}
+	return out
+}
+
 // ToFeedPosts converts a slice of posts into a slice of FeedPost objects.
 func (c *Converter) ToFeedPosts(posts []*k3.Post) []*bsky.FeedPost {
 	var out []*bsky.FeedPost
@@ -74,6 +107,105 @@ func (c *Converter) ToFeedPosts(posts []*k3.Post) []*bsky.FeedPost {
 	return out
 }
 
+// FromFeedPost generates a post from the content of the given Bluesky FeedPost object.
+//
+// Self-labels that are not in k3.KnownLabels are ignored. This function is lenient with facets. Facet indices outside of the text are clipped, and indices in the middle
+// of a UTF-8 character are moved so the facet covers the whole character. Facets that are empty after this are ignored.
+//
+// Facets may overlap and be in any order. The text is divided at every facet boundary, and each piece gets the features
+// of all the facets that cover it. If several facets give the same kind of feature to a piece, the first one wins.
+func (c *Converter) FromFeedPost(feedPost *bsky.FeedPost) *k3.Post {
+	return c.fromFeedPost(feedPost, nil)
+}
+
+// FromRecord generates a post from the content of the given Record, like FromFeedPost, keeping its custom features.
+//
+// If several facets give a custom feature of the same type to a piece of text, the first one wins.
+func (c *Converter) FromRecord(record *Record) *k3.Post {
+	return c.fromFeedPost(record.FeedPost, record.CustomFacets)
+}
+
+func (c *Converter) fromFeedPost(feedPost *bsky.FeedPost, customFacets []CustomFacet) *k3.Post {
+	out := k3.NewPost()
+	if creationTime, err := time.Parse(time.RFC3339Nano, feedPost.CreatedAt); err == nil {
+		out.SetCreationTime(creationTime)
+	}
+	for _, lang := range feedPost.Langs {
+		out.AddLanguage(lang)
+	}
+	if feedPost.Labels != nil && feedPost.Labels.LabelDefs_SelfLabels != nil {
+		for _, label := range feedPost.Labels.LabelDefs_SelfLabels.Values {
+			if label != nil && slices.Contains(k3.KnownLabels, label.Val) {
+				out.AddLabel(label.Val)
+			}
+		}
+	}
+
+	text := feedPost.Text
+	type span struct {
+		start, end int
+		features   []*bsky.RichtextFacet_Features_Elem
+		custom     []k3.CustomFeature
+	}
+	var spans []span
+	boundaries := []int{0, len(text)}
+	addSpan := func(byteStart, byteEnd int64, features []*bsky.RichtextFacet_Features_Elem, custom []k3.CustomFeature) {
+		start := int(min(max(byteStart, 0), int64(len(text))))
+		end := int(min(max(byteEnd, 0), int64(len(text))))
+		for start > 0 && start < len(text) && !utf8.RuneStart(text[start]) {
+			start--
+		}
+		for end > 0 && end < len(text) && !utf8.RuneStart(text[end]) {
+			end++
+		}
+		if start >= end {
+			return
+		}
+		spans = append(spans, span{start: start, end: end, features: features, custom: custom})
+		boundaries = append(boundaries, start, end)
+	}
+	for _, facet := range feedPost.Facets {
+		if facet == nil || facet.Index == nil {
+			continue
+		}
+		addSpan(facet.Index.ByteStart, facet.Index.ByteEnd, facet.Features, nil)
+	}
+	for _, facet := range customFacets {
+		addSpan(facet.ByteStart, facet.ByteEnd, nil, facet.Features)
+	}
+	slices.Sort(boundaries)
+	boundaries = slices.Compact(boundaries)
+
+	for i := 1; i < len(boundaries); i++ {
+		start, end := boundaries[i-1], boundaries[i]
+		block := k3.NewBlock(text[start:end])
+		for _, span := range spans {
+			if span.start > start || span.end < end {
+				continue
+			}
+			for _, feature := range span.features {
+				setBlockFeature(&block, feature)
+			}
+			for _, feature := range span.custom {
+				if !slices.ContainsFunc(block.Custom, func(f k3.CustomFeature) bool { return f.Type == feature.Type }) {
+					block.Custom = append(block.Custom, feature)
+				}
+			}
+		}
+		out.AddBlock(block)
+	}
+	return out
+}
+
+// FromFeedPosts converts a slice of FeedPost objects into a slice of posts.
+func (c *Converter) FromFeedPosts(feedPosts []*bsky.FeedPost) []*k3.Post {
+	var out []*k3.Post
+	for _, feedPost := range feedPosts {
+		out = append(out, c.FromFeedPost(feedPost))
+	}
+	return out
+}
+
 func getBlockFeatures(block *k3.PostBlock) []*bsky.RichtextFacet_Features_Elem {
 	var out []*bsky.RichtextFacet_Features_Elem
 	if block.Link != nil && len(*block.Link) > 0 {
@@ -102,3 +234,18 @@ func getBlockFeatures(block *k3.PostBlock) []*bsky.RichtextFacet_Features_Elem {
 	}
 	return out
 }
+
+func setBlockFeature(block *k3.PostBlock, feature *bsky.RichtextFacet_Features_Elem) {
+	if feature == nil {
+		return
+	}
+	if feature.RichtextFacet_Link != nil && block.Link == nil {
+		block.Link = &feature.RichtextFacet_Link.Uri
+	}
+	if feature.RichtextFacet_Mention != nil && block.Mention == nil {
+		block.Mention = &feature.RichtextFacet_Mention.Did
+	}
+	if feature.RichtextFacet_Tag != nil && block.Tag == nil {
+		block.Tag = &feature.RichtextFacet_Tag.Tag
+	}
+}
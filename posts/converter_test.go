@@ -1,15 +1,18 @@
 package posts_test
 
 import (
+	"encoding/json"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/bluesky-social/indigo/api/atproto"
 	"github.com/bluesky-social/indigo/api/bsky"
 	"github.com/jtarrio/k3"
 	"github.com/jtarrio/k3/posts"
 	atptesting "github.com/jtarrio/k3/testing"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestConvertPlainText(t *testing.T) {
@@ -201,3 +204,228 @@ func indexOf(text, substr string) *bsky.RichtextFacet_ByteSlice {
 		ByteEnd:   int64(index + len(substr)),
 	}
 }
+
+func TestFromFeedPost(t *testing.T) {
+	c := posts.NewConverter()
+
+	text := `Hola @alice.bsky.social, mira https://example.com #tag`
+	feedPost := &bsky.FeedPost{
+		LexiconTypeID: "app.bsky.feed.post",
+		CreatedAt:     "2025-01-02T12:34:56.789Z",
+		Text:          text,
+		Langs:         []string{"es"},
+		Facets: []*bsky.RichtextFacet{
+			{Index: indexOf(text, `@alice.bsky.social`), Features: mention("did:plc:alice")},
+			{Index: indexOf(text, `https://example.com`), Features: link("https://example.com")},
+			{Index: indexOf(text, `#tag`), Features: tag("tag")},
+		},
+	}
+	expected := k3.NewPost().
+		SetCreationTime(time.Date(2025, time.January, 2, 12, 34, 56, 789000000, time.UTC)).
+		AddLanguage("es").
+		AddText(`Hola `).
+		AddMention(`@alice.bsky.social`, "did:plc:alice").
+		AddText(`, mira `).
+		AddLink(`https://example.com`, "https://example.com").
+		AddText(` `).
+		AddTag(`#tag`, "tag")
+	assert.Equal(t, expected, c.FromFeedPost(feedPost))
+	assert.Equal(t, feedPost, c.ToFeedPost(c.FromFeedPost(feedPost)))
+}
+
+func TestFromFeedPostOverlappingFacets(t *testing.T) {
+	c := posts.NewConverter()
+
+	text := `one two three`
+	feedPost := &bsky.FeedPost{
+		Text: text,
+		Facets: []*bsky.RichtextFacet{
+			{Index: indexOf(text, `two`), Features: tag("two")},
+			{Index: indexOf(text, `one two`), Features: link("https://one.example.com")},
+			{Index: indexOf(text, `two three`), Features: link("https://two.example.com")},
+		},
+	}
+	expected := k3.NewPost().
+		AddLink(`one `, "https://one.example.com").
+		AddBlock(k3.NewBlock(`two`, k3.WithLink("https://one.example.com"), k3.WithTag("two"))).
+		AddLink(` three`, "https://two.example.com")
+	assert.Equal(t, expected, c.FromFeedPost(feedPost))
+}
+
+func TestFromFeedPostMultipleFeatures(t *testing.T) {
+	c := posts.NewConverter()
+
+	text := `hello world`
+	feedPost := &bsky.FeedPost{
+		Text: text,
+		Facets: []*bsky.RichtextFacet{
+			{
+				Index:    indexOf(text, `world`),
+				Features: append(append(link("https://example.com"), mention("did:plc:world")...), link("https://ignored.example.com")...),
+			},
+		},
+	}
+	expected := k3.NewPost().
+		AddText(`hello `).
+		AddBlock(k3.NewBlock(`world`, k3.WithLink("https://example.com"), k3.WithMention("did:plc:world")))
+	assert.Equal(t, expected, c.FromFeedPost(feedPost))
+}
+
+func TestFromFeedPostMalformedIndices(t *testing.T) {
+	c := posts.NewConverter()
+
+	text := `año €uro end`
+	feedPost := &bsky.FeedPost{
+		CreatedAt: "not a date",
+		Text:      text,
+		Facets: []*bsky.RichtextFacet{
+			// Starts in the middle of 'ñ' and ends in the middle of '€'.
+			{Index: &bsky.RichtextFacet_ByteSlice{ByteStart: 2, ByteEnd: 7}, Features: tag("split")},
+			// Inverted.
+			{Index: &bsky.RichtextFacet_ByteSlice{ByteStart: 12, ByteEnd: 10}, Features: tag("inverted")},
+			// Empty.
+			{Index: &bsky.RichtextFacet_ByteSlice{ByteStart: 3, ByteEnd: 3}, Features: tag("empty")},
+			// Out of bounds.
+			{Index: &bsky.RichtextFacet_ByteSlice{ByteStart: 12, ByteEnd: 100}, Features: tag("end")},
+			{Index: &bsky.RichtextFacet_ByteSlice{ByteStart: -5, ByteEnd: -1}, Features: tag("negative")},
+			// Missing index and features.
+			{Features: tag("noindex")},
+			{Index: &bsky.RichtextFacet_ByteSlice{ByteStart: 0, ByteEnd: 1}},
+			{Index: &bsky.RichtextFacet_ByteSlice{ByteStart: 0, ByteEnd: 1}, Features: []*bsky.RichtextFacet_Features_Elem{{}}},
+		},
+	}
+	expected := k3.NewPost().
+		AddText(`a`).
+		AddTag(`ño €`, "split").
+		AddText(`uro `).
+		AddTag(`end`, "end")
+	assert.Equal(t, expected, c.FromFeedPost(feedPost))
+}
+
+func TestFromFeedPosts(t *testing.T) {
+	c := posts.NewConverter()
+
+	feedPosts := []*bsky.FeedPost{{Text: `first`}, {Text: `second`}}
+	expected := []*k3.Post{k3.NewPost().AddText(`first`), k3.NewPost().AddText(`second`)}
+	assert.Equal(t, expected, c.FromFeedPosts(feedPosts))
+}
+
+func TestConvertLabels(t *testing.T) {
+	fakeClock := &atptesting.FakeClock{Time: time.Date(2025, time.January, 2, 12, 34, 56, 789000000, time.UTC)}
+	c := posts.NewConverter(posts.WithClock(fakeClock))
+
+	post := k3.NewPost().AddText(`Contenido sensible`).AddLabel(k3.LabelPorn).AddLabel(k3.LabelSexual)
+	feedPost := c.ToFeedPost(post)
+	expected := &bsky.FeedPost{
+		LexiconTypeID: "app.bsky.feed.post",
+		CreatedAt:     "2025-01-02T12:34:56.789Z",
+		Text:          `Contenido sensible`,
+		Labels: &bsky.FeedPost_Labels{
+			LabelDefs_SelfLabels: &atproto.LabelDefs_SelfLabels{
+				LexiconTypeID: "com.atproto.label.defs#selfLabels",
+				Values: []*atproto.LabelDefs_SelfLabel{
+					{Val: "porn"},
+					{Val: "sexual"},
+				},
+			},
+		},
+	}
+	assert.Equal(t, expected, feedPost)
+
+	post.SetCreationTime(fakeClock.Time)
+	assert.Equal(t, post, c.FromFeedPost(feedPost))
+}
+
+func TestConvertUnknownLabels(t *testing.T) {
+	c := posts.NewConverter()
+	post := k3.NewPost().AddText(`Spoilers`).AddLabel("spoiler")
+	assert.Nil(t, c.ToFeedPost(post).Labels)
+	post.AddLabel(k3.LabelNudity)
+	assert.Equal(t, []*atproto.LabelDefs_SelfLabel{{Val: "nudity"}}, c.ToFeedPost(post).Labels.LabelDefs_SelfLabels.Values)
+
+	feedPost := &bsky.FeedPost{
+		Text: `Spoilers`,
+		Labels: &bsky.FeedPost_Labels{
+			LabelDefs_SelfLabels: &atproto.LabelDefs_SelfLabels{
+				Values: []*atproto.LabelDefs_SelfLabel{{Val: "spoiler"}, {Val: "porn"}},
+			},
+		},
+	}
+	assert.Equal(t, []string{"porn"}, c.FromFeedPost(feedPost).Labels)
+}
+
+func TestConvertCashtagsAndCustomFeatures(t *testing.T) {
+	c := posts.NewConverter()
+	post := k3.NewPost().
+		AddText("Buy ").
+		AddCashtag("$AAPL", "AAPL").
+		AddText(" ").
+		AddBlock(k3.NewBlock("now", k3.WithCustomFeature("com.example.facet#highlight", map[string]string{"color": "red"})))
+	feedPost := c.ToFeedPost(post)
+	expected := []*bsky.RichtextFacet{
+		{
+			Index: &bsky.RichtextFacet_ByteSlice{ByteStart: 4, ByteEnd: 9},
+			Features: []*bsky.RichtextFacet_Features_Elem{
+				{RichtextFacet_Tag: &bsky.RichtextFacet_Tag{LexiconTypeID: "app.bsky.richtext.facet#tag", Tag: "$AAPL"}},
+			},
+		},
+	}
+	assert.Equal(t, expected, feedPost.Facets)
+	assert.Equal(t, "Buy $AAPL now", feedPost.Text)
+
+	back := c.FromFeedPost(feedPost)
+	ticker, ok := back.Blocks[1].Cashtag()
+	assert.True(t, ok)
+	assert.Equal(t, "AAPL", ticker)
+}
+
+func TestConvertRecordWithCustomFeatures(t *testing.T) {
+	fakeClock := &atptesting.FakeClock{Time: time.Date(2025, time.January, 2, 12, 34, 56, 789000000, time.UTC)}
+	c := posts.NewConverter(posts.WithClock(fakeClock))
+	post := k3.NewPost().
+		SetCreationTime(fakeClock.Time).
+		AddText("Buy ").
+		AddCashtag("$AAPL", "AAPL").
+		AddText(" ").
+		AddBlock(k3.NewBlock("now", k3.WithCustomFeature("com.example.facet#highlight", map[string]string{"color": "red"})))
+	record := c.ToRecord(post)
+	assert.Equal(t, c.ToFeedPost(post), record.FeedPost)
+
+	data, err := json.Marshal(record)
+	require.NoError(t, err)
+	var raw struct {
+		Facets []struct {
+			Index    map[string]int      `json:"index"`
+			Features []map[string]string `json:"features"`
+		} `json:"facets"`
+	}
+	require.NoError(t, json.Unmarshal(data, &raw))
+	require.Len(t, raw.Facets, 2)
+	assert.Equal(t, map[string]int{"byteStart": 10, "byteEnd": 13}, raw.Facets[1].Index)
+	assert.Equal(t, []map[string]string{{"$type": "com.example.facet#highlight", "color": "red"}}, raw.Facets[1].Features)
+
+	var back posts.Record
+	require.NoError(t, json.Unmarshal(data, &back))
+	assert.Equal(t, post, c.FromRecord(&back))
+	// A FeedPost decoded from the same JSON loses the custom feature.
+	for _, block := range c.FromFeedPost(back.FeedPost).Blocks {
+		assert.Empty(t, block.Custom)
+	}
+}
+
+func TestRecordKeepsUnknownFeaturesInKnownFacets(t *testing.T) {
+	data := `{"$type":"app.bsky.feed.post","text":"hello","createdAt":"2025-01-02T12:34:56.789Z","facets":[` +
+		`{"index":{"byteStart":0,"byteEnd":5},"features":[` +
+		`{"$type":"app.bsky.richtext.facet#tag","tag":"greeting"},` +
+		`{"$type":"com.example.facet#rating","stars":5}]}]}`
+	var record posts.Record
+	require.NoError(t, json.Unmarshal([]byte(data), &record))
+	post := posts.NewConverter().FromRecord(&record)
+	require.Len(t, post.Blocks, 1)
+	assert.Equal(t, "greeting", *post.Blocks[0].Tag)
+	assert.Equal(t, []k3.CustomFeature{{Type: "com.example.facet#rating", Fields: map[string]string{"stars": "5"}}}, post.Blocks[0].Custom)
+
+	again, err := json.Marshal(&record)
+	require.NoError(t, err)
+	assert.Contains(t, string(again), `{"$type":"com.example.facet#rating","stars":"5"}`)
+}
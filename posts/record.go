@@ -0,0 +1,147 @@
+package posts
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+
+	"github.com/bluesky-social/indigo/api/bsky"
+	"github.com/jtarrio/k3"
+)
+
+// Record is an app.bsky.feed.post record that can also contain custom facet features,
+// which bsky.FeedPost can't represent.
+//
+// When it is converted to JSON, each custom feature is added to the facet for its text range as an object
+// with its "$type" and its fields, so AppViews that understand it can use it. Use client.RecordPublisher
+// to publish a Record.
+type Record struct {
+	*bsky.FeedPost
+	// CustomFacets contains the custom features of the post and the text ranges they apply to.
+	CustomFacets []CustomFacet
+}
+
+// CustomFacet contains custom features that apply to a range of the text of a post.
+type CustomFacet struct {
+	// ByteStart is the start of the range, in bytes, inclusive.
+	ByteStart int64
+	// ByteEnd is the end of the range, in bytes, exclusive.
+	ByteEnd int64
+	// Features contains the custom features that apply to the range.
+	Features []k3.CustomFeature
+}
+
+type jsonFacet struct {
+	Index    jsonByteSlice     `json:"index"`
+	Features []json.RawMessage `json:"features"`
+}
+
+type jsonByteSlice struct {
+	ByteStart int64 `json:"byteStart"`
+	ByteEnd   int64 `json:"byteEnd"`
+}
+
+var knownFeatureTypes = []string{"app.bsky.richtext.facet#link", "app.bsky.richtext.facet#mention", "app.bsky.richtext.facet#tag"}
+
+func (r *Record) MarshalJSON() ([]byte, error) {
+	if r.FeedPost == nil {
+		return nil, errors.New("the record has no post")
+	}
+	data, err := json.Marshal(r.FeedPost)
+	if err != nil || len(r.CustomFacets) == 0 {
+		return data, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	var facets []jsonFacet
+	if raw, found := fields["facets"]; found {
+		if err := json.Unmarshal(raw, &facets); err != nil {
+			return nil, err
+		}
+	}
+	for _, custom := range r.CustomFacets {
+		index := jsonByteSlice{ByteStart: custom.ByteStart, ByteEnd: custom.ByteEnd}
+		i := slices.IndexFunc(facets, func(f jsonFacet) bool { return f.Index == index })
+		if i < 0 {
+			facets = append(facets, jsonFacet{Index: index})
+			i = len(facets) - 1
+		}
+		for _, feature := range custom.Features {
+			object := map[string]string{}
+			for key, value := range feature.Fields {
+				object[key] = value
+			}
+			object["$type"] = feature.Type
+			raw, err := json.Marshal(object)
+			if err != nil {
+				return nil, err
+			}
+			facets[i].Features = append(facets[i].Features, raw)
+		}
+	}
+	slices.SortStableFunc(facets, func(a, b jsonFacet) int { return int(a.Index.ByteStart - b.Index.ByteStart) })
+	if fields["facets"], err = json.Marshal(facets); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+func (r *Record) UnmarshalJSON(data []byte) error {
+	var feedPost bsky.FeedPost
+	if err := json.Unmarshal(data, &feedPost); err != nil {
+		return err
+	}
+	var raw struct {
+		Facets []jsonFacet `json:"facets"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	var customFacets []CustomFacet
+	for _, facet := range raw.Facets {
+		custom := CustomFacet{ByteStart: facet.Index.ByteStart, ByteEnd: facet.Index.ByteEnd}
+		for _, rawFeature := range facet.Features {
+			var object map[string]json.RawMessage
+			if err := json.Unmarshal(rawFeature, &object); err != nil {
+				return err
+			}
+			var featureType string
+			if err := json.Unmarshal(object["$type"], &featureType); err != nil || slices.Contains(knownFeatureTypes, featureType) {
+				continue
+			}
+			feature := k3.CustomFeature{Type: featureType, Fields: map[string]string{}}
+			for key, value := range object {
+				if key == "$type" {
+					continue
+				}
+				// Values that are not strings are kept as JSON text.
+				var str string
+				if err := json.Unmarshal(value, &str); err != nil {
+					str = string(value)
+				}
+				feature.Fields[key] = str
+			}
+			custom.Features = append(custom.Features, feature)
+		}
+		if len(custom.Features) > 0 {
+			customFacets = append(customFacets, custom)
+		}
+	}
+
+	// bsky.FeedPost decodes unknown features as empty values, which can't be encoded again.
+	for _, facet := range feedPost.Facets {
+		facet.Features = slices.DeleteFunc(facet.Features, func(f *bsky.RichtextFacet_Features_Elem) bool {
+			return f == nil || (f.RichtextFacet_Link == nil && f.RichtextFacet_Mention == nil && f.RichtextFacet_Tag == nil)
+		})
+	}
+	feedPost.Facets = slices.DeleteFunc(feedPost.Facets, func(f *bsky.RichtextFacet) bool { return f == nil || len(f.Features) == 0 })
+	if len(feedPost.Facets) == 0 {
+		feedPost.Facets = nil
+	}
+
+	r.FeedPost = &feedPost
+	r.CustomFacets = customFacets
+	return nil
+}
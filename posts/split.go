@@ -18,8 +18,24 @@ import (
 // strings like "Part three of nine", because the next string would be "part four of nine",
 // which is shorter.
 func Split(post *k3.Post, options ...SplitOption) []*k3.Post {
+	return split(post, nil, options...)
+}
+
+// SplitWithLanguages works like Split, and sets the languages of each part to the ones the detector finds
+// in the part's text.
+//
+// If no language is detected for a part, it keeps the languages of the original post.
+func SplitWithLanguages(post *k3.Post, detector k3.LanguageDetector, options ...SplitOption) []*k3.Post {
+	return split(post, detector, options...)
+}
+
+func split(post *k3.Post, detector k3.LanguageDetector, options ...SplitOption) []*k3.Post {
 	if post.GetGraphemeLength() <= maxPostGraphemeLength {
-		return []*k3.Post{post}
+		if detector == nil {
+			return []*k3.Post{post}
+		}
+		newPost := *post
+		return []*k3.Post{newPost.DetectLanguages(detector)}
 	}
 
 	partFn := DefaultPartFunction
@@ -35,9 +51,13 @@ func Split(post *k3.Post, options ...SplitOption) []*k3.Post {
 		newPost := k3.NewPost()
 		newPost.CreationTime = post.CreationTime
 		newPost.Languages = post.Languages
+		newPost.Labels = post.Labels
 		for _, block := range postBlocks {
 			newPost.AddBlock(block)
 		}
+		if detector != nil {
+			newPost.DetectLanguages(detector)
+		}
 		out = append(out, newPost)
 	}
 	return out
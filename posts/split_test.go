@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/jtarrio/k3"
+	"github.com/jtarrio/k3/langdetect"
 	"github.com/jtarrio/k3/posts"
 	"github.com/stretchr/testify/assert"
 )
@@ -162,3 +163,32 @@ func TestSplitMaximumSize(t *testing.T) {
 		assert.LessOrEqual(t, len(runes), 300)
 	}
 }
+
+func TestSplitCopiesLabels(t *testing.T) {
+	post := k3.NewPost().AddLabel(k3.LabelGraphicMedia).AddText(strings.Repeat(`palabra `, 100))
+	split := posts.Split(post)
+	assert.Len(t, split, 3)
+	for _, part := range split {
+		assert.Equal(t, []string{"graphic-media"}, part.Labels)
+	}
+}
+
+func TestSplitDetectsLanguages(t *testing.T) {
+	post := k3.NewPost().AddLanguage("en").
+		AddText(strings.Repeat("En un lugar de la Mancha, de cuyo nombre no quiero acordarme. ", 4)).
+		AddText(strings.Repeat("Il était une fois une petite fille qui vivait dans un village. ", 4))
+	split := posts.SplitWithLanguages(post, langdetect.New())
+	assert.Len(t, split, 2)
+	assert.Equal(t, []string{"es"}, split[0].Languages)
+	assert.Equal(t, []string{"fr"}, split[1].Languages)
+	assert.Equal(t, []string{"en"}, post.Languages)
+
+	short := k3.NewPost().AddText("Il était une fois une petite fille qui vivait dans un village.")
+	split = posts.SplitWithLanguages(short, langdetect.New())
+	assert.Equal(t, []string{"fr"}, split[0].Languages)
+	assert.Empty(t, short.Languages)
+
+	undetected := k3.NewPost().AddLanguage("en").AddText("12345 67890")
+	split = posts.SplitWithLanguages(undetected, langdetect.New())
+	assert.Equal(t, []string{"en"}, split[0].Languages)
+}
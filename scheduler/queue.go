@@ -0,0 +1,212 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jtarrio/k3"
+	"github.com/jtarrio/k3/internal/atomicfile"
+	"github.com/jtarrio/k3/postfile"
+)
+
+// Item is a post, or a series of posts, scheduled to be published at a given time.
+type Item struct {
+	// ID identifies the item in the queue. If it's empty, Schedule assigns a random ID.
+	ID string
+	// Due is the time when the item should be published.
+	Due time.Time
+	// Posts contains the posts to publish.
+	Posts []*k3.Post
+	// Thread indicates that the posts must be published as a thread instead of as a sequence.
+	Thread bool
+	// Started is the time when the scheduler first tried to publish the item, or zero if it hasn't tried yet.
+	// The scheduler saves it before publishing, and uses it to give the posts the same creation times on every attempt.
+	Started time.Time
+	// Attempts is the number of times that publishing the item failed.
+	Attempts int
+	// Retry is the time when the scheduler will try again to publish the item after a failure.
+	Retry time.Time
+	// Rkeys contains the record keys of the posts. If the client implements client.RkeyPublisher,
+	// the scheduler generates them and saves them with Started, so every attempt uses the same record keys.
+	Rkeys []string
+}
+
+// Queue is an interface for storing scheduled items.
+type Queue interface {
+	// Add stores an item in the queue, replacing any other item with the same ID.
+	Add(item *Item) error
+	// Remove deletes the item with the given ID from the queue. It is not an error if the item doesn't exist.
+	Remove(id string) error
+	// Items returns all the items in the queue, sorted by due time.
+	Items() ([]*Item, error)
+}
+
+// NewMemoryQueue returns a Queue that keeps its items in memory.
+func NewMemoryQueue() Queue {
+	return &memoryQueue{}
+}
+
+type memoryQueue struct {
+	mutex sync.Mutex
+	items []*Item
+}
+
+func (q *memoryQueue) Add(item *Item) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.items = addItem(q.items, item)
+	return nil
+}
+
+func (q *memoryQueue) Remove(id string) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.items = removeItem(q.items, id)
+	return nil
+}
+
+func (q *memoryQueue) Items() ([]*Item, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return slices.Clone(q.items), nil
+}
+
+// NewFileQueue returns a Queue that stores its items in a JSON file, so they survive a restart.
+//
+// The file is created if it doesn't exist. Every change rewrites the whole file atomically.
+func NewFileQueue(path string) (Queue, error) {
+	q := &fileQueue{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file queueFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("could not read queue file %s: %w", path, err)
+	}
+	if file.Version != queueFileVersion {
+		return nil, fmt.Errorf("unsupported queue file version %d", file.Version)
+	}
+	for _, fileItem := range file.Items {
+		item := &Item{ID: fileItem.ID, Due: fileItem.Due, Thread: fileItem.Thread, Attempts: fileItem.Attempts, Rkeys: fileItem.Rkeys}
+		if fileItem.Started != nil {
+			item.Started = *fileItem.Started
+		}
+		if fileItem.Retry != nil {
+			item.Retry = *fileItem.Retry
+		}
+		for _, doc := range fileItem.Posts {
+			post, err := doc.ToPost()
+			if err != nil {
+				return nil, fmt.Errorf("invalid post in item %s: %w", fileItem.ID, err)
+			}
+			item.Posts = append(item.Posts, post)
+		}
+		q.items = addItem(q.items, item)
+	}
+	return q, nil
+}
+
+type fileQueue struct {
+	path  string
+	mutex sync.Mutex
+	items []*Item
+}
+
+const queueFileVersion = 1
+
+type queueFile struct {
+	Version int             `json:"version"`
+	Items   []queueFileItem `json:"items"`
+}
+
+type queueFileItem struct {
+	ID       string               `json:"id"`
+	Due      time.Time            `json:"due"`
+	Thread   bool                 `json:"thread,omitempty"`
+	Posts    []*postfile.Document `json:"posts"`
+	Started  *time.Time           `json:"started,omitempty"`
+	Attempts int                  `json:"attempts,omitempty"`
+	Retry    *time.Time           `json:"retry,omitempty"`
+	Rkeys    []string             `json:"rkeys,omitempty"`
+}
+
+func (q *fileQueue) Add(item *Item) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	items := addItem(slices.Clone(q.items), item)
+	if err := q.save(items); err != nil {
+		return err
+	}
+	q.items = items
+	return nil
+}
+
+func (q *fileQueue) Remove(id string) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	items := removeItem(slices.Clone(q.items), id)
+	if len(items) == len(q.items) {
+		return nil
+	}
+	if err := q.save(items); err != nil {
+		return err
+	}
+	q.items = items
+	return nil
+}
+
+func (q *fileQueue) Items() ([]*Item, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return slices.Clone(q.items), nil
+}
+
+func (q *fileQueue) save(items []*Item) error {
+	file := queueFile{Version: queueFileVersion, Items: []queueFileItem{}}
+	for _, item := range items {
+		fileItem := queueFileItem{ID: item.ID, Due: item.Due, Thread: item.Thread, Attempts: item.Attempts, Rkeys: item.Rkeys}
+		if !item.Started.IsZero() {
+			fileItem.Started = &item.Started
+		}
+		if !item.Retry.IsZero() {
+			fileItem.Retry = &item.Retry
+		}
+		for _, post := range item.Posts {
+			doc := postfile.FromPost(post)
+			if err := doc.Validate(); err != nil {
+				return fmt.Errorf("invalid post in item %s: %w", item.ID, err)
+			}
+			fileItem.Posts = append(fileItem.Posts, doc)
+		}
+		file.Items = append(file.Items, fileItem)
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.Write(q.path, data)
+}
+
+func addItem(items []*Item, item *Item) []*Item {
+	items = removeItem(items, item.ID)
+	i, _ := slices.BinarySearchFunc(items, item, func(a, b *Item) int {
+		if a.Due.After(b.Due) {
+			return 1
+		}
+		return -1
+	})
+	return slices.Insert(items, i, item)
+}
+
+func removeItem(items []*Item, id string) []*Item {
+	return slices.DeleteFunc(items, func(item *Item) bool { return item.ID == id })
+}
@@ -0,0 +1,383 @@
+// Package scheduler publishes posts and threads at scheduled times.
+//
+// Items are kept in a Queue, which can be stored in memory or in a file, and are published through a
+// multiposter.Multiposter when they become due. If the scheduler was not running when an item became due,
+// the MissedPolicy decides what happens to it. Items that fail to publish stay in the queue and are retried later.
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bluesky-social/indigo/api/bsky"
+	"github.com/jtarrio/k3"
+	"github.com/jtarrio/k3/client"
+	"github.com/jtarrio/k3/multiposter"
+	"github.com/jtarrio/k3/posts"
+	"github.com/jtarrio/k3/tid"
+)
+
+// MissedPolicy specifies what to do with items whose due time passed while the scheduler was not running.
+type MissedPolicy int
+
+const (
+	// PublishLate publishes all the missed items, in order. This is the default policy.
+	PublishLate MissedPolicy = iota
+	// SkipMissed removes the missed items without publishing them.
+	SkipMissed
+	// CollapseMissed publishes only the latest of the missed items and removes the others.
+	CollapseMissed
+)
+
+// DefaultGracePeriod is the default time after its due time when an item is considered missed.
+const DefaultGracePeriod = time.Minute
+
+// DefaultRetryDelay is the default time to wait before trying again to publish an item that failed.
+const DefaultRetryDelay = time.Minute
+
+// DefaultMaxRetryDelay is the default maximum time to wait between attempts to publish an item that failed.
+const DefaultMaxRetryDelay = time.Hour
+
+// New creates a new Scheduler that publishes the items in the queue with the given client.
+func New(client client.Client, queue Queue, options ...SchedulerOption) *Scheduler {
+	s := &Scheduler{
+		client:        client,
+		queue:         queue,
+		clock:         k3.SystemClock(),
+		gracePeriod:   DefaultGracePeriod,
+		retryDelay:    DefaultRetryDelay,
+		maxRetryDelay: DefaultMaxRetryDelay,
+		onResult:      func(*Item, *multiposter.PublishResult) {},
+		wake:          make(chan struct{}, 1),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	s.tids = tid.New(tid.WithClock(s.clock))
+	return s
+}
+
+// WithClock makes the scheduler use the given clock to decide when items are due and to wait for them.
+func WithClock(clock k3.Clock) SchedulerOption {
+	return func(s *Scheduler) {
+		s.clock = clock
+	}
+}
+
+// WithMissedPolicy sets what to do with items that were missed while the scheduler was not running.
+func WithMissedPolicy(policy MissedPolicy) SchedulerOption {
+	return func(s *Scheduler) {
+		s.policy = policy
+	}
+}
+
+// WithGracePeriod sets how long after its due time an item is still published normally instead of being considered missed.
+//
+// Only the items that were due this long before Run was called are considered missed.
+func WithGracePeriod(gracePeriod time.Duration) SchedulerOption {
+	return func(s *Scheduler) {
+		s.gracePeriod = gracePeriod
+	}
+}
+
+// WithRetryDelay sets how long to wait before trying again to publish an item that failed.
+//
+// The delay doubles after every failed attempt, up to maxDelay.
+func WithRetryDelay(delay time.Duration, maxDelay time.Duration) SchedulerOption {
+	return func(s *Scheduler) {
+		s.retryDelay = delay
+		s.maxRetryDelay = maxDelay
+	}
+}
+
+// WithMultiposterOptions sets the options for the multiposter used to publish each item.
+//
+// AsThread and AsSequence are set according to each item's Thread field, so you don't need to pass them.
+func WithMultiposterOptions(options ...multiposter.MultiposterOption) SchedulerOption {
+	return func(s *Scheduler) {
+		s.multiposterOptions = options
+	}
+}
+
+// WithResultHandler sets a function that is called after each item is processed.
+//
+// The result is nil if the item was removed without publishing it because of the MissedPolicy.
+// If the result has an error, the item stays in the queue, with its Attempts and Retry fields updated,
+// and the handler is called again after the next attempt.
+func WithResultHandler(handler func(item *Item, result *multiposter.PublishResult)) SchedulerOption {
+	return func(s *Scheduler) {
+		s.onResult = handler
+	}
+}
+
+type SchedulerOption func(*Scheduler)
+
+// Scheduler publishes the items in a queue when they become due.
+type Scheduler struct {
+	client             client.Client
+	queue              Queue
+	clock              k3.Clock
+	policy             MissedPolicy
+	gracePeriod        time.Duration
+	retryDelay         time.Duration
+	maxRetryDelay      time.Duration
+	multiposterOptions []multiposter.MultiposterOption
+	onResult           func(*Item, *multiposter.PublishResult)
+	wake               chan struct{}
+	started            time.Time
+	tids               *tid.Generator
+}
+
+// Schedule adds an item to the queue. If the item has no ID, a random one is assigned.
+func (s *Scheduler) Schedule(item *Item) (string, error) {
+	if item.ID == "" {
+		id := make([]byte, 8)
+		if _, err := rand.Read(id); err != nil {
+			return "", err
+		}
+		item.ID = hex.EncodeToString(id)
+	}
+	if err := s.queue.Add(item); err != nil {
+		return "", err
+	}
+	s.notify()
+	return item.ID, nil
+}
+
+// Cancel removes the item with the given ID from the queue.
+func (s *Scheduler) Cancel(id string) error {
+	if err := s.queue.Remove(id); err != nil {
+		return err
+	}
+	s.notify()
+	return nil
+}
+
+// Run publishes the items in the queue as they become due, until the context is cancelled.
+//
+// Each item is removed from the queue after it has been published, and the result is passed to the result handler.
+// Items that fail to publish stay in the queue and are retried after the delay set with WithRetryDelay.
+//
+// The item's Started field is saved in the queue before the first attempt, and all the attempts give the posts
+// the same creation times. If the client implements client.RkeyPublisher, the scheduler also generates a record key
+// for each post and saves it with Started, so retrying an item that failed partway, or that was being published when
+// the process stopped, doesn't duplicate the posts that were already published. With other clients, those posts are
+// published again.
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.started = s.clock.Now()
+	for {
+		next, err := s.processDue(ctx)
+		if err != nil {
+			return err
+		}
+		var timer k3.Timer
+		var timerC <-chan time.Time
+		if !next.IsZero() {
+			timer = s.clock.NewTimer(next.Sub(s.clock.Now()))
+			timerC = timer.C()
+		}
+		select {
+		case <-ctx.Done():
+		case <-timerC:
+		case <-s.wake:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// processDue publishes or removes the items that are due, and returns the time when the queue must be checked next, if any.
+func (s *Scheduler) processDue(ctx context.Context) (time.Time, error) {
+	items, err := s.queue.Items()
+	if err != nil {
+		return time.Time{}, err
+	}
+	now := s.clock.Now()
+	var due, missed []*Item
+	var next time.Time
+	for _, item := range items {
+		if at := attemptTime(item); at.After(now) {
+			if next.IsZero() || at.Before(next) {
+				next = at
+			}
+		} else if item.Started.IsZero() && s.started.Sub(item.Due) > s.gracePeriod {
+			missed = append(missed, item)
+		} else {
+			due = append(due, item)
+		}
+	}
+
+	switch s.policy {
+	case PublishLate:
+		due = append(missed, due...)
+	case SkipMissed:
+		for _, item := range missed {
+			if err := s.queue.Remove(item.ID); err != nil {
+				return time.Time{}, err
+			}
+			s.onResult(item, nil)
+		}
+	case CollapseMissed:
+		if len(missed) > 0 {
+			for _, item := range missed[:len(missed)-1] {
+				if err := s.queue.Remove(item.ID); err != nil {
+					return time.Time{}, err
+				}
+				s.onResult(item, nil)
+			}
+			due = append([]*Item{missed[len(missed)-1]}, due...)
+		}
+	}
+
+	for _, item := range due {
+		if err := ctx.Err(); err != nil {
+			return time.Time{}, err
+		}
+		if err := s.process(ctx, item); err != nil {
+			return time.Time{}, err
+		}
+	}
+	if len(due) > 0 {
+		// Failed items have a new retry time, and more items may have become due while publishing.
+		return now, nil
+	}
+	return next, nil
+}
+
+// process publishes an item and removes it from the queue or, if publishing failed, schedules the next attempt.
+func (s *Scheduler) process(ctx context.Context, item *Item) error {
+	attempt := *item
+	_, withRkeys := s.client.(client.RkeyPublisher)
+	withRkeys = withRkeys && len(attempt.Rkeys) != len(attempt.Posts)
+	if attempt.Started.IsZero() || withRkeys {
+		if attempt.Started.IsZero() {
+			attempt.Started = s.clock.Now()
+		}
+		if withRkeys {
+			attempt.Rkeys = nil
+			for range attempt.Posts {
+				attempt.Rkeys = append(attempt.Rkeys, s.tids.Next())
+			}
+		}
+		if err := s.queue.Add(&attempt); err != nil {
+			return err
+		}
+	}
+	result := s.publish(ctx, &attempt)
+	current, err := s.find(attempt.ID)
+	if err != nil {
+		return err
+	}
+	// Leave the queue alone if the item was cancelled or replaced while it was being published.
+	if current != nil && current.Started.Equal(attempt.Started) {
+		if result.Error == nil {
+			err = s.queue.Remove(attempt.ID)
+		} else {
+			attempt.Attempts++
+			attempt.Retry = s.clock.Now().Add(s.backoff(attempt.Attempts))
+			err = s.queue.Add(&attempt)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	s.onResult(&attempt, result)
+	return nil
+}
+
+func (s *Scheduler) publish(ctx context.Context, item *Item) *multiposter.PublishResult {
+	converter := posts.NewConverter(posts.WithClock(fixedClock{Clock: s.clock, now: item.Started}))
+	feedPosts := converter.ToFeedPosts(item.Posts)
+	var options []multiposter.MultiposterOption
+	if _, ok := s.client.(client.RkeyPublisher); ok {
+		options = append(options, multiposter.WithRkeys(itemRkeys(feedPosts, item.Rkeys)))
+	}
+	options = append(options, s.multiposterOptions...)
+	if item.Thread {
+		options = append(options, multiposter.AsThread())
+	} else {
+		options = append(options, multiposter.AsSequence())
+	}
+	return multiposter.New(s.client, options...).Publish(ctx, feedPosts)
+}
+
+// find returns the item with the given ID from the queue, or nil if there isn't one.
+func (s *Scheduler) find(id string) (*Item, error) {
+	items, err := s.queue.Items()
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if item.ID == id {
+			return item, nil
+		}
+	}
+	return nil, nil
+}
+
+// backoff returns the time to wait after the given number of failed attempts.
+func (s *Scheduler) backoff(attempts int) time.Duration {
+	delay := s.retryDelay
+	for i := 1; i < attempts && delay < s.maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, s.maxRetryDelay)
+}
+
+// attemptTime returns the time when the item must be published next.
+func attemptTime(item *Item) time.Time {
+	if item.Retry.After(item.Due) {
+		return item.Retry
+	}
+	return item.Due
+}
+
+// itemRkeys returns an RkeyFunc that gives each post the record key at the same position in rkeys.
+//
+// The multiposter may pass copies of the posts, so they are matched by their creation time; posts with the same
+// creation time get their record keys in order.
+func itemRkeys(feedPosts []*bsky.FeedPost, rkeys []string) multiposter.RkeyFunc {
+	byTime := map[string][]string{}
+	for i, post := range feedPosts {
+		if i < len(rkeys) {
+			byTime[post.CreatedAt] = append(byTime[post.CreatedAt], rkeys[i])
+		}
+	}
+	var mutex sync.Mutex
+	return func(post *bsky.FeedPost) (string, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		pending := byTime[post.CreatedAt]
+		if len(pending) == 0 {
+			return "", fmt.Errorf("no record key for the post created at %s", post.CreatedAt)
+		}
+		byTime[post.CreatedAt] = pending[1:]
+		return pending[0], nil
+	}
+}
+
+// fixedClock is a clock whose Now method always returns the same time.
+type fixedClock struct {
+	k3.Clock
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.now
+}
+
+// notify wakes up the Run loop so it sees the changes in the queue.
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,342 @@
+package scheduler_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jtarrio/k3"
+	"github.com/jtarrio/k3/client"
+	"github.com/jtarrio/k3/multiposter"
+	"github.com/jtarrio/k3/scheduler"
+	atptesting "github.com/jtarrio/k3/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var startTime = time.Date(2025, time.January, 2, 12, 0, 0, 0, time.UTC)
+
+// runScheduler starts the scheduler and returns a channel that receives the ID of each processed item.
+func runScheduler(t *testing.T, c client.Client, queue scheduler.Queue, options ...scheduler.SchedulerOption) (*scheduler.Scheduler, <-chan string) {
+	processed := make(chan string, 100)
+	options = append(options, scheduler.WithResultHandler(func(item *scheduler.Item, result *multiposter.PublishResult) {
+		if result != nil {
+			assert.NoError(t, result.Error)
+		}
+		processed <- item.ID
+	}))
+	return startScheduler(t, c, queue, options...), processed
+}
+
+// startScheduler creates a scheduler and runs it until the end of the test.
+func startScheduler(t *testing.T, c client.Client, queue scheduler.Queue, options ...scheduler.SchedulerOption) *scheduler.Scheduler {
+	s := scheduler.New(c, queue, options...)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		assert.ErrorIs(t, s.Run(ctx), context.Canceled)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return s
+}
+
+func item(id string, due time.Time, texts ...string) *scheduler.Item {
+	out := &scheduler.Item{ID: id, Due: due}
+	for _, text := range texts {
+		out.Posts = append(out.Posts, k3.NewPost().AddText(text))
+	}
+	return out
+}
+
+func TestPublishWhenDue(t *testing.T) {
+	clock := &atptesting.FakeClock{Time: startTime}
+	queue := scheduler.NewMemoryQueue()
+	require.NoError(t, queue.Add(item("b", startTime.Add(2*time.Hour), "second")))
+	require.NoError(t, queue.Add(item("a", startTime.Add(time.Hour), "first")))
+	c := atptesting.NewFakeClient()
+	_, processed := runScheduler(t, c, queue, scheduler.WithClock(clock))
+
+	clock.BlockUntil(1)
+	assert.Empty(t, c.Texts())
+	clock.Advance(time.Hour)
+	assert.Equal(t, "a", <-processed)
+	assert.Equal(t, []string{"first"}, c.Texts())
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Hour)
+	assert.Equal(t, "b", <-processed)
+	assert.Equal(t, []string{"first", "second"}, c.Texts())
+
+	items, err := queue.Items()
+	require.NoError(t, err)
+	assert.Empty(t, items)
+}
+
+func TestScheduleWhileRunning(t *testing.T) {
+	clock := &atptesting.FakeClock{Time: startTime}
+	c := atptesting.NewFakeClient()
+	s, processed := runScheduler(t, c, scheduler.NewMemoryQueue(), scheduler.WithClock(clock))
+
+	id, err := s.Schedule(item("", startTime.Add(time.Minute), "one", "two"))
+	require.NoError(t, err)
+	assert.NotEmpty(t, id)
+	_, err = s.Schedule(item("cancelled", startTime.Add(time.Minute), "three"))
+	require.NoError(t, err)
+	require.NoError(t, s.Cancel("cancelled"))
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	assert.Equal(t, id, <-processed)
+	assert.Equal(t, []string{"one", "two"}, c.Texts())
+}
+
+func TestPublishThread(t *testing.T) {
+	clock := &atptesting.FakeClock{Time: startTime}
+	queue := scheduler.NewMemoryQueue()
+	thread := item("thread", startTime, "root", "reply")
+	thread.Thread = true
+	require.NoError(t, queue.Add(thread))
+	c := atptesting.NewFakeClient()
+	_, processed := runScheduler(t, c, queue, scheduler.WithClock(clock))
+
+	assert.Equal(t, "thread", <-processed)
+	published := c.Posts()
+	require.Len(t, published, 2)
+	assert.Nil(t, published[0].Reply)
+	assert.Equal(t, "at://did:plc:me/app.bsky.feed.post/0", published[1].Reply.Root.Uri)
+}
+
+func missedQueue(t *testing.T) scheduler.Queue {
+	queue := scheduler.NewMemoryQueue()
+	require.NoError(t, queue.Add(item("missed1", startTime.Add(-3*time.Hour), "missed 1")))
+	require.NoError(t, queue.Add(item("missed2", startTime.Add(-2*time.Hour), "missed 2")))
+	require.NoError(t, queue.Add(item("late", startTime.Add(-30*time.Second), "slightly late")))
+	require.NoError(t, queue.Add(item("future", startTime.Add(time.Hour), "future")))
+	return queue
+}
+
+func TestMissedPolicies(t *testing.T) {
+	tests := []struct {
+		policy    scheduler.MissedPolicy
+		processed []string
+		published []string
+	}{
+		{scheduler.PublishLate, []string{"missed1", "missed2", "late"}, []string{"missed 1", "missed 2", "slightly late"}},
+		{scheduler.SkipMissed, []string{"missed1", "missed2", "late"}, []string{"slightly late"}},
+		{scheduler.CollapseMissed, []string{"missed1", "missed2", "late"}, []string{"missed 2", "slightly late"}},
+	}
+	for _, test := range tests {
+		clock := &atptesting.FakeClock{Time: startTime}
+		c := atptesting.NewFakeClient()
+		queue := missedQueue(t)
+		_, processed := runScheduler(t, c, queue, scheduler.WithClock(clock), scheduler.WithMissedPolicy(test.policy))
+		var ids []string
+		for range test.processed {
+			ids = append(ids, <-processed)
+		}
+		assert.Equal(t, test.processed, ids)
+		assert.Equal(t, test.published, c.Texts())
+		items, err := queue.Items()
+		require.NoError(t, err)
+		assert.Len(t, items, 1)
+	}
+}
+
+func TestGracePeriod(t *testing.T) {
+	clock := &atptesting.FakeClock{Time: startTime}
+	c := atptesting.NewFakeClient()
+	_, processed := runScheduler(t, c, missedQueue(t),
+		scheduler.WithClock(clock), scheduler.WithMissedPolicy(scheduler.SkipMissed), scheduler.WithGracePeriod(5*time.Hour))
+	for range 3 {
+		<-processed
+	}
+	assert.Equal(t, []string{"missed 1", "missed 2", "slightly late"}, c.Texts())
+}
+
+func TestItemsDueWhilePublishingAreNotMissed(t *testing.T) {
+	clock := &atptesting.FakeClock{Time: startTime}
+	queue := scheduler.NewMemoryQueue()
+	require.NoError(t, queue.Add(item("a", startTime, "first")))
+	require.NoError(t, queue.Add(item("b", startTime.Add(time.Hour), "second")))
+	// Publishing the first item takes longer than the grace period of the second one.
+	slow := true
+	c := atptesting.NewFakeClient().OnPublish(func() {
+		if slow {
+			slow = false
+			clock.Advance(2 * time.Hour)
+		}
+	})
+	_, processed := runScheduler(t, c, queue, scheduler.WithClock(clock), scheduler.WithMissedPolicy(scheduler.SkipMissed))
+
+	assert.Equal(t, "a", <-processed)
+	assert.Equal(t, "b", <-processed)
+	assert.Equal(t, []string{"first", "second"}, c.Texts())
+}
+
+func TestRetryFailedItems(t *testing.T) {
+	clock := &atptesting.FakeClock{Time: startTime}
+	queue := scheduler.NewMemoryQueue()
+	require.NoError(t, queue.Add(item("a", startTime, "one")))
+	c := atptesting.NewFakeClient().FailAfter(0)
+	results := make(chan *multiposter.PublishResult, 10)
+	startScheduler(t, c, queue, scheduler.WithClock(clock), scheduler.WithRetryDelay(time.Minute, 90*time.Second),
+		scheduler.WithResultHandler(func(item *scheduler.Item, result *multiposter.PublishResult) { results <- result }))
+
+	// The item stays in the queue after each failure, and the delay doubles up to the maximum.
+	for i, delay := range []time.Duration{time.Minute, 90 * time.Second, 90 * time.Second} {
+		assert.ErrorIs(t, (<-results).Error, atptesting.ErrFakeClientFailure)
+		items, err := queue.Items()
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+		assert.True(t, items[0].Started.Equal(startTime))
+		assert.Equal(t, i+1, items[0].Attempts)
+		assert.True(t, items[0].Retry.Equal(clock.Now().Add(delay)))
+		clock.BlockUntil(1)
+		if i == 2 {
+			c.FailAfter(-1)
+		}
+		clock.Advance(delay)
+	}
+
+	assert.NoError(t, (<-results).Error)
+	assert.Equal(t, []string{"one"}, c.Texts())
+	items, err := queue.Items()
+	require.NoError(t, err)
+	assert.Empty(t, items)
+}
+
+// failingTransport sends the requests to the server, except the given createRecord call, which fails without being sent.
+type failingTransport struct {
+	mutex   sync.Mutex
+	creates int
+	failOn  int
+	// onCreate, if not nil, is called before each createRecord call.
+	onCreate func()
+}
+
+func (f *failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.HasSuffix(req.URL.Path, "/com.atproto.repo.createRecord") {
+		f.mutex.Lock()
+		f.creates++
+		failed := f.creates == f.failOn
+		f.mutex.Unlock()
+		if f.onCreate != nil {
+			f.onCreate()
+		}
+		if failed {
+			return nil, errors.New("connection lost")
+		}
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestRetryDoesNotDuplicatePosts(t *testing.T) {
+	clock := &atptesting.FakeClock{Time: startTime}
+	fakeServer := atptesting.NewFakeServer(atptesting.WithClock(clock))
+	fakeServer.AddUser("testuser", "testpass")
+	defer fakeServer.Close()
+	path := filepath.Join(t.TempDir(), "queue.json")
+	queue, err := scheduler.NewFileQueue(path)
+	require.NoError(t, err)
+	thread := item("thread", startTime, "root", "reply")
+	thread.Thread = true
+	require.NoError(t, queue.Add(thread))
+
+	// The item is marked as started in the file before anything is published.
+	transport := &failingTransport{failOn: 3, onCreate: func() {
+		reopened, err := scheduler.NewFileQueue(path)
+		require.NoError(t, err)
+		items, err := reopened.Items()
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+		assert.True(t, items[0].Started.Equal(startTime))
+	}}
+	c := client.New("testuser", "testpass", client.WithHost(fakeServer.URL()), client.WithClock(clock),
+		client.WithHttpClient(&http.Client{Transport: transport}))
+	results := make(chan *multiposter.PublishResult, 10)
+	startScheduler(t, c, queue, scheduler.WithClock(clock),
+		scheduler.WithMultiposterOptions(multiposter.WithReplyRules(multiposter.AllowFollowers())),
+		scheduler.WithResultHandler(func(item *scheduler.Item, result *multiposter.PublishResult) { results <- result }))
+
+	// The root and its threadgate are published, but the reply fails.
+	assert.ErrorContains(t, (<-results).Error, "connection lost")
+	require.Len(t, fakeServer.Posts, 1)
+
+	// The retry finds the root and its threadgate instead of publishing them again.
+	clock.BlockUntil(1)
+	clock.Advance(scheduler.DefaultRetryDelay)
+	result := <-results
+	require.NoError(t, result.Error)
+	require.Len(t, fakeServer.Posts, 2)
+	assert.Len(t, fakeServer.Threadgates, 1)
+	assert.Equal(t, []string{"root", "reply"}, []string{fakeServer.Posts[0].Record.Text, fakeServer.Posts[1].Record.Text})
+	assert.Equal(t, result.Published[0].Uri, fakeServer.Posts[1].Record.Reply.Root.Uri)
+}
+
+func TestItemsStartedTogetherGetDifferentRkeys(t *testing.T) {
+	clock := &atptesting.FakeClock{Time: startTime}
+	fakeServer := atptesting.NewFakeServer(atptesting.WithClock(clock))
+	fakeServer.AddUser("testuser", "testpass")
+	defer fakeServer.Close()
+	queue := scheduler.NewMemoryQueue()
+	require.NoError(t, queue.Add(item("item91", startTime, "first")))
+	require.NoError(t, queue.Add(item("item110", startTime, "second")))
+
+	c := client.New("testuser", "testpass", client.WithHost(fakeServer.URL()), client.WithClock(clock))
+	results := make(chan *multiposter.PublishResult, 10)
+	startScheduler(t, c, queue, scheduler.WithClock(clock),
+		scheduler.WithResultHandler(func(item *scheduler.Item, result *multiposter.PublishResult) { results <- result }))
+
+	require.NoError(t, (<-results).Error)
+	require.NoError(t, (<-results).Error)
+	require.Len(t, fakeServer.Posts, 2)
+	assert.NotEqual(t, fakeServer.Posts[0].Rkey, fakeServer.Posts[1].Rkey)
+}
+
+func TestFileQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+	queue, err := scheduler.NewFileQueue(path)
+	require.NoError(t, err)
+	items, err := queue.Items()
+	require.NoError(t, err)
+	assert.Empty(t, items)
+
+	thread := item("thread", startTime.Add(2*time.Hour), "root", "reply")
+	thread.Thread = true
+	thread.Posts[0].AddLanguage("en").AddLink(" link", "https://example.com/")
+	require.NoError(t, queue.Add(thread))
+	single := item("single", startTime.Add(time.Hour), "single")
+	single.Started = startTime.Add(time.Hour)
+	single.Attempts = 2
+	single.Retry = startTime.Add(2 * time.Hour)
+	single.Rkeys = []string{"3jzfcijpj2z2a"}
+	require.NoError(t, queue.Add(single))
+	require.NoError(t, queue.Add(item("removed", startTime, "removed")))
+	require.NoError(t, queue.Remove("removed"))
+
+	reopened, err := scheduler.NewFileQueue(path)
+	require.NoError(t, err)
+	items, err = reopened.Items()
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.Equal(t, "single", items[0].ID)
+	assert.True(t, items[0].Due.Equal(startTime.Add(time.Hour)))
+	assert.True(t, items[0].Started.Equal(single.Started))
+	assert.Equal(t, 2, items[0].Attempts)
+	assert.True(t, items[0].Retry.Equal(single.Retry))
+	assert.Equal(t, single.Rkeys, items[0].Rkeys)
+	assert.True(t, items[1].Started.IsZero())
+	assert.Zero(t, items[1].Attempts)
+	assert.Equal(t, "thread", items[1].ID)
+	assert.True(t, items[1].Thread)
+	assert.Equal(t, thread.Posts, items[1].Posts)
+}
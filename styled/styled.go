@@ -0,0 +1,91 @@
+// Package styled converts text into bold, italic, or monospace text using Unicode characters.
+//
+// Bluesky posts don't support any formatting, but the Unicode standard contains several alphabets
+// of "Mathematical Alphanumeric Symbols" that look like bold, italic, or monospace versions of the
+// Latin letters and digits. This package converts plain text into those alphabets.
+//
+// Only the unaccented Latin letters (A-Z, a-z) and the digits (0-9) have styled versions.
+// Any other characters are left unchanged. As each styled character is a single code point,
+// the length of the text in graphemes doesn't change when it is styled.
+package styled
+
+import "strings"
+
+// Style is the type for the available text styles.
+type Style int
+
+const (
+	// Plain leaves the text unchanged.
+	Plain Style = iota
+	// Bold turns the text into bold text.
+	Bold
+	// Italic turns the text into italic text. Digits don't have an italic version, so they are left unchanged.
+	Italic
+	// BoldItalic turns the text into bold italic text. Digits don't have an italic version, so they are made bold.
+	BoldItalic
+	// Monospace turns the text into monospace text.
+	Monospace
+)
+
+// StyleOf returns the style that corresponds to the given combination of attributes.
+//
+// Monospace text can't be made bold or italic, so monospace takes precedence over the other attributes.
+func StyleOf(bold bool, italic bool, monospace bool) Style {
+	switch {
+	case monospace:
+		return Monospace
+	case bold && italic:
+		return BoldItalic
+	case bold:
+		return Bold
+	case italic:
+		return Italic
+	default:
+		return Plain
+	}
+}
+
+// Apply returns the given text converted into the given style.
+func Apply(text string, style Style) string {
+	if style == Plain {
+		return text
+	}
+	return strings.Map(func(r rune) rune { return ApplyRune(r, style) }, text)
+}
+
+// ApplyRune returns the given character converted into the given style.
+//
+// If there is no styled version of the character, it is returned unchanged.
+func ApplyRune(r rune, style Style) rune {
+	if style == Plain {
+		return r
+	}
+	a := alphabets[style]
+	switch {
+	case r >= 'A' && r <= 'Z':
+		return a.upper + r - 'A'
+	case r >= 'a' && r <= 'z':
+		if exception, found := a.exceptions[r]; found {
+			return exception
+		}
+		return a.lower + r - 'a'
+	case r >= '0' && r <= '9' && a.digits != 0:
+		return a.digits + r - '0'
+	default:
+		return r
+	}
+}
+
+type alphabet struct {
+	upper      rune
+	lower      rune
+	digits     rune
+	exceptions map[rune]rune
+}
+
+var alphabets = map[Style]alphabet{
+	Bold:       {upper: 0x1d400, lower: 0x1d41a, digits: 0x1d7ce},
+	Italic:     {upper: 0x1d434, lower: 0x1d44e, exceptions: map[rune]rune{'h': 0x210e}},
+	BoldItalic: {upper: 0x1d468, lower: 0x1d482, digits: 0x1d7ce},
+	Monospace:  {upper: 0x1d670, lower: 0x1d68a, digits: 0x1d7f6},
+}
@@ -0,0 +1,34 @@
+package styled_test
+
+import (
+	"testing"
+
+	"github.com/jtarrio/k3/styled"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStyles(t *testing.T) {
+	const text = `Hola, Quijote 123!`
+	assert.Equal(t, text, styled.Apply(text, styled.Plain))
+	assert.Equal(t, `𝐇𝐨𝐥𝐚, 𝐐𝐮𝐢𝐣𝐨𝐭𝐞 𝟏𝟐𝟑!`, styled.Apply(text, styled.Bold))
+	assert.Equal(t, `𝐻𝑜𝑙𝑎, 𝑄𝑢𝑖𝑗𝑜𝑡𝑒 123!`, styled.Apply(text, styled.Italic))
+	assert.Equal(t, `𝑯𝒐𝒍𝒂, 𝑸𝒖𝒊𝒋𝒐𝒕𝒆 𝟏𝟐𝟑!`, styled.Apply(text, styled.BoldItalic))
+	assert.Equal(t, `𝙷𝚘𝚕𝚊, 𝚀𝚞𝚒𝚓𝚘𝚝𝚎 𝟷𝟸𝟹!`, styled.Apply(text, styled.Monospace))
+}
+
+func TestItalicH(t *testing.T) {
+	assert.Equal(t, `𝑎𝑏𝑐𝑑𝑒𝑓𝑔ℎ𝑖𝑗`, styled.Apply(`abcdefghij`, styled.Italic))
+}
+
+func TestFallback(t *testing.T) {
+	assert.Equal(t, `𝐄𝐧 𝐞𝐬𝐭𝐨, 𝐝𝐞𝐬𝐜𝐮𝐛𝐫𝐢𝐞𝐫𝐨𝐧 𝐥𝐨𝐬 𝐦𝐨𝐥𝐢𝐧𝐨𝐬 𝐝𝐞 𝐯𝐢𝐞𝐧𝐭𝐨 𝐪𝐮𝐞 𝐡𝐚𝐲 𝐞𝐧 𝐚𝐪𝐮𝐞𝐥 𝐜𝐚𝐦𝐩𝐨, 𝐲 𝐚𝐬í 𝐜𝐨𝐦𝐨 🙂`,
+		styled.Apply(`En esto, descubrieron los molinos de viento que hay en aquel campo, y así como 🙂`, styled.Bold))
+}
+
+func TestStyleOf(t *testing.T) {
+	assert.Equal(t, styled.Plain, styled.StyleOf(false, false, false))
+	assert.Equal(t, styled.Bold, styled.StyleOf(true, false, false))
+	assert.Equal(t, styled.Italic, styled.StyleOf(false, true, false))
+	assert.Equal(t, styled.BoldItalic, styled.StyleOf(true, true, false))
+	assert.Equal(t, styled.Monospace, styled.StyleOf(true, true, true))
+}
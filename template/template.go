@@ -0,0 +1,289 @@
+// Package template renders Bluesky posts from templates written in the text/template syntax.
+//
+// In addition to the standard text/template actions and functions, templates can use the following functions:
+//
+//   - {{link URI [TEXT]}} adds a link to URI. If TEXT is not specified, the URI is used as the text.
+//   - {{mention HANDLE [TEXT]}} adds a mention of the user with the given handle or DID.
+//     If TEXT is not specified, the handle with an initial '@' is used as the text.
+//     If the handle can't be resolved, TEXT is added as plain text.
+//   - {{tag TAG [TEXT]}} adds a tag. If TEXT is not specified, the tag with an initial '#' is used as the text.
+//   - {{optional [PRIORITY]}} ... {{end_optional}} delimit a section that can be dropped if the post is too long.
+//
+// When the post is longer than the maximum length, optional sections are dropped, one at a time, until it fits.
+// Sections with lower priority are dropped first; among sections with the same priority, the last one is dropped first.
+// The default priority is 0. Optional sections can be nested; dropping a section also drops the sections inside it.
+package template
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	gotemplate "text/template"
+
+	"github.com/jtarrio/k3"
+	"github.com/jtarrio/k3/import/text"
+)
+
+// Template is a parsed template that can be rendered into posts.
+type Template struct {
+	tmpl           *gotemplate.Template
+	handleResolver text.HandleResolver
+	textImporter   text.Importer
+	maxLength      int
+}
+
+// Parse parses a template with the given options.
+func Parse(tmpl string, options ...TemplateOption) (*Template, error) {
+	t := &Template{handleResolver: text.DefaultHandleResolver, maxLength: DefaultMaxLength}
+	for _, option := range options {
+		option(t)
+	}
+	parsed, err := gotemplate.New("post").Funcs(gotemplate.FuncMap{
+		"link":         func(string, ...string) string { return "" },
+		"mention":      func(string, ...string) string { return "" },
+		"tag":          func(string, ...string) string { return "" },
+		"optional":     func(...int) string { return "" },
+		"end_optional": func() string { return "" },
+	}).Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	t.tmpl = parsed
+	return t, nil
+}
+
+// Must is a helper that wraps a call to Parse and panics if the error is not nil.
+func Must(t *Template, err error) *Template {
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// WithHandleResolver sets the function to use to resolve the handles passed to the 'mention' function.
+//
+// By default, handles are not resolved, so mentions are added as plain text unless they are given a DID.
+func WithHandleResolver(r text.HandleResolver) TemplateOption {
+	return func(t *Template) {
+		t.handleResolver = r
+	}
+}
+
+// WithTextImporter makes the template use the given importer to convert the text outside of the template functions.
+//
+// By default, this text is added to the post as plain text. By using a text.Importer, any URLs, handles and hashtags
+// that appear in the text, including in the values of variables, are converted into links, mentions and tags.
+func WithTextImporter(importer text.Importer) TemplateOption {
+	return func(t *Template) {
+		t.textImporter = importer
+	}
+}
+
+// WithMaxLength sets the maximum length of the post, in graphemes, that triggers the removal of optional sections.
+//
+// A value of 0 disables the removal of optional sections.
+func WithMaxLength(maxLength int) TemplateOption {
+	return func(t *Template) {
+		t.maxLength = maxLength
+	}
+}
+
+type TemplateOption func(*Template)
+
+// DefaultMaxLength is the default maximum length of a post, which is the maximum length allowed by Bluesky.
+const DefaultMaxLength = 300
+
+// Execute renders the template with the given data into a post.
+//
+// If the post is longer than the maximum length even after all the optional sections have been removed,
+// it is returned anyway, so it can be split with posts.Split.
+func (t *Template) Execute(data any) (*k3.Post, error) {
+	r := &renderer{template: t}
+	tmpl, err := t.tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	tmpl.Funcs(gotemplate.FuncMap{
+		"link":         r.link,
+		"mention":      r.mention,
+		"tag":          r.tag,
+		"optional":     r.optional,
+		"end_optional": r.endOptional,
+	})
+	sb := strings.Builder{}
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return nil, err
+	}
+	tokens, err := r.tokenize(sb.String())
+	if err != nil {
+		return nil, err
+	}
+
+	dropped := map[int]bool{}
+	for {
+		post := t.toPost(tokens, dropped)
+		if t.maxLength <= 0 || post.GetGraphemeLength() <= t.maxLength {
+			return post, nil
+		}
+		section := nextDroppable(tokens, dropped)
+		if section < 0 {
+			return post, nil
+		}
+		dropped[section] = true
+	}
+}
+
+// renderer holds the state for an execution of the template.
+type renderer struct {
+	template *Template
+	items    []item
+}
+
+// item is the content that a function produced during the execution of the template.
+type item struct {
+	kind     itemKind
+	block    k3.PostBlock
+	priority int
+}
+
+type itemKind int
+
+const (
+	blockItem itemKind = iota
+	optionalItem
+	endOptionalItem
+)
+
+// The output of the template functions is a marker that contains an index into the renderer's items.
+const markerDelimiter = "\x00"
+
+func (r *renderer) marker(it item) string {
+	r.items = append(r.items, it)
+	return markerDelimiter + strconv.Itoa(len(r.items)-1) + markerDelimiter
+}
+
+func (r *renderer) link(uri string, txt ...string) string {
+	return r.marker(item{kind: blockItem, block: k3.NewBlock(textOr(txt, uri), k3.WithLink(uri))})
+}
+
+func (r *renderer) mention(handle string, txt ...string) string {
+	handle, _ = strings.CutPrefix(handle, "@")
+	display := textOr(txt, "@"+handle)
+	if strings.HasPrefix(handle, "did:") {
+		return r.marker(item{kind: blockItem, block: k3.NewBlock(display, k3.WithMention(handle))})
+	}
+	if did := r.template.handleResolver(handle); did != nil {
+		return r.marker(item{kind: blockItem, block: k3.NewBlock(display, k3.WithMention(*did))})
+	}
+	return r.marker(item{kind: blockItem, block: k3.NewBlock(display)})
+}
+
+func (r *renderer) tag(tag string, txt ...string) string {
+	tag, _ = strings.CutPrefix(tag, "#")
+	return r.marker(item{kind: blockItem, block: k3.NewBlock(textOr(txt, "#"+tag), k3.WithTag(tag))})
+}
+
+func (r *renderer) optional(priority ...int) string {
+	p := 0
+	if len(priority) > 0 {
+		p = priority[0]
+	}
+	return r.marker(item{kind: optionalItem, priority: p})
+}
+
+func (r *renderer) endOptional() string {
+	return r.marker(item{kind: endOptionalItem})
+}
+
+func textOr(txt []string, def string) string {
+	if len(txt) > 0 {
+		return strings.Join(txt, " ")
+	}
+	return def
+}
+
+// token is a piece of the template's output: either some text or an item.
+type token struct {
+	text string
+	item *item
+	// end is the index of the matching end_optional token, for optional tokens.
+	end int
+}
+
+// tokenize splits the template's output into text and items, and matches optional and end_optional tokens.
+func (r *renderer) tokenize(output string) ([]token, error) {
+	parts := strings.Split(output, markerDelimiter)
+	if len(parts)%2 == 0 {
+		return nil, fmt.Errorf("the template output contains an unexpected NUL character")
+	}
+	var tokens []token
+	var open []int
+	for i, part := range parts {
+		if i%2 == 0 {
+			if len(part) > 0 {
+				tokens = append(tokens, token{text: part})
+			}
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || n >= len(r.items) {
+			return nil, fmt.Errorf("the template output contains an unexpected NUL character")
+		}
+		it := &r.items[n]
+		switch it.kind {
+		case optionalItem:
+			open = append(open, len(tokens))
+		case endOptionalItem:
+			if len(open) == 0 {
+				return nil, fmt.Errorf("end_optional without a matching optional")
+			}
+			tokens[open[len(open)-1]].end = len(tokens)
+			open = open[:len(open)-1]
+		}
+		tokens = append(tokens, token{item: it})
+	}
+	if len(open) > 0 {
+		return nil, fmt.Errorf("optional without a matching end_optional")
+	}
+	return tokens, nil
+}
+
+// nextDroppable returns the index of the optional token for the next section to drop, or -1 if there are none.
+func nextDroppable(tokens []token, dropped map[int]bool) int {
+	best := -1
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i].item == nil || tokens[i].item.kind != optionalItem {
+			continue
+		}
+		if dropped[i] {
+			i = tokens[i].end
+			continue
+		}
+		if best < 0 || tokens[i].item.priority <= tokens[best].item.priority {
+			best = i
+		}
+	}
+	return best
+}
+
+func (t *Template) toPost(tokens []token, dropped map[int]bool) *k3.Post {
+	post := k3.NewPost()
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch {
+		case tok.item == nil:
+			if t.textImporter == nil {
+				post.AddText(tok.text)
+				continue
+			}
+			for _, block := range t.textImporter.Import(tok.text).Blocks {
+				post.AddBlock(block)
+			}
+		case tok.item.kind == blockItem:
+			post.AddBlock(tok.item.block)
+		case tok.item.kind == optionalItem && dropped[i]:
+			i = tok.end
+		}
+	}
+	return post
+}
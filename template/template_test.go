@@ -0,0 +1,143 @@
+package template_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jtarrio/k3"
+	"github.com/jtarrio/k3/import/text"
+	"github.com/jtarrio/k3/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type release struct {
+	Version string
+	URL     string
+	Author  string
+	Notes   string
+}
+
+func resolveHandle(handle string) *string {
+	if handle == "alice.bsky.social" {
+		did := "did:plc:alice"
+		return &did
+	}
+	return nil
+}
+
+func TestFunctions(t *testing.T) {
+	tmpl := template.Must(template.Parse(
+		`Version {{.Version}} is out! Read the {{link .URL "release notes"}}. Thanks, {{mention .Author}}! {{tag "release"}}`,
+		template.WithHandleResolver(resolveHandle)))
+	post, err := tmpl.Execute(release{Version: "1.2", URL: "https://example.com/1.2", Author: "alice.bsky.social"})
+	require.NoError(t, err)
+	expected := k3.NewPost().
+		AddText(`Version 1.2 is out! Read the `).
+		AddLink(`release notes`, "https://example.com/1.2").
+		AddText(`. Thanks, `).
+		AddMention(`@alice.bsky.social`, "did:plc:alice").
+		AddText(`! `).
+		AddTag(`#release`, "release")
+	assert.Equal(t, expected, post)
+}
+
+func TestDefaultTexts(t *testing.T) {
+	tmpl := template.Must(template.Parse(
+		`{{link "https://example.com"}} {{mention "@bob.bsky.social"}} {{mention "did:plc:carol" "Carol"}} {{tag "#k3" "K3"}}`))
+	post, err := tmpl.Execute(nil)
+	require.NoError(t, err)
+	expected := k3.NewPost().
+		AddLink(`https://example.com`, "https://example.com").
+		AddText(` @bob.bsky.social `).
+		AddMention(`Carol`, "did:plc:carol").
+		AddText(` `).
+		AddTag(`K3`, "k3")
+	assert.Equal(t, expected, post)
+}
+
+func TestTextImporter(t *testing.T) {
+	tmpl := template.Must(template.Parse(`See {{.}} #news`, template.WithTextImporter(text.NewImporter())))
+	post, err := tmpl.Execute("https://example.com/")
+	require.NoError(t, err)
+	expected := k3.NewPost().
+		AddText(`See `).
+		AddLink(`example.com`, "https://example.com/").
+		AddText(` `).
+		AddTag(`#news`, "news")
+	assert.Equal(t, expected, post)
+}
+
+func TestOptionalSectionsKeptWhenShort(t *testing.T) {
+	tmpl := template.Must(template.Parse(`Version {{.Version}}{{optional}}: {{.Notes}}{{end_optional}}.`))
+	post, err := tmpl.Execute(release{Version: "1.2", Notes: "bug fixes"})
+	require.NoError(t, err)
+	assert.Equal(t, `Version 1.2: bug fixes.`, post.GetPlainText())
+}
+
+func TestOptionalSectionsDropped(t *testing.T) {
+	tmpl := template.Must(template.Parse(
+		`A{{optional}} B{{end_optional}}{{optional}} C{{end_optional}}{{optional 1}} D{{end_optional}}.`,
+		template.WithMaxLength(6)))
+	post, err := tmpl.Execute(nil)
+	require.NoError(t, err)
+	assert.Equal(t, `A B D.`, post.GetPlainText())
+
+	tmpl = template.Must(template.Parse(
+		`A{{optional}} B{{end_optional}}{{optional}} C{{end_optional}}{{optional 1}} D{{end_optional}}.`,
+		template.WithMaxLength(4)))
+	post, err = tmpl.Execute(nil)
+	require.NoError(t, err)
+	assert.Equal(t, `A D.`, post.GetPlainText())
+}
+
+func TestNestedOptionalSections(t *testing.T) {
+	source := `A{{optional 1}} B{{optional}} C{{end_optional}}{{end_optional}} D`
+	tmpl := template.Must(template.Parse(source, template.WithMaxLength(6)))
+	post, err := tmpl.Execute(nil)
+	require.NoError(t, err)
+	assert.Equal(t, `A B D`, post.GetPlainText())
+
+	tmpl = template.Must(template.Parse(source, template.WithMaxLength(3)))
+	post, err = tmpl.Execute(nil)
+	require.NoError(t, err)
+	assert.Equal(t, `A D`, post.GetPlainText())
+}
+
+func TestTooLongWithoutOptionalSections(t *testing.T) {
+	tmpl := template.Must(template.Parse(`{{.}}{{optional}} extra{{end_optional}}`))
+	long := strings.Repeat("x", 400)
+	post, err := tmpl.Execute(long)
+	require.NoError(t, err)
+	assert.Equal(t, long, post.GetPlainText())
+}
+
+func TestOptionalSectionWithLink(t *testing.T) {
+	tmpl := template.Must(template.Parse(
+		`{{.Notes}}{{optional}} {{link .URL "more"}}{{end_optional}}`,
+		template.WithMaxLength(10)))
+	post, err := tmpl.Execute(release{Notes: "Fixes", URL: "https://example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, k3.NewPost().AddText(`Fixes `).AddLink(`more`, "https://example.com"), post)
+
+	post, err = tmpl.Execute(release{Notes: "Many fixes", URL: "https://example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, k3.NewPost().AddText(`Many fixes`), post)
+}
+
+func TestErrors(t *testing.T) {
+	_, err := template.Parse(`{{link}`)
+	assert.Error(t, err)
+
+	_, err = template.Must(template.Parse(`{{optional}}unclosed`)).Execute(nil)
+	assert.EqualError(t, err, "optional without a matching end_optional")
+
+	_, err = template.Must(template.Parse(`unopened{{end_optional}}`)).Execute(nil)
+	assert.EqualError(t, err, "end_optional without a matching optional")
+
+	_, err = template.Must(template.Parse("{{.}}")).Execute("bad\x00text")
+	assert.Error(t, err)
+
+	_, err = template.Must(template.Parse("{{.Missing}}")).Execute(release{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,94 @@
+package testing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/bluesky-social/indigo/api/bsky"
+	"github.com/jtarrio/k3/client"
+)
+
+// ErrFakeClientFailure is the error that a FakeClient returns when it is made to fail.
+var ErrFakeClientFailure = errors.New("failure posting")
+
+// NewFakeClient returns a fake client.Client for testing, which records the posts it publishes.
+//
+// The URI of each post it publishes is "at://did:plc:me/app.bsky.feed.post/<n>", where <n> is its index in Posts.
+func NewFakeClient() *FakeClient {
+	return &FakeClient{failAfter: -1}
+}
+
+// FakeClient is a fake client.Client for testing.
+type FakeClient struct {
+	mutex     sync.Mutex
+	posts     []*bsky.FeedPost
+	failAfter int
+	onPublish func()
+}
+
+var _ client.Client = &FakeClient{}
+
+// FailAfter makes the client publish the given number of posts and fail after that.
+// A negative number makes the client publish all posts.
+func (c *FakeClient) FailAfter(count int) *FakeClient {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.failAfter = count
+	return c
+}
+
+// OnPublish makes the client call the given function before it publishes each post.
+func (c *FakeClient) OnPublish(fn func()) *FakeClient {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.onPublish = fn
+	return c
+}
+
+// Posts returns all the posts that the client published.
+func (c *FakeClient) Posts() []*bsky.FeedPost {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]*bsky.FeedPost(nil), c.posts...)
+}
+
+// Texts returns the text of all the posts that the client published.
+func (c *FakeClient) Texts() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var out []string
+	for _, post := range c.posts {
+		out = append(out, post.Text)
+	}
+	return out
+}
+
+func (c *FakeClient) GetAccessToken(ctx context.Context) error {
+	return nil
+}
+
+func (c *FakeClient) Publish(ctx context.Context, post *bsky.FeedPost) (*client.PublishResult, error) {
+	c.mutex.Lock()
+	onPublish := c.onPublish
+	c.mutex.Unlock()
+	if onPublish != nil {
+		onPublish()
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.failAfter == 0 {
+		return nil, ErrFakeClientFailure
+	}
+	if c.failAfter > 0 {
+		c.failAfter--
+	}
+	c.posts = append(c.posts, post)
+	rkey := fmt.Sprintf("%d", len(c.posts)-1)
+	return &client.PublishResult{Uri: "at://did:plc:me/app.bsky.feed.post/" + rkey, Cid: rkey}, nil
+}
+
+func (c *FakeClient) FindUserByHandle(ctx context.Context, handle string) (*client.UserData, error) {
+	return nil, fmt.Errorf("user with handle '%s' not found", handle)
+}
@@ -1,12 +1,195 @@
 package testing
 
-import "time"
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jtarrio/k3"
+)
 
 // FakeClock is an k3.Clock that returns a user-provided time.
+//
+// Timers and tickers only fire when the clock is moved forward with Advance or Set.
+// Use BlockUntil to wait until the code under test has created its timers.
 type FakeClock struct {
-	Time time.Time
+	Time    time.Time
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	waiters []*fakeWaiter
+}
+
+type fakeWaiter struct {
+	clock    *FakeClock
+	deadline time.Time
+	period   time.Duration
+	ch       chan time.Time
 }
 
 func (f *FakeClock) Now() time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
 	return f.Time
 }
+
+// After returns a channel that receives the clock's time once it has been advanced by at least the given duration.
+func (f *FakeClock) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+// NewTimer creates a timer that fires once the clock has been advanced by at least the given duration.
+func (f *FakeClock) NewTimer(d time.Duration) k3.Timer {
+	w := &fakeWaiter{clock: f, ch: make(chan time.Time, 1)}
+	w.Reset(d)
+	return w
+}
+
+// NewTicker creates a ticker that fires every time the clock advances past a multiple of the given period.
+func (f *FakeClock) NewTicker(d time.Duration) k3.Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	w := &fakeWaiter{clock: f, ch: make(chan time.Time, 1)}
+	w.resetTicker(d)
+	return fakeTicker{w}
+}
+
+// Sleep waits until the clock has been advanced by at least the given duration, or the context is done.
+func (f *FakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	return k3.Sleep(ctx, f, d)
+}
+
+// Advance moves the clock forward by the given duration, firing the timers and tickers that become due in order.
+func (f *FakeClock) Advance(d time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.advanceTo(f.Time.Add(d))
+}
+
+// Set changes the clock's time. If the new time is later than the current time, the timers and tickers
+// that become due are fired in order.
+func (f *FakeClock) Set(t time.Time) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if t.Before(f.Time) {
+		f.Time = t
+		return
+	}
+	f.advanceTo(t)
+}
+
+// BlockUntil waits until there are at least the given number of active timers and tickers.
+//
+// Use this function before calling Advance to make sure that the code under test is already waiting.
+func (f *FakeClock) BlockUntil(waiters int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for len(f.waiters) < waiters {
+		f.getCond().Wait()
+	}
+}
+
+// advanceTo fires the waiters whose deadline is not after the given time, earliest first, and then sets the clock's time.
+//
+// While each waiter is fired, the clock's time is set to its deadline. Like time.Ticker, a ticker whose channel
+// is full drops the tick.
+func (f *FakeClock) advanceTo(t time.Time) {
+	for len(f.waiters) > 0 && !f.waiters[0].deadline.After(t) {
+		w := f.waiters[0]
+		f.waiters = f.waiters[1:]
+		f.Time = w.deadline
+		select {
+		case w.ch <- f.Time:
+		default:
+		}
+		if w.period > 0 {
+			w.deadline = w.deadline.Add(w.period)
+			f.addWaiter(w)
+		}
+	}
+	f.Time = t
+}
+
+func (f *FakeClock) addWaiter(w *fakeWaiter) {
+	i, _ := slices.BinarySearchFunc(f.waiters, w, func(a, b *fakeWaiter) int {
+		if a.deadline.After(b.deadline) {
+			return 1
+		}
+		return -1
+	})
+	f.waiters = slices.Insert(f.waiters, i, w)
+	f.getCond().Broadcast()
+}
+
+// removeWaiter removes the waiter and returns whether it was active.
+func (f *FakeClock) removeWaiter(w *fakeWaiter) bool {
+	i := slices.Index(f.waiters, w)
+	if i < 0 {
+		return false
+	}
+	f.waiters = slices.Delete(f.waiters, i, i+1)
+	return true
+}
+
+func (f *FakeClock) getCond() *sync.Cond {
+	if f.cond == nil {
+		f.cond = sync.NewCond(&f.mutex)
+	}
+	return f.cond
+}
+
+func (w *fakeWaiter) C() <-chan time.Time {
+	return w.ch
+}
+
+func (w *fakeWaiter) Stop() bool {
+	w.clock.mutex.Lock()
+	defer w.clock.mutex.Unlock()
+	return w.clock.removeWaiter(w)
+}
+
+func (w *fakeWaiter) Reset(d time.Duration) bool {
+	w.clock.mutex.Lock()
+	defer w.clock.mutex.Unlock()
+	active := w.clock.removeWaiter(w)
+	w.deadline = w.clock.Time.Add(d)
+	w.period = 0
+	if d <= 0 {
+		select {
+		case w.ch <- w.clock.Time:
+		default:
+		}
+		return active
+	}
+	w.clock.addWaiter(w)
+	return active
+}
+
+func (w *fakeWaiter) resetTicker(d time.Duration) {
+	w.clock.mutex.Lock()
+	defer w.clock.mutex.Unlock()
+	w.clock.removeWaiter(w)
+	w.deadline = w.clock.Time.Add(d)
+	w.period = d
+	w.clock.addWaiter(w)
+}
+
+type fakeTicker struct {
+	w *fakeWaiter
+}
+
+func (t fakeTicker) C() <-chan time.Time {
+	return t.w.ch
+}
+
+func (t fakeTicker) Stop() {
+	t.w.Stop()
+}
+
+func (t fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+	t.w.resetTicker(d)
+}
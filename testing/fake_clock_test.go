@@ -0,0 +1,76 @@
+package testing_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	atptesting "github.com/jtarrio/k3/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+var startTime = time.Date(2025, time.January, 2, 12, 0, 0, 0, time.UTC)
+
+func TestFakeTimersFireInOrder(t *testing.T) {
+	clock := &atptesting.FakeClock{Time: startTime}
+	late := clock.NewTimer(3 * time.Second)
+	early := clock.After(time.Second)
+	stopped := clock.NewTimer(2 * time.Second)
+	assert.True(t, stopped.Stop())
+	assert.False(t, stopped.Stop())
+
+	clock.Advance(500 * time.Millisecond)
+	assert.Empty(t, early)
+	assert.Empty(t, late.C())
+
+	clock.Advance(5 * time.Second)
+	assert.Equal(t, startTime.Add(time.Second), <-early)
+	assert.Equal(t, startTime.Add(3*time.Second), <-late.C())
+	assert.Empty(t, stopped.C())
+	assert.False(t, late.Stop())
+	assert.Equal(t, startTime.Add(5500*time.Millisecond), clock.Now())
+
+	assert.False(t, late.Reset(time.Second))
+	clock.Set(startTime.Add(10 * time.Second))
+	assert.Equal(t, startTime.Add(6500*time.Millisecond), <-late.C())
+}
+
+func TestFakeTicker(t *testing.T) {
+	clock := &atptesting.FakeClock{Time: startTime}
+	ticker := clock.NewTicker(time.Minute)
+	clock.Advance(time.Minute)
+	assert.Equal(t, startTime.Add(time.Minute), <-ticker.C())
+
+	// Ticks are dropped if nobody reads them, like with time.Ticker.
+	clock.Advance(3 * time.Minute)
+	assert.Equal(t, startTime.Add(2*time.Minute), <-ticker.C())
+	assert.Empty(t, ticker.C())
+
+	ticker.Reset(time.Hour)
+	clock.Advance(time.Minute)
+	assert.Empty(t, ticker.C())
+	ticker.Stop()
+	clock.Advance(2 * time.Hour)
+	assert.Empty(t, ticker.C())
+}
+
+func TestFakeSleep(t *testing.T) {
+	clock := &atptesting.FakeClock{Time: startTime}
+	done := make(chan error)
+	go func() {
+		done <- clock.Sleep(context.Background(), time.Hour)
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(time.Hour)
+	assert.NoError(t, <-done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		done <- clock.Sleep(ctx, time.Hour)
+	}()
+	clock.BlockUntil(1)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	clock.BlockUntil(0)
+	assert.NoError(t, clock.Sleep(context.Background(), 0))
+}
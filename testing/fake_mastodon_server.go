@@ -0,0 +1,162 @@
+package testing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"sync"
+)
+
+// NewFakeMastodonServer returns a fake Mastodon server for testing, which accepts the given access token.
+func NewFakeMastodonServer(accessToken string, options ...FakeMastodonServerOption) *FakeMastodonServer {
+	fs := &FakeMastodonServer{
+		accessToken:   accessToken,
+		maxCharacters: 500,
+		failAfter:     -1,
+	}
+	for _, option := range options {
+		option(fs)
+	}
+	fs.server = httptest.NewServer(fs)
+	return fs
+}
+
+// WithMaxCharacters sets the maximum length of the statuses that the FakeMastodonServer accepts.
+func WithMaxCharacters(maxCharacters int) FakeMastodonServerOption {
+	return func(fs *FakeMastodonServer) {
+		fs.maxCharacters = maxCharacters
+	}
+}
+
+type FakeMastodonServerOption func(*FakeMastodonServer)
+
+// FakeMastodonServer is a fake Mastodon server for testing.
+type FakeMastodonServer struct {
+	// Statuses contains all the statuses that were published to the server.
+	Statuses      []MastodonStatus
+	accessToken   string
+	maxCharacters int
+	failAfter     int
+	mutex         sync.Mutex
+	server        *httptest.Server
+}
+
+// MastodonStatus contains information about a published Mastodon status.
+type MastodonStatus struct {
+	ID          string
+	Status      string
+	InReplyToID string
+	Sensitive   bool
+	SpoilerText string
+	Visibility  string
+	Language    string
+}
+
+// URL returns the server's URL.
+func (f *FakeMastodonServer) URL() string {
+	return f.server.URL
+}
+
+// Close shuts down the server.
+func (f *FakeMastodonServer) Close() {
+	f.server.Close()
+}
+
+// FailAfter makes the server accept the given number of statuses and fail after that.
+// A negative number makes the server accept all statuses.
+func (f *FakeMastodonServer) FailAfter(count int) *FakeMastodonServer {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.failAfter = count
+	return f
+}
+
+func (f *FakeMastodonServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if req.Method != http.MethodPost || req.URL.Path != "/api/v1/statuses" {
+		outputMastodonError(rw, http.StatusNotFound, "Not found")
+		return
+	}
+	if req.Header.Get("Authorization") != "Bearer "+f.accessToken {
+		outputMastodonError(rw, http.StatusUnauthorized, "The access token is invalid")
+		return
+	}
+	var input struct {
+		Status      string `json:"status"`
+		InReplyToID string `json:"in_reply_to_id"`
+		Sensitive   bool   `json:"sensitive"`
+		SpoilerText string `json:"spoiler_text"`
+		Visibility  string `json:"visibility"`
+		Language    string `json:"language"`
+	}
+	defer req.Body.Close()
+	if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+		outputMastodonError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+	if input.Status == "" {
+		outputMastodonError(rw, http.StatusUnprocessableEntity, "Validation failed: Text can't be blank")
+		return
+	}
+	if mastodonLength(input.Status)+len([]rune(input.SpoilerText)) > f.maxCharacters {
+		outputMastodonError(rw, http.StatusUnprocessableEntity, fmt.Sprintf("Validation failed: Text character limit of %d exceeded", f.maxCharacters))
+		return
+	}
+	if input.InReplyToID != "" && !slices.ContainsFunc(f.Statuses, func(s MastodonStatus) bool { return s.ID == input.InReplyToID }) {
+		outputMastodonError(rw, http.StatusNotFound, "Record not found")
+		return
+	}
+	if f.failAfter == 0 {
+		outputMastodonError(rw, http.StatusServiceUnavailable, "Service unavailable")
+		return
+	}
+	if f.failAfter > 0 {
+		f.failAfter--
+	}
+	status := MastodonStatus{
+		ID:          fmt.Sprintf("%d", 1000+len(f.Statuses)),
+		Status:      input.Status,
+		InReplyToID: input.InReplyToID,
+		Sensitive:   input.Sensitive,
+		SpoilerText: input.SpoilerText,
+		Visibility:  input.Visibility,
+		Language:    input.Language,
+	}
+	f.Statuses = append(f.Statuses, status)
+	b, err := json.Marshal(map[string]any{
+		"id":             status.ID,
+		"uri":            f.server.URL + "/users/me/statuses/" + status.ID,
+		"url":            f.server.URL + "/@me/" + status.ID,
+		"content":        status.Status,
+		"in_reply_to_id": status.InReplyToID,
+	})
+	if err != nil {
+		outputMastodonError(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+	rw.Write(b)
+}
+
+var mastodonUrlRegexp = regexp.MustCompile(`https?://[^\s()]+`)
+var mastodonMentionRegexp = regexp.MustCompile(`(@\w+(?:[\w.-]*\w)?)@[\w.-]+\w`)
+
+// mastodonLength returns the length of a status as Mastodon counts it: URLs count as 23 characters,
+// and mentions of remote users count without their domain.
+func mastodonLength(text string) int {
+	text = mastodonUrlRegexp.ReplaceAllLiteralString(text, "xxxxxxxxxxxxxxxxxxxxxxx")
+	text = mastodonMentionRegexp.ReplaceAllString(text, "$1")
+	return len([]rune(text))
+}
+
+func outputMastodonError(rw http.ResponseWriter, code int, message string) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	b, err := json.Marshal(map[string]string{"error": message})
+	if err == nil {
+		rw.Write(b)
+	}
+}
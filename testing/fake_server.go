@@ -6,12 +6,14 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/bluesky-social/indigo/api/atproto"
 	"github.com/bluesky-social/indigo/api/bsky"
 	"github.com/bluesky-social/indigo/atproto/identity"
+	"github.com/bluesky-social/indigo/lex/util"
 	"github.com/bluesky-social/indigo/xrpc"
 	"github.com/jtarrio/k3"
 	"github.com/lestrrat-go/jwx/v3/jwt"
@@ -31,6 +33,8 @@ func NewFakeServer(options ...FakeServerOption) *FakeServer {
 	fs.Register(NewFunction(xrpc.Procedure, "com.atproto.server.createSession", fs.serverCreateSession))
 	fs.Register(NewCommand(xrpc.Procedure, "com.atproto.server.refreshSession", fs.serverRefreshSession))
 	fs.Register(NewFunction(xrpc.Procedure, "com.atproto.repo.createRecord", fs.repoCreateRecord))
+	fs.Register(NewFunction(xrpc.Procedure, "com.atproto.repo.deleteRecord", fs.repoDeleteRecord))
+	fs.Register(NewCommand(xrpc.Query, "com.atproto.repo.getRecord", fs.repoGetRecord))
 	fs.Register(NewCommand(xrpc.Query, "com.atproto.identity.resolveHandle", fs.identityResolveHandle))
 	return fs
 }
@@ -48,13 +52,18 @@ type FakeServerOption func(*FakeServer)
 type FakeServer struct {
 	// Calls contains information about all the methods that were called in the fake server.
 	Calls []Call
-	// Posts contains all the posts that were published to the server.
-	Posts    []Post
-	clock    k3.Clock
-	users    map[string]string
-	methods  map[methodKey]methodFunc
-	userDids []identity.DIDDocument
-	server   *httptest.Server
+	// Posts contains all the posts that were published to the server and not deleted.
+	Posts []Post
+	// Threadgates contains all the threadgates that were published to the server.
+	Threadgates []Threadgate
+	// Postgates contains all the postgates that were published to the server.
+	Postgates []Postgate
+	postCount int
+	clock     k3.Clock
+	users     map[string]string
+	methods   map[methodKey]methodFunc
+	userDids  []identity.DIDDocument
+	server    *httptest.Server
 }
 
 // Call contains information about a method call.
@@ -72,6 +81,20 @@ type Post struct {
 	Record *bsky.FeedPost
 }
 
+// Threadgate contains information about a published threadgate.
+type Threadgate struct {
+	Repo   string
+	Rkey   string
+	Record *bsky.FeedThreadgate
+}
+
+// Postgate contains information about a published postgate.
+type Postgate struct {
+	Repo   string
+	Rkey   string
+	Record *bsky.FeedPostgate
+}
+
 // URL returns the server's URL.
 func (f *FakeServer) URL() string {
 	return f.server.URL
@@ -146,28 +169,107 @@ func (f *FakeServer) repoCreateRecord(user *string, params map[string][]string,
 	if user == nil {
 		return nil, fmt.Errorf("no valid JWT in request")
 	}
-	if input.Collection != "app.bsky.feed.post" {
-		return nil, fmt.Errorf("invalid collection: %s", input.Collection)
-	}
 	rkey := ""
 	if input.Rkey != nil {
 		rkey = *input.Rkey
-	} else {
-		rkey = fmt.Sprintf("%x", len(f.Posts))
 	}
-	f.Posts = append(f.Posts, Post{
-		Repo:   input.Repo,
-		Rkey:   rkey,
-		Record: input.Record.Val.(*bsky.FeedPost),
-	})
+	switch input.Collection {
+	case "app.bsky.feed.post":
+		if input.Rkey == nil {
+			rkey = fmt.Sprintf("%x", f.postCount)
+		} else if slices.ContainsFunc(f.Posts, func(p Post) bool { return p.Repo == input.Repo && p.Rkey == rkey }) {
+			return nil, fmt.Errorf("record already exists: %s", rkey)
+		}
+		f.postCount++
+		f.Posts = append(f.Posts, Post{
+			Repo:   input.Repo,
+			Rkey:   rkey,
+			Record: input.Record.Val.(*bsky.FeedPost),
+		})
+	case "app.bsky.feed.threadgate":
+		if input.Rkey == nil {
+			return nil, fmt.Errorf("threadgate without rkey")
+		} else if slices.ContainsFunc(f.Threadgates, func(t Threadgate) bool { return t.Repo == input.Repo && t.Rkey == rkey }) {
+			return nil, fmt.Errorf("record already exists: %s", rkey)
+		}
+		f.Threadgates = append(f.Threadgates, Threadgate{
+			Repo:   input.Repo,
+			Rkey:   rkey,
+			Record: input.Record.Val.(*bsky.FeedThreadgate),
+		})
+	case "app.bsky.feed.postgate":
+		if input.Rkey == nil {
+			return nil, fmt.Errorf("postgate without rkey")
+		} else if slices.ContainsFunc(f.Postgates, func(p Postgate) bool { return p.Repo == input.Repo && p.Rkey == rkey }) {
+			return nil, fmt.Errorf("record already exists: %s", rkey)
+		}
+		f.Postgates = append(f.Postgates, Postgate{
+			Repo:   input.Repo,
+			Rkey:   rkey,
+			Record: input.Record.Val.(*bsky.FeedPostgate),
+		})
+	default:
+		return nil, fmt.Errorf("invalid collection: %s", input.Collection)
+	}
 	output := &atproto.RepoCreateRecord_Output{
 		Cid:    rkey,
 		Commit: &atproto.RepoDefs_CommitMeta{},
-		Uri:    fmt.Sprintf("https://uri/%s/%s", input.Repo, rkey),
+		Uri:    fmt.Sprintf("at://%s/%s/%s", input.Repo, input.Collection, rkey),
 	}
 	return output, nil
 }
 
+func (f *FakeServer) repoDeleteRecord(user *string, params map[string][]string, input *atproto.RepoDeleteRecord_Input) (*atproto.RepoDeleteRecord_Output, error) {
+	if user == nil {
+		return nil, fmt.Errorf("no valid JWT in request")
+	}
+	switch input.Collection {
+	case "app.bsky.feed.post":
+		f.Posts = slices.DeleteFunc(f.Posts, func(p Post) bool { return p.Repo == input.Repo && p.Rkey == input.Rkey })
+	case "app.bsky.feed.threadgate":
+		f.Threadgates = slices.DeleteFunc(f.Threadgates, func(t Threadgate) bool { return t.Repo == input.Repo && t.Rkey == input.Rkey })
+	case "app.bsky.feed.postgate":
+		f.Postgates = slices.DeleteFunc(f.Postgates, func(p Postgate) bool { return p.Repo == input.Repo && p.Rkey == input.Rkey })
+	default:
+		return nil, fmt.Errorf("invalid collection: %s", input.Collection)
+	}
+	return &atproto.RepoDeleteRecord_Output{Commit: &atproto.RepoDefs_CommitMeta{}}, nil
+}
+
+func (f *FakeServer) repoGetRecord(user *string, params map[string][]string) (*atproto.RepoGetRecord_Output, error) {
+	repo, collection, rkey := params["repo"], params["collection"], params["rkey"]
+	if len(repo) == 0 || len(collection) == 0 || len(rkey) == 0 {
+		return nil, errors.New("repo, collection, and rkey must be specified")
+	}
+	var record util.CBOR
+	switch collection[0] {
+	case "app.bsky.feed.post":
+		if i := slices.IndexFunc(f.Posts, func(p Post) bool { return p.Repo == repo[0] && p.Rkey == rkey[0] }); i >= 0 {
+			record = f.Posts[i].Record
+		}
+	case "app.bsky.feed.threadgate":
+		if i := slices.IndexFunc(f.Threadgates, func(t Threadgate) bool { return t.Repo == repo[0] && t.Rkey == rkey[0] }); i >= 0 {
+			record = f.Threadgates[i].Record
+		}
+	case "app.bsky.feed.postgate":
+		if i := slices.IndexFunc(f.Postgates, func(p Postgate) bool { return p.Repo == repo[0] && p.Rkey == rkey[0] }); i >= 0 {
+			record = f.Postgates[i].Record
+		}
+	default:
+		return nil, fmt.Errorf("invalid collection: %s", collection[0])
+	}
+	if record != nil {
+		cid := rkey[0]
+		output := &atproto.RepoGetRecord_Output{
+			Cid:   &cid,
+			Uri:   fmt.Sprintf("at://%s/%s/%s", repo[0], collection[0], rkey[0]),
+			Value: &util.LexiconTypeDecoder{Val: record},
+		}
+		return output, nil
+	}
+	return nil, fmt.Errorf("record not found: %s", rkey[0])
+}
+
 func (f *FakeServer) identityResolveHandle(user *string, params map[string][]string) (*atproto.IdentityResolveHandle_Output, error) {
 	handle, found := params["handle"]
 	if !found {
@@ -0,0 +1,116 @@
+// Package tid generates and parses TIDs, the timestamp identifiers used as record keys in Bluesky.
+//
+// A TID encodes a timestamp in microseconds and a clock identifier between 0 and 1023 in 13 characters,
+// using a base32 alphabet that makes the TIDs sort in the same order as their timestamps.
+package tid
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/jtarrio/k3"
+)
+
+// MaxClockID is the largest clock identifier that can be encoded in a TID.
+const MaxClockID = 1023
+
+// New creates a new TID generator with the given options.
+//
+// By default, the generator uses the system clock and a random clock identifier.
+func New(options ...GeneratorOption) *Generator {
+	g := &Generator{clock: k3.SystemClock(), clockID: randomClockID()}
+	for _, option := range options {
+		option(g)
+	}
+	return g
+}
+
+// WithClock makes the generator take the timestamps from the given clock.
+func WithClock(clock k3.Clock) GeneratorOption {
+	return func(g *Generator) {
+		g.clock = clock
+	}
+}
+
+// WithClockID makes the generator use the given clock identifier, which must be between 0 and MaxClockID.
+// It panics if the clock identifier is out of range.
+//
+// Generators that could produce TIDs for the same repository at the same time should have different clock identifiers.
+func WithClockID(clockID uint) GeneratorOption {
+	checkClockID(clockID)
+	return func(g *Generator) {
+		g.clockID = clockID
+	}
+}
+
+type GeneratorOption func(*Generator)
+
+// Generator produces TIDs from the current time.
+//
+// The TIDs are strictly increasing, even when the clock doesn't move or goes back,
+// and the generator can be used from several goroutines at once.
+type Generator struct {
+	clock   k3.Clock
+	clockID uint
+	mutex   sync.Mutex
+	last    int64
+}
+
+// Next returns a new TID.
+func (g *Generator) Next() string {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	micros := g.clock.Now().UnixMicro()
+	if micros <= g.last {
+		micros = g.last + 1
+	}
+	g.last = micros
+	return syntax.NewTID(micros, g.clockID).String()
+}
+
+// ClockID returns the generator's clock identifier.
+func (g *Generator) ClockID() uint {
+	return g.clockID
+}
+
+// FromTime returns the TID for the given time and clock identifier, which must be between 0 and MaxClockID.
+// It panics if the clock identifier is out of range.
+//
+// The same time and clock identifier always produce the same TID.
+func FromTime(t time.Time, clockID uint) string {
+	checkClockID(clockID)
+	return syntax.NewTIDFromTime(t, clockID).String()
+}
+
+// Parse returns the time and the clock identifier encoded in a TID.
+func Parse(tid string) (time.Time, uint, error) {
+	parsed, err := syntax.ParseTID(tid)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("invalid TID '%s': %w", tid, err)
+	}
+	return parsed.Time(), parsed.ClockID(), nil
+}
+
+// Validate returns an error if the given string is not a valid TID.
+func Validate(tid string) error {
+	_, _, err := Parse(tid)
+	return err
+}
+
+func checkClockID(clockID uint) {
+	if clockID > MaxClockID {
+		panic(fmt.Sprintf("clock identifier %d is greater than %d", clockID, MaxClockID))
+	}
+}
+
+func randomClockID() uint {
+	var b [2]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return 0
+	}
+	return uint(binary.BigEndian.Uint16(b[:])) & MaxClockID
+}
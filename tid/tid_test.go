@@ -0,0 +1,91 @@
+package tid_test
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	atptesting "github.com/jtarrio/k3/testing"
+	"github.com/jtarrio/k3/tid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var startTime = time.Date(2025, time.January, 2, 12, 34, 56, 789000000, time.UTC)
+
+func TestFromTime(t *testing.T) {
+	assert.Equal(t, "3ler3lwntkc2b", tid.FromTime(startTime, 7))
+	assert.Equal(t, tid.FromTime(startTime, 7), tid.FromTime(startTime, 7))
+	assert.Less(t, tid.FromTime(startTime, 7), tid.FromTime(startTime.Add(time.Microsecond), 0))
+
+	parsed, clockID, err := tid.Parse("3ler3lwntkc2b")
+	require.NoError(t, err)
+	assert.True(t, startTime.Equal(parsed))
+	assert.Equal(t, uint(7), clockID)
+}
+
+func TestOutOfRangeClockID(t *testing.T) {
+	assert.NotPanics(t, func() { tid.FromTime(startTime, tid.MaxClockID) })
+	assert.PanicsWithValue(t, "clock identifier 1024 is greater than 1023", func() { tid.FromTime(startTime, tid.MaxClockID+1) })
+	assert.PanicsWithValue(t, "clock identifier 1031 is greater than 1023", func() { tid.WithClockID(1031) })
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, tid.Validate("3ler3lwntkc2b"))
+	assert.Error(t, tid.Validate(""))
+	assert.Error(t, tid.Validate("3ler3lwntkc2"))
+	assert.Error(t, tid.Validate("3LER3LWNTKC2B"))
+	assert.Error(t, tid.Validate("zler3lwntkc2b"))
+	assert.Error(t, tid.Validate("3ler3lwntkc21"))
+}
+
+func TestGeneratorWithFakeClock(t *testing.T) {
+	clock := &atptesting.FakeClock{Time: startTime}
+	g := tid.New(tid.WithClock(clock), tid.WithClockID(7))
+	assert.Equal(t, uint(7), g.ClockID())
+
+	first := g.Next()
+	assert.Equal(t, "3ler3lwntkc2b", first)
+	// The clock doesn't move, so the next TID is one microsecond later.
+	second := g.Next()
+	parsed, _, err := tid.Parse(second)
+	require.NoError(t, err)
+	assert.True(t, startTime.Add(time.Microsecond).Equal(parsed))
+
+	// The clock goes back, but the TIDs keep increasing.
+	clock.Set(startTime.Add(-time.Hour))
+	third := g.Next()
+	assert.Less(t, second, third)
+
+	clock.Set(startTime.Add(time.Hour))
+	fourth := g.Next()
+	parsed, _, err = tid.Parse(fourth)
+	require.NoError(t, err)
+	assert.True(t, startTime.Add(time.Hour).Equal(parsed))
+}
+
+func TestGeneratorConcurrency(t *testing.T) {
+	g := tid.New(tid.WithClock(&atptesting.FakeClock{Time: startTime}))
+	assert.LessOrEqual(t, g.ClockID(), uint(tid.MaxClockID))
+	var wg sync.WaitGroup
+	results := make([][]string, 8)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				results[i] = append(results[i], g.Next())
+			}
+		}()
+	}
+	wg.Wait()
+
+	var all []string
+	for _, tids := range results {
+		assert.True(t, slices.IsSorted(tids))
+		all = append(all, tids...)
+	}
+	slices.Sort(all)
+	assert.Len(t, slices.Compact(all), 800)
+}